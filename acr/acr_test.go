@@ -415,3 +415,51 @@ func testCheckMap(t *testing.T, nodename string, statemap map[string]string, sta
 		t.Error(fmt.Errorf("Node %s should have states : %s but has states %s", nodename, strings.Join(states, ","), st))
 	}
 }
+
+func TestParsimonyAcrTraits(t *testing.T) {
+	treeString := "(t1,(t2,((t3,(t4,t5)t6)t7,(t8,((t9,t10)t11,((t12,t13)t14,t15)t16)t17)t18)t19)t20[c1])t21;"
+	tipstates := []map[string]string{
+		{
+			"t1": "A", "t2": "A", "t3": "B", "t4": "B", "t5": "A", "t8": "B",
+			"t9": "B", "t10": "A", "t12": "A", "t13": "A", "t15": "A",
+		},
+		{
+			"t1": "C", "t2": "C", "t3": "C", "t4": "C", "t5": "C", "t8": "D",
+			"t9": "D", "t10": "D", "t12": "D", "t13": "D", "t15": "D",
+		},
+	}
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Error(err)
+	}
+
+	statemaps, nsteps, err := ParsimonyAcrTraits(tr, tipstates, ALGO_DELTRAN, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(statemaps) != 2 || len(nsteps) != 2 {
+		t.Fatalf("There should be 2 reconstructions")
+	}
+	if nsteps[0] != 4 {
+		t.Error(fmt.Errorf("Number of pars steps of trait 0 is %d and should be %d", nsteps[0], 4))
+	}
+	if nsteps[1] != 1 {
+		t.Error(fmt.Errorf("Number of pars steps of trait 1 is %d and should be %d", nsteps[1], 1))
+	}
+	testCheckMap(t, "t6", statemaps[0], "A")
+	testCheckMap(t, "t18", statemaps[0], "A")
+	testCheckMap(t, "t6", statemaps[1], "C")
+	testCheckMap(t, "t18", statemaps[1], "D")
+	testCheckMap(t, "t19", statemaps[1], "C")
+
+	// Node comments should be left unchanged
+	for _, n := range tr.Nodes() {
+		if n.Name() == "t20" {
+			if len(n.Comments()) != 1 || n.Comments()[0] != "c1" {
+				t.Errorf("Node t20 should keep its comment c1: %v", n.Comments())
+			}
+		} else if len(n.Comments()) != 0 {
+			t.Errorf("Node %s should have no comment: %v", n.Name(), n.Comments())
+		}
+	}
+}
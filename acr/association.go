@@ -0,0 +1,201 @@
+package acr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Statistics of association between a trait and a tree
+// (see BaTS, Parker et al., 2008)
+type AssociationStats struct {
+	AI float64 // Association index (Wang et al., 2001)
+	PS int     // Parsimony score (Fitch)
+	MC []int   // Maximum monophyletic clade size of each state (in the order of the alphabet)
+}
+
+// Summary of an association statistic over a set of trees
+type AssociationSummary struct {
+	Statistic    string  // Name of the statistic: AI, PS, or MC(state)
+	Observed     float64 // Mean observed value over all trees
+	ObservedLow  float64 // 2.5% quantile of observed values
+	ObservedHigh float64 // 97.5% quantile of observed values
+	Null         float64 // Mean value over all randomizations of all trees
+	NullLow      float64 // 2.5% quantile of null values
+	NullHigh     float64 // 97.5% quantile of null values
+	PValue       float64 // Proportion of null values at least as extreme as the mean observed value
+}
+
+// Computes the association index, the parsimony score, and the maximum monophyletic clade size
+// of each state of the given alphabet, given the states of the tips.
+//
+// The association index is the sum over all internal nodes of (1-f)/(2^(n-1)), n being the number
+// of tips under the node, and f the frequency of the most frequent state among them.
+// The maximum monophyletic clade size of a state is the number of tips of the largest clade
+// (given the root of the tree) whose tips all have this state.
+func Association(t *tree.Tree, tipCharacters map[string]string, alphabet []string) (stats AssociationStats, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var counts [][]int = make([][]int, len(nodes))
+	stateIndices := AncestralStateIndices(alphabet)
+
+	for i, n := range nodes {
+		n.SetId(i)
+	}
+	// Parsimony score (states are not written in the node comments)
+	if _, _, stats.PS, err = parsimonyStates(t, nodes, tipCharacters, ALGO_NONE, false); err != nil {
+		return
+	}
+	for i := range counts {
+		counts[i] = make([]int, len(alphabet))
+	}
+	stats.MC = make([]int, len(alphabet))
+	t.PostOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) (keep bool) {
+		c := counts[cur.Id()]
+		if cur.Tip() {
+			idx, ok := stateIndices[tipCharacters[cur.Name()]]
+			if !ok {
+				err = fmt.Errorf("State of tip %s does not exist in the alphabet", cur.Name())
+				return false
+			}
+			c[idx]++
+		}
+		n, max, maxidx := 0, 0, 0
+		for i, v := range c {
+			n += v
+			if v > max {
+				max, maxidx = v, i
+			}
+		}
+		if !cur.Tip() {
+			stats.AI += (1 - float64(max)/float64(n)) / math.Pow(2, float64(n-1))
+		}
+		if max == n && n > stats.MC[maxidx] {
+			stats.MC[maxidx] = n
+		}
+		if prev != nil {
+			for i, v := range c {
+				counts[prev.Id()][i] += v
+			}
+		}
+		return true
+	})
+	return
+}
+
+// Tests the association between a trait and a set of trees (BaTS-like, Parker et al., 2008).
+//
+// For each tree, the association statistics (see Association) are computed on the observed
+// tip states, and on nrand randomizations of the tip states (states are shuffled among tips).
+// Returns the summaries of the AI, PS, and MC of each state (in the alphabetical order of the states).
+// P-values are the proportions of null values lower than or equal to the mean observed value for AI and PS,
+// and greater than or equal to the mean observed value for MC.
+func AssociationTest(trees <-chan tree.Trees, tipCharacters map[string]string, nrand int) (summaries []AssociationSummary, err error) {
+	var alphabet []string
+	var obs, null []AssociationStats
+	var stats AssociationStats
+
+	if nrand <= 0 {
+		err = errors.New("Number of randomizations should be > 0")
+		return
+	}
+
+	seenState := make(map[string]bool)
+	for _, state := range tipCharacters {
+		if _, ok := seenState[state]; !ok {
+			alphabet = append(alphabet, state)
+		}
+		seenState[state] = true
+	}
+	sort.Strings(alphabet)
+
+	for t := range trees {
+		if t.Err != nil {
+			err = t.Err
+			break
+		}
+		if stats, err = Association(t.Tree, tipCharacters, alphabet); err != nil {
+			break
+		}
+		obs = append(obs, stats)
+
+		tips := t.Tree.Tips()
+		states := make([]string, len(tips))
+		for i, tip := range tips {
+			states[i] = tipCharacters[tip.Name()]
+		}
+		randCharacters := make(map[string]string, len(tips))
+		for r := 0; r < nrand; r++ {
+			for i, p := range rand.Perm(len(tips)) {
+				randCharacters[tips[i].Name()] = states[p]
+			}
+			if stats, err = Association(t.Tree, randCharacters, alphabet); err != nil {
+				break
+			}
+			null = append(null, stats)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err != nil {
+		// We empty the channel if needed
+		for range trees {
+		}
+		return
+	}
+	if len(obs) == 0 {
+		err = errors.New("No tree given")
+		return
+	}
+
+	summaries = append(summaries,
+		summarizeAssociation("AI", obs, null, func(s AssociationStats) float64 { return s.AI }, true),
+		summarizeAssociation("PS", obs, null, func(s AssociationStats) float64 { return float64(s.PS) }, true))
+	for i, state := range alphabet {
+		summaries = append(summaries,
+			summarizeAssociation(fmt.Sprintf("MC(%s)", state), obs, null, func(s AssociationStats) float64 { return float64(s.MC[i]) }, false))
+	}
+	return
+}
+
+// Summarizes the observed and null values of a statistic.
+// If lower is true, then low values denote association.
+func summarizeAssociation(name string, obs, null []AssociationStats, value func(AssociationStats) float64, lower bool) (s AssociationSummary) {
+	obsvalues := make([]float64, len(obs))
+	for i, o := range obs {
+		obsvalues[i] = value(o)
+	}
+	nullvalues := make([]float64, len(null))
+	for i, n := range null {
+		nullvalues[i] = value(n)
+	}
+	s.Statistic = name
+	s.Observed, s.ObservedLow, s.ObservedHigh = meanInterval(obsvalues)
+	s.Null, s.NullLow, s.NullHigh = meanInterval(nullvalues)
+	extreme := 0
+	for _, v := range nullvalues {
+		if (lower && v <= s.Observed) || (!lower && v >= s.Observed) {
+			extreme++
+		}
+	}
+	s.PValue = float64(extreme) / float64(len(nullvalues))
+	return
+}
+
+// Returns the mean, and the 2.5% and 97.5% quantiles of the values
+func meanInterval(values []float64) (mean, low, high float64) {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	for _, v := range sorted {
+		mean += v
+	}
+	mean /= float64(len(sorted))
+	low = sorted[int(math.Floor(0.025*float64(len(sorted)-1)))]
+	high = sorted[int(math.Ceil(0.975*float64(len(sorted)-1)))]
+	return
+}
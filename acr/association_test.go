@@ -0,0 +1,103 @@
+package acr
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestAssociation(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader("((t1,t2)[c1],(t3,t4));")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tipstates := map[string]string{"t1": "A", "t2": "A", "t3": "A", "t4": "B"}
+	stats, err := Association(tr, tipstates, []string{"A", "B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (t3,t4): (1-1/2)/2 ; root: (1-3/4)/8
+	if math.Abs(stats.AI-0.28125) > 1e-10 {
+		t.Errorf("AI is %f and should be %f", stats.AI, 0.28125)
+	}
+	if stats.PS != 1 {
+		t.Errorf("PS is %d and should be %d", stats.PS, 1)
+	}
+	if stats.MC[0] != 2 || stats.MC[1] != 1 {
+		t.Errorf("MC are %v and should be [2 1]", stats.MC)
+	}
+	if tr.Newick() != "((t1,t2)[c1],(t3,t4));" {
+		t.Errorf("The input tree should not be modified: %s", tr.Newick())
+	}
+}
+
+func TestAssociationTest(t *testing.T) {
+	rand.Seed(10)
+	treeString := "((((t1,t2),(t3,t4)),((t5,t6),(t7,t8))),(((t9,t10),(t11,t12)),((t13,t14),(t15,t16))));"
+	tipstates := make(map[string]string)
+	for i := 1; i <= 16; i++ {
+		tipstates[fmt.Sprintf("t%d", i)] = "A"
+		if i > 8 {
+			tipstates[fmt.Sprintf("t%d", i)] = "B"
+		}
+	}
+	trees := make(chan tree.Trees, 2)
+	for i := 0; i < 2; i++ {
+		tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		trees <- tree.Trees{Tree: tr, Id: i}
+	}
+	close(trees)
+
+	summaries, err := AssociationTest(trees, tipstates, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"AI", "PS", "MC(A)", "MC(B)"}
+	if len(summaries) != len(expected) {
+		t.Fatalf("There should be %d statistics, but there are %d", len(expected), len(summaries))
+	}
+	for i, s := range summaries {
+		if s.Statistic != expected[i] {
+			t.Errorf("Statistic %d should be %s, but is %s", i, expected[i], s.Statistic)
+		}
+		if s.PValue > 0.01 {
+			t.Errorf("P-value of %s should be low for a perfectly clustered trait, but is %f", s.Statistic, s.PValue)
+		}
+	}
+	if summaries[1].Observed != 1 || summaries[2].Observed != 8 {
+		t.Errorf("Observed PS and MC(A) are %f and %f and should be 1 and 8", summaries[1].Observed, summaries[2].Observed)
+	}
+}
+
+func TestAssociationTestError(t *testing.T) {
+	tipstates := map[string]string{"t1": "A", "t2": "A", "t3": "B", "t4": "B"}
+	trees := make(chan tree.Trees)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 3; i++ {
+			// t5 has no state
+			tr, _ := newick.NewParser(strings.NewReader("((t1,t2),(t3,t5));")).Parse()
+			trees <- tree.Trees{Tree: tr, Id: i}
+		}
+		close(trees)
+		done <- true
+	}()
+	if _, err := AssociationTest(trees, tipstates, 10); err == nil {
+		t.Error("A tip without state should give an error")
+	}
+	// The tree channel should have been emptied
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("The tree channel has not been emptied")
+	}
+}
@@ -0,0 +1,348 @@
+package acr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Ancestral value of a continuous character
+type ContinuousState struct {
+	Value    float64 // Maximum likelihood estimate
+	Variance float64 // Variance of the estimate
+	Low      float64 // Lower bound of the 95% confidence interval
+	High     float64 // Upper bound of the 95% confidence interval
+}
+
+// Phylogenetically independent contrast (Felsenstein, 1985)
+type Contrast struct {
+	Node     *tree.Node // Node at which the contrast is computed
+	Value    float64    // Standardized contrast
+	Variance float64    // Expected variance of the raw contrast (sum of corrected branch lengths)
+}
+
+// Result of a continuous ancestral character reconstruction under Brownian motion
+type ContinuousResult struct {
+	Sigma2        float64                    // REML estimate of the Brownian motion rate: mean of squared contrasts
+	LogLikelihood float64                    // Maximum log likelihood of the tip values under Brownian motion
+	Contrasts     []Contrast                 // Phylogenetically independent contrasts, in post-order
+	States        map[string]ContinuousState // Ancestral values of all nodes
+}
+
+// Phylogenetic signal of a continuous character
+type PhylogeneticSignal struct {
+	K                   float64 // Blomberg's K (Blomberg et al., 2003)
+	KPValue             float64 // P-value of the randomization test of K, NaN if not computed
+	Lambda              float64 // Maximum likelihood estimate of Pagel's lambda, in [0,1] (Pagel, 1999)
+	LambdaLogLikelihood float64 // Log likelihood at the estimated lambda
+	NullLogLikelihood   float64 // Log likelihood at lambda=0 (no phylogenetic signal)
+	LambdaPValue        float64 // P-value of the likelihood ratio test of lambda against lambda=0
+}
+
+// Internal structure storing the values computed
+// by the pruning algorithm under Brownian motion
+type bmPruning struct {
+	lengths   []float64 // Length of the branch going to the parent of each node
+	tips      []float64 // Values of the tips
+	lower     []float64 // Estimate of the value of each node given its subtree
+	lowervar  []float64 // Variance factor of lower
+	upper     []float64 // Estimate of the value of each node given the rest of the tree
+	uppervar  []float64 // Variance factor of upper (including the branch to the parent)
+	contrasts []Contrast
+	sumsq     float64 // Sum of squared standardized contrasts
+	logdet    float64 // Sum of the logs of the variances of the contrasts
+	rootvar   float64 // Variance factor of the estimate of the root value
+	tipids    []int   // Ids of the tips
+}
+
+// Reconstructs ancestral values of a continuous character under Brownian motion,
+// using Felsenstein's pruning algorithm (contrasts) followed by a pre-order pass.
+//
+// tipValues: mapping between tip names and values
+//
+// The estimate of each node is its maximum likelihood value given all tip values,
+// its variance is sigma2 times the variance factor of the estimate, sigma2 being
+// the REML estimate of the rate (mean of the squared contrasts), and the 95%
+// confidence interval is value +/- 1.96*sqrt(variance).
+//
+// The tree must have branch lengths. Multifurcations are handled as if they were
+// resolved with branches of length 0. Values of internal nodes are stored as node attributes
+// "value" and "value_95%_CI". Keys of the returned states are the node names, or the node ids
+// in the deep first traversal of the tree for nodes without name.
+func ContinuousAcr(t *tree.Tree, tipValues map[string]float64) (res *ContinuousResult, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var bm *bmPruning
+
+	if bm, err = newBMPruning(t, nodes, tipValues); err != nil {
+		return
+	}
+	bm.pruneTree(t.Root())
+	bm.upper[t.Root().Id()], bm.uppervar[t.Root().Id()] = 0, math.Inf(1)
+	bm.upPass(t.Root(), nil)
+
+	res = &ContinuousResult{
+		LogLikelihood: bm.logLikelihood(),
+		Contrasts:     bm.contrasts,
+		States:        make(map[string]ContinuousState),
+	}
+	if len(bm.contrasts) > 0 {
+		res.Sigma2 = bm.sumsq / float64(len(bm.contrasts))
+	}
+	for _, n := range nodes {
+		value, v := combineBM(bm.lower[n.Id()], bm.lowervar[n.Id()], bm.upper[n.Id()], bm.uppervar[n.Id()])
+		s := ContinuousState{Value: value, Variance: v * res.Sigma2}
+		s.Low = s.Value - 1.959964*math.Sqrt(s.Variance)
+		s.High = s.Value + 1.959964*math.Sqrt(s.Variance)
+		id := fmt.Sprintf("%d", n.Id())
+		if n.Name() != "" {
+			id = n.Name()
+		}
+		res.States[id] = s
+		if !n.Tip() {
+			n.SetAttribute("value", tree.NewFloatAttribute(s.Value))
+			n.SetAttribute("value_95%_CI", tree.NewFloatListAttribute(s.Low, s.High))
+		}
+	}
+	return
+}
+
+// Computes the phylogenetically independent contrasts of a continuous
+// character (Felsenstein, 1985), in post-order. Multifurcations are handled
+// as if they were resolved with branches of length 0, each multifurcation
+// with k children thus giving k-1 contrasts. Contrasts with a null
+// variance (e.g. cherries with branches of length 0) are not computed.
+func IndependentContrasts(t *tree.Tree, tipValues map[string]float64) (contrasts []Contrast, err error) {
+	var bm *bmPruning
+
+	if bm, err = newBMPruning(t, t.Nodes(), tipValues); err != nil {
+		return
+	}
+	bm.pruneTree(t.Root())
+	contrasts = bm.contrasts
+	return
+}
+
+// Measures the phylogenetic signal of a continuous character:
+//   - Blomberg's K: Ratio of the observed mean squared error of the tip values (around their
+//     phylogenetic mean) to the mean squared error given the tree, divided by its expectation
+//     under Brownian motion. If nperm > 0, the p-value of K is computed by shuffling values among
+//     tips nperm times: (1 + number of shuffles with K at least as large) / (1 + nperm);
+//   - Pagel's lambda: Multiplier of internal branch lengths (keeping the root-to-tip distances unchanged)
+//     maximizing the likelihood of the tip values under Brownian motion, estimated in [0,1].
+//     Its p-value is computed with a likelihood ratio test against lambda=0 (chi-square, 1 degree of freedom).
+//
+// The root of the tree is used as the root of the Brownian motion, and the tree must have branch lengths.
+func ContinuousSignal(t *tree.Tree, tipValues map[string]float64, nperm int) (signal PhylogeneticSignal, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var bm *bmPruning
+	var nhigher int
+
+	if bm, err = newBMPruning(t, nodes, tipValues); err != nil {
+		return
+	}
+	if len(bm.tipids) < 3 {
+		err = errors.New("Phylogenetic signal requires at least 3 tips")
+		return
+	}
+
+	// Root-to-tip distances, used for K and for lambda
+	depths := make([]float64, len(nodes))
+	t.PreOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) (keep bool) {
+		if prev != nil {
+			depths[cur.Id()] = depths[prev.Id()] + bm.lengths[cur.Id()]
+		}
+		return true
+	})
+
+	// Blomberg's K
+	signal.K = bm.blombergK(t.Root(), depths)
+	signal.KPValue = math.NaN()
+	if nperm > 0 {
+		tips := t.Tips()
+		values := make([]float64, len(tips))
+		for i, tip := range tips {
+			values[i] = bm.tips[tip.Id()]
+		}
+		for i := 0; i < nperm; i++ {
+			for j, p := range rand.Perm(len(tips)) {
+				bm.tips[tips[j].Id()] = values[p]
+			}
+			if bm.blombergK(t.Root(), depths) >= signal.K {
+				nhigher++
+			}
+		}
+		for i, tip := range tips {
+			bm.tips[tip.Id()] = values[i]
+		}
+		signal.KPValue = float64(nhigher+1) / float64(nperm+1)
+	}
+
+	// Pagel's lambda
+	lengths := bm.lengths
+	bm.lengths = make([]float64, len(lengths))
+	f := func(lambda float64) float64 {
+		for _, n := range nodes {
+			if n.Tip() {
+				bm.lengths[n.Id()] = lambda*lengths[n.Id()] + (1-lambda)*depths[n.Id()]
+			} else {
+				bm.lengths[n.Id()] = lambda * lengths[n.Id()]
+			}
+		}
+		bm.pruneTree(t.Root())
+		return bm.logLikelihood()
+	}
+	gr := (math.Sqrt(5) - 1) / 2
+	a, b := 0.0, 1.0
+	c, d := b-gr*(b-a), a+gr*(b-a)
+	fc, fd := f(c), f(d)
+	for b-a > 1e-6 {
+		if fc > fd {
+			b, d, fd = d, c, fc
+			c = b - gr*(b-a)
+			fc = f(c)
+		} else {
+			a, c, fc = c, d, fd
+			d = a + gr*(b-a)
+			fd = f(d)
+		}
+	}
+	signal.Lambda = (a + b) / 2
+	signal.LambdaLogLikelihood = f(signal.Lambda)
+	// The maximum may be at the bounds
+	for _, lambda := range []float64{0, 1} {
+		if lnl := f(lambda); lnl > signal.LambdaLogLikelihood {
+			signal.Lambda, signal.LambdaLogLikelihood = lambda, lnl
+		}
+	}
+	signal.NullLogLikelihood = f(0)
+	bm.lengths = lengths
+
+	lrt := math.Max(0, 2*(signal.LambdaLogLikelihood-signal.NullLogLikelihood))
+	signal.LambdaPValue = math.Erfc(math.Sqrt(lrt / 2))
+	return
+}
+
+// Initializes the pruning structure: node ids, branch lengths and tip values
+func newBMPruning(t *tree.Tree, nodes []*tree.Node, tipValues map[string]float64) (bm *bmPruning, err error) {
+	bm = &bmPruning{
+		lengths:  make([]float64, len(nodes)),
+		tips:     make([]float64, len(nodes)),
+		lower:    make([]float64, len(nodes)),
+		lowervar: make([]float64, len(nodes)),
+		upper:    make([]float64, len(nodes)),
+		uppervar: make([]float64, len(nodes)),
+	}
+	for i, n := range nodes {
+		n.SetId(i)
+		if n.Tip() {
+			value, ok := tipValues[n.Name()]
+			if !ok {
+				err = fmt.Errorf("Tip %s does not exist in the tip/value mapping file", n.Name())
+				return
+			}
+			bm.tips[i] = value
+			bm.tipids = append(bm.tipids, i)
+		}
+	}
+	for _, e := range t.Edges() {
+		if e.Length() == tree.NIL_LENGTH {
+			err = errors.New("Continuous ACR requires branch lengths")
+			return
+		}
+		bm.lengths[e.Right().Id()] = math.Max(0, e.Length())
+	}
+	return
+}
+
+// First pass: From tips to root. Computes the estimate of each node given
+// its subtree, and the contrasts between the children of each node.
+func (bm *bmPruning) pruneTree(root *tree.Node) {
+	bm.contrasts = nil
+	bm.sumsq, bm.logdet = 0, 0
+	bm.prune(root, nil)
+	bm.rootvar = bm.lowervar[root.Id()]
+}
+
+func (bm *bmPruning) prune(cur, prev *tree.Node) {
+	if cur.Tip() {
+		bm.lower[cur.Id()], bm.lowervar[cur.Id()] = bm.tips[cur.Id()], 0
+		return
+	}
+	value, v := 0.0, math.Inf(1)
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			bm.prune(child, cur)
+			cvalue, cv := bm.lower[child.Id()], bm.lowervar[child.Id()]+bm.lengths[child.Id()]
+			if !math.IsInf(v, 1) && v+cv > 0 {
+				c := Contrast{Node: cur, Value: (value - cvalue) / math.Sqrt(v+cv), Variance: v + cv}
+				bm.contrasts = append(bm.contrasts, c)
+				bm.sumsq += c.Value * c.Value
+				bm.logdet += math.Log(c.Variance)
+			}
+			value, v = combineBM(value, v, cvalue, cv)
+		}
+	}
+	bm.lower[cur.Id()], bm.lowervar[cur.Id()] = value, v
+}
+
+// Second pass: From root to tips. Computes the estimate of each
+// node given the rest of the tree (outside its subtree).
+func (bm *bmPruning) upPass(cur, prev *tree.Node) {
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			value, v := bm.upper[cur.Id()], bm.uppervar[cur.Id()]
+			for _, sister := range cur.Neigh() {
+				if sister != prev && sister != child {
+					value, v = combineBM(value, v, bm.lower[sister.Id()], bm.lowervar[sister.Id()]+bm.lengths[sister.Id()])
+				}
+			}
+			bm.upper[child.Id()], bm.uppervar[child.Id()] = value, v+bm.lengths[child.Id()]
+			bm.upPass(child, cur)
+		}
+	}
+}
+
+// Log likelihood of the tip values under Brownian motion, with the maximum
+// likelihood estimates of the rate and of the root value, computed after prune.
+func (bm *bmPruning) logLikelihood() float64 {
+	n := float64(len(bm.tipids))
+	sigma2 := bm.sumsq / n
+	return -0.5 * (n*math.Log(2*math.Pi*sigma2) + bm.logdet + math.Log(bm.rootvar) + n)
+}
+
+// Blomberg's K, given the root-to-tip distances of the nodes
+func (bm *bmPruning) blombergK(root *tree.Node, depths []float64) float64 {
+	var mse0, trace float64
+	bm.pruneTree(root)
+	mean := bm.lower[root.Id()]
+	for _, id := range bm.tipids {
+		mse0 += (bm.tips[id] - mean) * (bm.tips[id] - mean)
+		trace += depths[id]
+	}
+	n := float64(len(bm.tipids))
+	// MSE0/MSE, both having the same denominator (n-1)
+	observed := mse0 / bm.sumsq
+	expected := (trace - n*bm.rootvar) / (n - 1)
+	return observed / expected
+}
+
+// Combines two independent estimates of the value of a node, given their
+// variance factors (infinite if no information, 0 if the value is known).
+func combineBM(value1, v1, value2, v2 float64) (value, v float64) {
+	switch {
+	case math.IsInf(v1, 1):
+		return value2, v2
+	case math.IsInf(v2, 1):
+		return value1, v1
+	case v1 == 0 && v2 == 0:
+		return (value1 + value2) / 2, 0
+	case v1 == 0:
+		return value1, 0
+	case v2 == 0:
+		return value2, 0
+	}
+	return (value1*v2 + value2*v1) / (v1 + v2), v1 * v2 / (v1 + v2)
+}
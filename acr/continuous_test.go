@@ -0,0 +1,160 @@
+package acr
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+)
+
+// Log likelihood of the values x under Brownian motion with covariance
+// matrix c (ML estimates of the root value and of the rate), GLS mean,
+// (x-mean)'C^-1(x-mean), and 1'C^-1 1, computed by Gaussian elimination.
+func testBMLikelihood(c [][]float64, x []float64) (lnl, mean, quad, oco float64) {
+	n := len(x)
+	// Solves c.y = [1 x]
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = append(append([]float64{}, c[i]...), 1, x[i])
+	}
+	logdet := 0.0
+	for i := 0; i < n; i++ {
+		logdet += math.Log(m[i][i])
+		for j := i + 1; j < n; j++ {
+			f := m[j][i] / m[i][i]
+			for k := i; k < n+2; k++ {
+				m[j][k] -= f * m[i][k]
+			}
+		}
+	}
+	y := make([][2]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		for col := 0; col < 2; col++ {
+			s := m[i][n+col]
+			for k := i + 1; k < n; k++ {
+				s -= m[i][k] * y[k][col]
+			}
+			y[i][col] = s / m[i][i]
+		}
+	}
+	// 1'C^-1 1, 1'C^-1 x, and x'C^-1 x
+	var ocx, xcx float64
+	for i := 0; i < n; i++ {
+		oco += y[i][0]
+		ocx += y[i][1]
+		xcx += x[i] * y[i][1]
+	}
+	mean = ocx / oco
+	quad = xcx - mean*ocx
+	sigma2 := quad / float64(n)
+	lnl = -0.5 * (float64(n)*math.Log(2*math.Pi*sigma2) + logdet + float64(n))
+	return
+}
+
+func TestContinuousAcr(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader("((A:1,B:1)AB:0.5,(C:1,D:1)CD:0.5)root;")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]float64{"A": 1, "B": 3, "C": 5, "D": 9}
+	res, err := ContinuousAcr(tr, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][2]float64{"root": {4.5, 0.5}, "AB": {3.25, 0.375}, "CD": {5.75, 0.375}, "A": {1, 0}}
+	sigma2 := 22.5 / 3
+	if math.Abs(res.Sigma2-sigma2) > 1e-10 {
+		t.Errorf("Sigma2 is %f and should be %f", res.Sigma2, sigma2)
+	}
+	for name, exp := range expected {
+		s := res.States[name]
+		if math.Abs(s.Value-exp[0]) > 1e-10 || math.Abs(s.Variance-exp[1]*sigma2) > 1e-10 {
+			t.Errorf("State of node %s is %f (variance %f) and should be %f (variance %f)", name, s.Value, s.Variance, exp[0], exp[1]*sigma2)
+		}
+		if math.Abs(s.High-s.Value-1.959964*math.Sqrt(s.Variance)) > 1e-10 {
+			t.Errorf("Wrong confidence interval for node %s: [%f,%f]", name, s.Low, s.High)
+		}
+	}
+
+	if len(res.Contrasts) != 3 {
+		t.Fatalf("There should be %d contrasts, not %d", 3, len(res.Contrasts))
+	}
+	expcontrasts := []float64{-2 / math.Sqrt(2), -4 / math.Sqrt(2), -5 / math.Sqrt(2)}
+	for i, c := range res.Contrasts {
+		if math.Abs(c.Value-expcontrasts[i]) > 1e-10 {
+			t.Errorf("Contrast %d is %f and should be %f", i, c.Value, expcontrasts[i])
+		}
+	}
+
+	c := [][]float64{{1.5, 0.5, 0, 0}, {0.5, 1.5, 0, 0}, {0, 0, 1.5, 0.5}, {0, 0, 0.5, 1.5}}
+	lnl, _, _, _ := testBMLikelihood(c, []float64{1, 3, 5, 9})
+	if math.Abs(res.LogLikelihood-lnl) > 1e-10 {
+		t.Errorf("Log likelihood is %f and should be %f", res.LogLikelihood, lnl)
+	}
+
+	if a, ok := tr.Root().Attribute("value"); !ok || a.String() != "4.5" {
+		t.Errorf("Root value attribute is not set correctly")
+	}
+}
+
+func TestContinuousSignal(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader("(((A:1,B:1):0.5,(C:1,D:1):0.5):1,(E:2,F:2):0.5);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"A", "B", "C", "D", "E", "F"}
+	x := []float64{1, 1.5, 4, 5, 9, 10}
+	values := make(map[string]float64)
+	for i, n := range names {
+		values[n] = x[i]
+	}
+	signal, err := ContinuousSignal(tr, values, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Covariance matrix given by shared path lengths from the root
+	c := [][]float64{
+		{2.5, 1.5, 1, 1, 0, 0},
+		{1.5, 2.5, 1, 1, 0, 0},
+		{1, 1, 2.5, 1.5, 0, 0},
+		{1, 1, 1.5, 2.5, 0, 0},
+		{0, 0, 0, 0, 2.5, 0.5},
+		{0, 0, 0, 0, 0.5, 2.5},
+	}
+	lnl1, mean, quad, oco := testBMLikelihood(c, x)
+	mse0, trace := 0.0, 0.0
+	for i := range x {
+		mse0 += (x[i] - mean) * (x[i] - mean)
+		trace += c[i][i]
+	}
+	n := float64(len(x))
+	k := (mse0 / quad) / ((trace - n/oco) / (n - 1))
+	if math.Abs(signal.K-k) > 1e-10 {
+		t.Errorf("Blomberg's K is %f and should be %f", signal.K, k)
+	}
+	if !math.IsNaN(signal.KPValue) {
+		t.Errorf("P-value of K should not be computed without permutations")
+	}
+
+	if signal.Lambda < 0 || signal.Lambda > 1 {
+		t.Errorf("Lambda should be in [0,1], but is %f", signal.Lambda)
+	}
+	if signal.LambdaLogLikelihood < lnl1-1e-10 || signal.LambdaLogLikelihood < signal.NullLogLikelihood-1e-10 {
+		t.Errorf("Log likelihood at lambda=%f (%f) should be at least the log likelihood at lambda=1 (%f) and lambda=0 (%f)",
+			signal.Lambda, signal.LambdaLogLikelihood, lnl1, signal.NullLogLikelihood)
+	}
+
+	// Null log likelihood: star tree with the same root-to-tip distances
+	star := make([][]float64, len(x))
+	for i := range star {
+		star[i] = make([]float64, len(x))
+		star[i][i] = c[i][i]
+	}
+	lnl0, _, _, _ := testBMLikelihood(star, x)
+	if math.Abs(signal.NullLogLikelihood-lnl0) > 1e-10 {
+		t.Errorf("Log likelihood at lambda=0 is %f and should be %f", signal.NullLogLikelihood, lnl0)
+	}
+}
@@ -0,0 +1,455 @@
+package acr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Maximum likelihood methods for selecting ancestral states
+const (
+	ML_MARGINAL = iota // State with the maximum marginal posterior probability
+	ML_JOINT           // States of the most likely joint reconstruction (Pupko et al., 2000)
+	ML_MPPA            // Marginal posterior probabilities approximation (Ishikawa et al., 2019)
+)
+
+// Character evolution models
+const (
+	MODEL_JC  = iota // Mk model: equal state frequencies and equal rates
+	MODEL_F81        // F81-like model: state frequencies estimated from tip states
+)
+
+// Result of a maximum likelihood ancestral character reconstruction
+type MLResult struct {
+	Alphabet      []string                  // Sorted possible states
+	Frequencies   []float64                 // Equilibrium frequencies of the states
+	Rate          float64                   // Rate of the model (given or estimated)
+	LogLikelihood float64                   // Log likelihood of the tip states under the model
+	Probas        map[string]AncestralState // Marginal posterior probabilities of each state for all nodes
+	States        map[string]string         // Selected states of internal nodes (comma separated)
+}
+
+// Internal structure storing the model and the
+// partial likelihoods of an ML ancestral reconstruction
+type mlAcr struct {
+	nstates int
+	freqs   []float64
+	beta    float64          // Normalization factor so that the expected number of changes per unit of time is the rate
+	rate    float64          // Rate of the model
+	tips    []AncestralState // Tip likelihoods (1 for the observed state, 0 otherwise)
+	lower   []AncestralState // Normalized conditional likelihoods of the subtree under each node
+	toup    []AncestralState // Normalized likelihoods of the subtree under each node, given the state of its parent
+	upper   []AncestralState // Normalized likelihoods of the rest of the tree, given the state of each node
+	lengths []float64        // Length of the branch going to the parent of each node
+}
+
+// Will annotate the tree nodes with ancestral characters
+// Computed using maximum likelihood under the Mk (MODEL_JC) or F81-like (MODEL_F81) model.
+// Characters will be located in the comment field of each node
+// at the first index (several states are separated by '|').
+//
+// tipCharacters: mapping between tipnames and character state
+// method: One of ML_MARGINAL, ML_JOINT, and ML_MPPA : returns an error otherwise
+// If ML_MARGINAL, each node is assigned the state with the highest marginal posterior probability,
+// If ML_JOINT, nodes are assigned the states of the most likely joint reconstruction,
+// If ML_MPPA, each node is assigned the set of states minimizing the Brier score of its marginal posterior probabilities.
+// rate: if > 0, rate of the model, otherwise the rate is estimated by maximum likelihood.
+//
+// The tree must have branch lengths. Returns the log likelihood, the rate, the marginal posterior
+// probabilities of all nodes, and the selected states of internal nodes. If a node has a name,
+// key is its name, if a node has no name, the key will be its id in the deep first traversal of the tree.
+func MLAcr(t *tree.Tree, tipCharacters map[string]string, method int, model int, rate float64) (res *MLResult, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var ml *mlAcr
+	var states []AncestralState
+
+	if method != ML_MARGINAL && method != ML_JOINT && method != ML_MPPA {
+		err = fmt.Errorf("Maximum likelihood method %d unkown", method)
+		return
+	}
+	if model != MODEL_JC && model != MODEL_F81 {
+		err = fmt.Errorf("Model %d unkown", model)
+		return
+	}
+
+	// Initialize indices of characters
+	alphabet := make([]string, 0, 10)
+	seenState := make(map[string]bool)
+	for _, state := range tipCharacters {
+		if _, ok := seenState[state]; !ok {
+			alphabet = append(alphabet, state)
+		}
+		seenState[state] = true
+	}
+	sort.Strings(alphabet)
+	stateIndices := AncestralStateIndices(alphabet)
+	if len(alphabet) == 0 {
+		err = errors.New("No tip state given")
+		return
+	}
+
+	ml = &mlAcr{
+		nstates: len(alphabet),
+		freqs:   make([]float64, len(alphabet)),
+		tips:    make([]AncestralState, len(nodes)),
+		lower:   make([]AncestralState, len(nodes)),
+		toup:    make([]AncestralState, len(nodes)),
+		upper:   make([]AncestralState, len(nodes)),
+		lengths: make([]float64, len(nodes)),
+	}
+
+	ntips := 0
+	for i, n := range nodes {
+		n.SetId(i)
+		ml.lower[i] = make(AncestralState, len(alphabet))
+		ml.toup[i] = make(AncestralState, len(alphabet))
+		ml.upper[i] = make(AncestralState, len(alphabet))
+		if n.Tip() {
+			state, ok := tipCharacters[n.Name()]
+			if !ok {
+				err = fmt.Errorf("Tip %s does not exist in the tip/state mapping file", n.Name())
+				return
+			}
+			ml.tips[i] = make(AncestralState, len(alphabet))
+			ml.tips[i][stateIndices[state]] = 1
+			ml.freqs[stateIndices[state]]++
+			ntips++
+		}
+	}
+
+	for _, e := range t.Edges() {
+		if e.Length() == tree.NIL_LENGTH {
+			err = errors.New("Maximum likelihood ACR requires branch lengths")
+			return
+		}
+		ml.lengths[e.Right().Id()] = math.Max(0, e.Length())
+	}
+
+	// State frequencies
+	sumsq := 0.0
+	for i := range ml.freqs {
+		if model == MODEL_JC {
+			ml.freqs[i] = 1.0 / float64(len(alphabet))
+		} else {
+			ml.freqs[i] /= float64(ntips)
+		}
+		sumsq += ml.freqs[i] * ml.freqs[i]
+	}
+	if sumsq < 1 {
+		ml.beta = 1.0 / (1.0 - sumsq)
+	}
+
+	var lnl float64
+	if rate > 0 {
+		ml.rate = rate
+		lnl = ml.logLikelihood(t.Root())
+	} else {
+		lnl = ml.optimizeRate(t)
+	}
+
+	probas := ml.marginals(t.Root())
+	switch method {
+	case ML_MARGINAL:
+		states = selectMarginalStates(probas)
+	case ML_JOINT:
+		states = ml.jointStates(t.Root())
+	case ML_MPPA:
+		states = selectMPPAStates(probas)
+	}
+
+	res = &MLResult{
+		Alphabet:      alphabet,
+		Frequencies:   ml.freqs,
+		Rate:          ml.rate,
+		LogLikelihood: lnl,
+		Probas:        make(map[string]AncestralState),
+		States:        buildInternalNamesToStatesMap(nodes, states, alphabet),
+	}
+	for _, n := range nodes {
+		id := fmt.Sprintf("%d", n.Id())
+		if n.Name() != "" {
+			id = n.Name()
+		}
+		res.Probas[id] = probas[n.Id()]
+	}
+
+	assignStatesToTree(t, states, alphabet)
+	return
+}
+
+// Probability of having state j at the end of a branch of
+// length l, given state i at its start
+func (ml *mlAcr) transition(i, j int, l float64) float64 {
+	e := math.Exp(-ml.beta * ml.rate * l)
+	p := ml.freqs[j] * (1 - e)
+	if i == j {
+		p += e
+	}
+	return p
+}
+
+// Computes the log likelihood of the tip states with the current rate
+// using Felsenstein's pruning algorithm.
+func (ml *mlAcr) logLikelihood(root *tree.Node) float64 {
+	lnl := ml.lowerPass(root, nil)
+	sum := 0.0
+	for i, p := range ml.lower[root.Id()] {
+		sum += ml.freqs[i] * p
+	}
+	return lnl + math.Log(sum)
+}
+
+// First pass of the likelihood computation: From tips to root.
+// Conditional likelihoods are normalized at each node, and
+// the function returns the sum of the logs of the scaling factors.
+func (ml *mlAcr) lowerPass(cur, prev *tree.Node) (logscale float64) {
+	low := ml.lower[cur.Id()]
+	if cur.Tip() {
+		copy(low, ml.tips[cur.Id()])
+	} else {
+		for i := range low {
+			low[i] = 1
+		}
+		for _, child := range cur.Neigh() {
+			if child != prev {
+				logscale += ml.lowerPass(child, cur)
+				toup := ml.toup[child.Id()]
+				for i := range low {
+					low[i] *= toup[i]
+				}
+			}
+		}
+		logscale += normalize(low)
+	}
+
+	// Likelihood of the subtree given the state of the parent
+	if prev != nil {
+		toup := ml.toup[cur.Id()]
+		for i := range toup {
+			toup[i] = 0
+			for j, p := range low {
+				toup[i] += ml.transition(i, j, ml.lengths[cur.Id()]) * p
+			}
+		}
+		logscale += normalize(toup)
+	}
+	return
+}
+
+// Second pass of the likelihood computation: From root to tips.
+// Computes the normalized likelihoods of the rest of the tree given
+// the state of each node, and returns the marginal posterior probabilities
+// of all nodes (indexed by node ids).
+func (ml *mlAcr) marginals(root *tree.Node) (probas []AncestralState) {
+	probas = make([]AncestralState, len(ml.lower))
+	copy(ml.upper[root.Id()], ml.freqs)
+	ml.upperPass(root, nil)
+	for id := range probas {
+		probas[id] = make(AncestralState, ml.nstates)
+		for i := range probas[id] {
+			probas[id][i] = ml.upper[id][i] * ml.lower[id][i]
+		}
+		normalize(probas[id])
+	}
+	return
+}
+
+func (ml *mlAcr) upperPass(cur, prev *tree.Node) {
+	out := make([]float64, ml.nstates)
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			// Likelihood of the tree outside child subtree, given the state of cur
+			copy(out, ml.upper[cur.Id()])
+			for _, sister := range cur.Neigh() {
+				if sister != prev && sister != child {
+					for i := range out {
+						out[i] *= ml.toup[sister.Id()][i]
+					}
+				}
+			}
+			up := ml.upper[child.Id()]
+			for j := range up {
+				up[j] = 0
+				for i, p := range out {
+					up[j] += p * ml.transition(i, j, ml.lengths[child.Id()])
+				}
+			}
+			normalize(up)
+			ml.upperPass(child, cur)
+		}
+	}
+}
+
+// Joint reconstruction of the ancestral states (Pupko et al., 2000)
+func (ml *mlAcr) jointStates(root *tree.Node) (states []AncestralState) {
+	var best int
+	maxlnl := math.Inf(-1)
+	states = make([]AncestralState, len(ml.lower))
+	joint := make([][]float64, len(ml.lower)) // Log likelihood of the best reconstruction of subtrees given the state of their parent
+	choice := make([][]int, len(ml.lower))    // Best state of each node given the state of its parent
+
+	sub := ml.jointLowerPass(root, nil, joint, choice)
+	for j, l := range sub {
+		if l += math.Log(ml.freqs[j]); l > maxlnl {
+			maxlnl = l
+			best = j
+		}
+	}
+	for id := range states {
+		states[id] = make(AncestralState, ml.nstates)
+	}
+	ml.jointUpperPass(root, nil, best, choice, states)
+	return
+}
+
+// Computes for the subtree rooted at cur the log likelihood of its best reconstruction
+// given each state of cur, and stores for cur the best choice given each state of prev.
+func (ml *mlAcr) jointLowerPass(cur, prev *tree.Node, joint [][]float64, choice [][]int) (sub []float64) {
+	sub = make([]float64, ml.nstates)
+	if cur.Tip() {
+		for j, p := range ml.tips[cur.Id()] {
+			sub[j] = math.Log(p)
+		}
+	} else {
+		for _, child := range cur.Neigh() {
+			if child != prev {
+				ml.jointLowerPass(child, cur, joint, choice)
+				for j := range sub {
+					sub[j] += joint[child.Id()][j]
+				}
+			}
+		}
+	}
+	if prev != nil {
+		joint[cur.Id()] = make([]float64, ml.nstates)
+		choice[cur.Id()] = make([]int, ml.nstates)
+		for i := range joint[cur.Id()] {
+			joint[cur.Id()][i] = math.Inf(-1)
+			for j, l := range sub {
+				if l += math.Log(ml.transition(i, j, ml.lengths[cur.Id()])); l > joint[cur.Id()][i] {
+					joint[cur.Id()][i] = l
+					choice[cur.Id()][i] = j
+				}
+			}
+		}
+	}
+	return
+}
+
+func (ml *mlAcr) jointUpperPass(cur, prev *tree.Node, state int, choice [][]int, states []AncestralState) {
+	states[cur.Id()][state] = 1
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			ml.jointUpperPass(child, cur, choice[child.Id()][state], choice, states)
+		}
+	}
+}
+
+// Estimates the rate maximizing the likelihood, using a golden section
+// search on the log of the rate. Returns the maximum log likelihood.
+func (ml *mlAcr) optimizeRate(t *tree.Tree) float64 {
+	sumlen, nlen := 0.0, 0
+	for _, e := range t.Edges() {
+		if e.Length() > 0 {
+			sumlen += e.Length()
+			nlen++
+		}
+	}
+	if nlen == 0 {
+		ml.rate = 1
+		return ml.logLikelihood(t.Root())
+	}
+	avglen := sumlen / float64(nlen)
+
+	f := func(x float64) float64 {
+		ml.rate = math.Exp(x)
+		return ml.logLikelihood(t.Root())
+	}
+	gr := (math.Sqrt(5) - 1) / 2
+	a, b := math.Log(1e-3/avglen), math.Log(1e2/avglen)
+	c, d := b-gr*(b-a), a+gr*(b-a)
+	fc, fd := f(c), f(d)
+	for b-a > 1e-6 {
+		if fc > fd {
+			b, d, fd = d, c, fc
+			c = b - gr*(b-a)
+			fc = f(c)
+		} else {
+			a, c, fc = c, d, fd
+			d = a + gr*(b-a)
+			fd = f(d)
+		}
+	}
+	return f((a + b) / 2)
+}
+
+// Selects for each node the state having the highest
+// marginal posterior probability.
+func selectMarginalStates(probas []AncestralState) (states []AncestralState) {
+	states = make([]AncestralState, len(probas))
+	for id, p := range probas {
+		states[id] = make(AncestralState, len(p))
+		best := 0
+		for i, v := range p {
+			if v > p[best] {
+				best = i
+			}
+		}
+		states[id][best] = 1
+	}
+	return
+}
+
+// Selects for each node the set of k states with the highest marginal
+// posterior probabilities, k minimizing the Brier score between
+// the marginal probabilities and a uniform distribution over the k states
+// (MPPA, Ishikawa et al., 2019).
+func selectMPPAStates(probas []AncestralState) (states []AncestralState) {
+	states = make([]AncestralState, len(probas))
+	for id, p := range probas {
+		order := make([]int, len(p))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool { return p[order[i]] > p[order[j]] })
+
+		bestk, bestscore := 1, math.Inf(1)
+		for k := 1; k <= len(p); k++ {
+			score := 0.0
+			for r, i := range order {
+				if r < k {
+					score += (1.0/float64(k) - p[i]) * (1.0/float64(k) - p[i])
+				} else {
+					score += p[i] * p[i]
+				}
+			}
+			if score < bestscore-1e-12 {
+				bestk, bestscore = k, score
+			}
+		}
+		states[id] = make(AncestralState, len(p))
+		for _, i := range order[:bestk] {
+			states[id][i] = 1
+		}
+	}
+	return
+}
+
+// Divides the values of the vector by their sum,
+// and returns the log of the sum
+func normalize(v []float64) float64 {
+	sum := 0.0
+	for _, x := range v {
+		sum += x
+	}
+	if sum <= 0 {
+		return math.Inf(-1)
+	}
+	for i := range v {
+		v[i] /= sum
+	}
+	return math.Log(sum)
+}
@@ -0,0 +1,112 @@
+package acr
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+)
+
+// Brute force likelihood of the tree ((a:0.2,b:0.3)n1:0.1,c:0.4,d:0.5)n0;
+// with states a=A, b=A, c=B, d=C, under the F81 model with the given rate.
+// Returns the likelihood and the joint probabilities of the states of (n0,n1)
+func testBruteForceLikelihood(rate float64) (lk float64, joint [3][3]float64) {
+	freqs := []float64{0.5, 0.25, 0.25}
+	beta := 1.0 / (1.0 - 0.25 - 0.0625 - 0.0625)
+	p := func(i, j int, l float64) float64 {
+		e := math.Exp(-beta * rate * l)
+		v := freqs[j] * (1 - e)
+		if i == j {
+			v += e
+		}
+		return v
+	}
+	for s0 := 0; s0 < 3; s0++ {
+		for s1 := 0; s1 < 3; s1++ {
+			joint[s0][s1] = freqs[s0] * p(s0, s1, 0.1) * p(s1, 0, 0.2) * p(s1, 0, 0.3) * p(s0, 1, 0.4) * p(s0, 2, 0.5)
+			lk += joint[s0][s1]
+		}
+	}
+	return
+}
+
+func TestMLAcr(t *testing.T) {
+	treeString := "((a:0.2,b:0.3)n1:0.1,c:0.4,d:0.5)n0;"
+	tipstates := map[string]string{"a": "A", "b": "A", "c": "B", "d": "C"}
+	rate := 1.5
+
+	lk, joint := testBruteForceLikelihood(rate)
+	for _, method := range []int{ML_MARGINAL, ML_JOINT, ML_MPPA} {
+		tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := MLAcr(tr, tipstates, method, MODEL_F81, rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(res.LogLikelihood-math.Log(lk)) > 1e-9 {
+			t.Errorf("Log likelihood is %f and should be %f", res.LogLikelihood, math.Log(lk))
+		}
+
+		// Marginal posterior probabilities
+		for s := 0; s < 3; s++ {
+			p0 := (joint[s][0] + joint[s][1] + joint[s][2]) / lk
+			p1 := (joint[0][s] + joint[1][s] + joint[2][s]) / lk
+			if math.Abs(res.Probas["n0"][s]-p0) > 1e-9 {
+				t.Errorf("Marginal probability of state %s at n0 is %f and should be %f", res.Alphabet[s], res.Probas["n0"][s], p0)
+			}
+			if math.Abs(res.Probas["n1"][s]-p1) > 1e-9 {
+				t.Errorf("Marginal probability of state %s at n1 is %f and should be %f", res.Alphabet[s], res.Probas["n1"][s], p1)
+			}
+		}
+		if res.Probas["c"][1] != 1 {
+			t.Errorf("Marginal probability of state B at tip c should be 1")
+		}
+
+		switch method {
+		case ML_JOINT:
+			best0, best1 := 0, 0
+			for s0 := 0; s0 < 3; s0++ {
+				for s1 := 0; s1 < 3; s1++ {
+					if joint[s0][s1] > joint[best0][best1] {
+						best0, best1 = s0, s1
+					}
+				}
+			}
+			testCheckMap(t, "n0", res.States, res.Alphabet[best0])
+			testCheckMap(t, "n1", res.States, res.Alphabet[best1])
+		case ML_MARGINAL:
+			testCheckMap(t, "n1", res.States, "A")
+		case ML_MPPA:
+			testCheckMap(t, "n1", res.States, "A")
+		}
+	}
+}
+
+func TestMLAcrRate(t *testing.T) {
+	treeString := "((a:0.2,b:0.3)n1:0.1,c:0.4,d:0.5)n0;"
+	tipstates := map[string]string{"a": "A", "b": "A", "c": "B", "d": "C"}
+
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := MLAcr(tr, tipstates, ML_MPPA, MODEL_F81, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rate := range []float64{0.1, 0.5, 1, 2, 5, 10} {
+		lk, _ := testBruteForceLikelihood(rate)
+		if math.Log(lk) > res.LogLikelihood+1e-9 {
+			t.Errorf("Log likelihood with rate %f (%f) is higher than the one with estimated rate %f (%f)",
+				rate, math.Log(lk), res.Rate, res.LogLikelihood)
+		}
+	}
+
+	tr, _ = newick.NewParser(strings.NewReader("((a,b)n1,c,d)n0;")).Parse()
+	if _, err = MLAcr(tr, tipstates, ML_MPPA, MODEL_F81, -1); err == nil {
+		t.Errorf("ML ACR should fail on a tree without branch lengths")
+	}
+}
@@ -15,6 +15,8 @@ const (
 	ALGO_ACCTRAN
 	ALGO_DOWNPASS
 	ALGO_NONE
+	ALGO_ML      // Maximum likelihood (see MLAcr)
+	ALGO_SANKOFF // Sankoff parsimony with a cost matrix (see SankoffAcr)
 )
 
 // Will annotate the tree nodes with ancestral characters
@@ -32,10 +34,53 @@ const (
 // if randomResolve is true, then in the second pass, each ambiguities will be resolved randomly
 func ParsimonyAcr(t *tree.Tree, tipCharacters map[string]string, algo int, randomResolve bool) (nametostates map[string]string, nsteps int, err error) {
 	var nodes []*tree.Node = t.Nodes()
-	var states []AncestralState = make([]AncestralState, len(nodes))   // Downside states of each node
+	var states []AncestralState
+	var alphabet []string
+
+	for i, n := range nodes {
+		n.SetId(i)
+	}
+	if states, alphabet, nsteps, err = parsimonyStates(t, nodes, tipCharacters, algo, randomResolve); err != nil {
+		return
+	}
+	nametostates = buildInternalNamesToStatesMap(nodes, states, alphabet)
+	assignStatesToTree(t, states, alphabet)
+	return
+}
+
+// Reconstructs ancestral characters of several traits using parsimony on the same tree.
+// tipCharacters: one mapping between tipnames and character state per trait
+// Algo and randomResolve: see ParsimonyAcr
+// Returns, for each trait, a map with the states of all internal nodes (see ParsimonyAcr)
+// and the number of parsimony steps. Contrary to ParsimonyAcr, the states are not
+// written in the comment field of the nodes, which is left unchanged.
+func ParsimonyAcrTraits(t *tree.Tree, tipCharacters []map[string]string, algo int, randomResolve bool) (nametostates []map[string]string, nsteps []int, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var states []AncestralState
+	var alphabet []string
+
+	for i, n := range nodes {
+		n.SetId(i)
+	}
+	nametostates = make([]map[string]string, len(tipCharacters))
+	nsteps = make([]int, len(tipCharacters))
+	for i, characters := range tipCharacters {
+		if states, alphabet, nsteps[i], err = parsimonyStates(t, nodes, characters, algo, randomResolve); err != nil {
+			return
+		}
+		nametostates[i] = buildInternalNamesToStatesMap(nodes, states, alphabet)
+	}
+	return
+}
+
+// Computes the parsimony states of all nodes for one character, with the given algorithm
+// (see ParsimonyAcr). Node ids must be their indices in nodes.
+// Returns the states of all nodes, the (sorted) alphabet, and the number of parsimony steps.
+func parsimonyStates(t *tree.Tree, nodes []*tree.Node, tipCharacters map[string]string, algo int, randomResolve bool) (states []AncestralState, alphabet []string, nsteps int, err error) {
 	var upstates []AncestralState = make([]AncestralState, len(nodes)) // Upside states of each  node
+	states = make([]AncestralState, len(nodes))                        // Downside states of each node
 	// Initialize indices of characters
-	alphabet := make([]string, 0, 10)
+	alphabet = make([]string, 0, 10)
 	seenState := make(map[string]bool)
 	for _, state := range tipCharacters {
 		if _, ok := seenState[state]; !ok {
@@ -47,8 +92,7 @@ func ParsimonyAcr(t *tree.Tree, tipCharacters map[string]string, algo int, rando
 	stateIndices := AncestralStateIndices(alphabet)
 
 	// We initialize all ancestral states
-	for i, n := range nodes {
-		n.SetId(i)
+	for i := range nodes {
 		states[i] = make(AncestralState, len(alphabet))
 		upstates[i] = make(AncestralState, len(alphabet))
 	}
@@ -72,9 +116,6 @@ func ParsimonyAcr(t *tree.Tree, tipCharacters map[string]string, algo int, rando
 		err = fmt.Errorf("Parsimony algorithm %d unkown", algo)
 		return
 	}
-
-	nametostates = buildInternalNamesToStatesMap(t, states, alphabet)
-	assignStatesToTree(t, states, alphabet)
 	return
 }
 
@@ -380,11 +421,11 @@ func assignStatesToTree(t *tree.Tree, states []AncestralState, alphabet []string
 }
 
 // Returns a map with keys: Internal nodes identifier (id or name if any), and value: list of possible states, comma separated
-func buildInternalNamesToStatesMap(t *tree.Tree, states []AncestralState, alphabet []string) map[string]string {
+func buildInternalNamesToStatesMap(nodes []*tree.Node, states []AncestralState, alphabet []string) map[string]string {
 	outmap := make(map[string]string)
 	st := make([]string, 0, 10)
 
-	for _, n := range t.Nodes() {
+	for _, n := range nodes {
 		if !n.Tip() {
 			nb := 0
 			st = st[:0]
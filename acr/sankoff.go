@@ -0,0 +1,210 @@
+package acr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Will annotate the tree nodes with ancestral characters
+// Computed using Sankoff (weighted) parsimony
+// Characters will be located in the comment field of each node
+// at the first index
+// tipCharacters: mapping between tipnames and character state
+// costStates: states of the cost matrix (all possible states)
+// costs: costs[i][j] is the cost of a change from costStates[i] (parent) to costStates[j] (child).
+// It may be asymmetric, and may contain +Inf for forbidden changes.
+// Returns a map with the states of all internal nodes. If a node has a name, key is its name, if a node has no name,
+// the key will be its id in the deep first traversal of the tree. States of a node are all states
+// found in at least one most parsimonious reconstruction.
+// Also returns the minimum cost of the reconstruction.
+// if randomResolve is true, then one most parsimonious reconstruction is chosen randomly.
+func SankoffAcr(t *tree.Tree, tipCharacters map[string]string, costStates []string, costs [][]float64, randomResolve bool) (nametostates map[string]string, cost float64, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var alphabet []string
+	var c [][]float64
+	var down []AncestralState = make([]AncestralState, len(nodes))   // Minimum cost of the subtree of each node given its state
+	var toup []AncestralState = make([]AncestralState, len(nodes))   // Minimum cost of the subtree of each node (and its branch) given the state of its parent
+	var states []AncestralState = make([]AncestralState, len(nodes)) // Reconstructed states
+
+	if alphabet, c, err = checkCostMatrix(costStates, costs); err != nil {
+		return
+	}
+	stateIndices := AncestralStateIndices(alphabet)
+
+	for i, n := range nodes {
+		n.SetId(i)
+		down[i] = make(AncestralState, len(alphabet))
+		toup[i] = make(AncestralState, len(alphabet))
+		states[i] = make(AncestralState, len(alphabet))
+	}
+
+	if err = sankoffUPPASS(t.Root(), nil, tipCharacters, stateIndices, c, down, toup); err != nil {
+		return
+	}
+
+	cost = math.Inf(1)
+	for _, v := range down[t.Root().Id()] {
+		cost = math.Min(cost, v)
+	}
+	if math.IsInf(cost, 1) {
+		err = errors.New("No reconstruction with a finite cost exists")
+		return
+	}
+
+	if randomResolve {
+		sankoffRandomDOWNPASS(t.Root(), nil, -1, c, down, states)
+	} else {
+		up := make([]AncestralState, len(nodes))
+		for i := range up {
+			up[i] = make(AncestralState, len(alphabet))
+		}
+		sankoffDOWNPASS(t.Root(), nil, c, toup, up)
+		for i := range states {
+			for k := range states[i] {
+				if down[i][k]+up[i][k] <= cost+1e-9 {
+					states[i][k] = 1
+				}
+			}
+		}
+	}
+
+	nametostates = buildInternalNamesToStatesMap(nodes, states, alphabet)
+	assignStatesToTree(t, states, alphabet)
+	return
+}
+
+// Checks that the cost matrix is square, has the same size as the list of states,
+// and that states are unique. Returns the sorted states and the corresponding cost matrix.
+func checkCostMatrix(costStates []string, costs [][]float64) (alphabet []string, c [][]float64, err error) {
+	if len(costStates) == 0 {
+		err = errors.New("Cost matrix should not be empty")
+		return
+	}
+	if len(costs) != len(costStates) {
+		err = fmt.Errorf("Cost matrix has %d rows while %d states are given", len(costs), len(costStates))
+		return
+	}
+	alphabet = make([]string, len(costStates))
+	copy(alphabet, costStates)
+	sort.Strings(alphabet)
+	stateIndices := AncestralStateIndices(alphabet)
+	if len(stateIndices) != len(alphabet) {
+		err = errors.New("States of the cost matrix should be unique")
+		return
+	}
+	c = make([][]float64, len(alphabet))
+	for i := range c {
+		c[i] = make([]float64, len(alphabet))
+	}
+	for i, row := range costs {
+		if len(row) != len(costs) {
+			err = fmt.Errorf("Cost matrix is not square: row %d has %d columns", i, len(row))
+			return
+		}
+		for j, v := range row {
+			if math.IsNaN(v) || v < 0 {
+				err = fmt.Errorf("Cost from %s to %s should be positive: %f", costStates[i], costStates[j], v)
+				return
+			}
+			c[stateIndices[costStates[i]]][stateIndices[costStates[j]]] = v
+		}
+	}
+	return
+}
+
+// First step of the Sankoff parsimony: From tips to root
+// computes the minimum cost of the subtree of each node given its state
+func sankoffUPPASS(cur, prev *tree.Node, tipCharacters map[string]string, stateIndices map[string]int, c [][]float64, down, toup []AncestralState) (err error) {
+	if cur.Tip() {
+		state, ok := tipCharacters[cur.Name()]
+		if !ok {
+			return fmt.Errorf("Tip %s does not exist in the tip/state mapping file", cur.Name())
+		}
+		stateindex, ok := stateIndices[state]
+		if !ok {
+			return fmt.Errorf("State %s does not exist in the cost matrix", state)
+		}
+		for k := range down[cur.Id()] {
+			if k != stateindex {
+				down[cur.Id()][k] = math.Inf(1)
+			}
+		}
+	} else {
+		for _, child := range cur.Neigh() {
+			if child != prev {
+				if err = sankoffUPPASS(child, cur, tipCharacters, stateIndices, c, down, toup); err != nil {
+					return
+				}
+				for k, v := range toup[child.Id()] {
+					down[cur.Id()][k] += v
+				}
+			}
+		}
+	}
+
+	if prev != nil {
+		for k := range toup[cur.Id()] {
+			toup[cur.Id()][k] = math.Inf(1)
+			for l, v := range down[cur.Id()] {
+				toup[cur.Id()][k] = math.Min(toup[cur.Id()][k], c[k][l]+v)
+			}
+		}
+	}
+	return
+}
+
+// Second step of the Sankoff parsimony: From root to tips
+// computes the minimum cost of the rest of the tree given the state of each node
+func sankoffDOWNPASS(cur, prev *tree.Node, c [][]float64, toup, up []AncestralState) {
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			for l := range up[child.Id()] {
+				up[child.Id()][l] = math.Inf(1)
+			}
+			for k := range up[cur.Id()] {
+				// Cost of the rest of the tree, except the child subtree, given state k of cur
+				out := up[cur.Id()][k]
+				for _, sister := range cur.Neigh() {
+					if sister != prev && sister != child {
+						out += toup[sister.Id()][k]
+					}
+				}
+				for l := range up[child.Id()] {
+					up[child.Id()][l] = math.Min(up[child.Id()][l], out+c[k][l])
+				}
+			}
+			sankoffDOWNPASS(child, cur, c, toup, up)
+		}
+	}
+}
+
+// Second step of the Sankoff parsimony, if ambiguities are resolved randomly:
+// From root to tips, chooses randomly one of the best states of each node
+// given the state of its parent
+func sankoffRandomDOWNPASS(cur, prev *tree.Node, prevstate int, c [][]float64, down, states []AncestralState) {
+	var best []int
+	min := math.Inf(1)
+	for l, v := range down[cur.Id()] {
+		if prevstate >= 0 {
+			v += c[prevstate][l]
+		}
+		if v < min-1e-9 {
+			min = v
+			best = []int{l}
+		} else if v <= min+1e-9 {
+			best = append(best, l)
+		}
+	}
+	state := best[rand.Intn(len(best))]
+	states[cur.Id()][state] = 1
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			sankoffRandomDOWNPASS(child, cur, state, c, down, states)
+		}
+	}
+}
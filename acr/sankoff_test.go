@@ -0,0 +1,116 @@
+package acr
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+)
+
+func TestSankoffEqualCosts(t *testing.T) {
+	treeString := "(t1,(t2,((t3,(t4,t5)t6)t7,(t8,((t9,t10)t11,((t12,t13)t14,t15)t16)t17)t18)t19)t20)t21;"
+	tipstates := map[string]string{
+		"t1": "A", "t2": "A", "t3": "B", "t4": "B", "t5": "A", "t8": "B",
+		"t9": "B", "t10": "A", "t12": "A", "t13": "A", "t15": "A",
+	}
+	costs := [][]float64{{0, 1}, {1, 0}}
+
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statemap, cost, err := SankoffAcr(tr, tipstates, []string{"B", "A"}, costs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != 4 {
+		t.Errorf("Sankoff cost is %f and should be %d", cost, 4)
+	}
+
+	// With equal costs, states are the same as Fitch DOWNPASS
+	tr2, _ := newick.NewParser(strings.NewReader(treeString)).Parse()
+	fitchmap, _, err := ParsimonyAcr(tr2, tipstates, ALGO_DOWNPASS, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range fitchmap {
+		testCheckMap(t, k, statemap, strings.Split(v, ",")...)
+	}
+}
+
+func TestSankoffIrreversible(t *testing.T) {
+	// Changes from B to A are forbidden
+	treeString := "((t1,t2)n1,(t3,(t4,t5)n3)n2)n0;"
+	tipstates := map[string]string{"t1": "A", "t2": "B", "t3": "B", "t4": "B", "t5": "A"}
+	costs := [][]float64{{0, 2}, {math.Inf(1), 0}}
+
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statemap, cost, err := SankoffAcr(tr, tipstates, []string{"A", "B"}, costs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != 6 {
+		t.Errorf("Sankoff cost is %f and should be %d", cost, 6)
+	}
+	testCheckMap(t, "n0", statemap, "A")
+	testCheckMap(t, "n1", statemap, "A")
+	testCheckMap(t, "n2", statemap, "A")
+	testCheckMap(t, "n3", statemap, "A")
+
+	for i := 0; i < 10; i++ {
+		tr, _ = newick.NewParser(strings.NewReader(treeString)).Parse()
+		statemap, cost, err = SankoffAcr(tr, tipstates, []string{"A", "B"}, costs, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cost != 6 {
+			t.Errorf("Sankoff cost is %f and should be %d", cost, 6)
+		}
+		testCheckMap(t, "n2", statemap, "A")
+	}
+
+	// All changes are forbidden while tip states differ: no finite reconstruction
+	tr, _ = newick.NewParser(strings.NewReader("((t1,t2)n1,t3)n0;")).Parse()
+	if _, _, err = SankoffAcr(tr, map[string]string{"t1": "A", "t2": "B", "t3": "B"}, []string{"A", "B"}, [][]float64{{0, math.Inf(1)}, {math.Inf(1), 0}}, false); err == nil {
+		t.Errorf("Sankoff ACR should fail when no reconstruction has a finite cost")
+	}
+}
+
+func TestSankoffRandomResolve(t *testing.T) {
+	treeString := "(t1,(t2,((t3,(t4,t5)t6)t7,(t8,((t9,t10)t11,((t12,t13)t14,t15)t16)t17)t18)t19)t20)t21;"
+	tipstates := map[string]string{
+		"t1": "A", "t2": "A", "t3": "B", "t4": "B", "t5": "A", "t8": "B",
+		"t9": "B", "t10": "A", "t12": "A", "t13": "A", "t15": "A",
+	}
+	costs := [][]float64{{0, 1}, {1, 0}}
+
+	for i := 0; i < 10; i++ {
+		tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		statemap, cost, err := SankoffAcr(tr, tipstates, []string{"A", "B"}, costs, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Count changes of the chosen reconstruction
+		nchanges := 0
+		for _, e := range tr.Edges() {
+			if e.Left().Comments()[0] != e.Right().Comments()[0] {
+				nchanges++
+			}
+		}
+		if float64(nchanges) != cost {
+			t.Errorf("Random reconstruction has %d changes while the cost is %f", nchanges, cost)
+		}
+		for k, v := range statemap {
+			if strings.Contains(v, ",") {
+				t.Errorf("Node %s should have a single state, but has %s", k, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package acr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Builds the rate matrix of the Mk model with k states: all changes
+// have the same rate, such that the expected number of changes per unit
+// of branch length is rate (same parameterization as MLAcr with MODEL_JC).
+func MkRates(nstates int, rate float64) (rates [][]float64) {
+	rates = make([][]float64, nstates)
+	for i := range rates {
+		rates[i] = make([]float64, nstates)
+		for j := range rates[i] {
+			if i != j && nstates > 1 {
+				rates[i][j] = rate / float64(nstates-1)
+			}
+		}
+	}
+	return
+}
+
+// Simulates the evolution of a discrete character along the tree, under a continuous
+// time Markov model: rates[i][j] is the instantaneous rate of change from alphabet[i]
+// to alphabet[j] (diagonal is ignored). Changes are simulated along each branch
+// by drawing exponential waiting times.
+//
+// rootState: State of the root, or "" to draw it uniformly at random.
+//
+// The tree must have branch lengths. Returns the states of all nodes: If a node has a name,
+// key is its name, if a node has no name, the key will be its id in the deep first traversal of the tree.
+func SimulateDiscrete(t *tree.Tree, alphabet []string, rates [][]float64, rootState string) (states map[string]string, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var nodestates []int = make([]int, len(nodes))
+	var lengths []float64
+	var outrates []float64 = make([]float64, len(alphabet))
+
+	if len(alphabet) == 0 {
+		err = errors.New("No state given")
+		return
+	}
+	if len(rates) != len(alphabet) {
+		err = fmt.Errorf("Rate matrix has %d rows, but there are %d states", len(rates), len(alphabet))
+		return
+	}
+	for i, r := range rates {
+		if len(r) != len(alphabet) {
+			err = fmt.Errorf("Rate matrix row %d has %d columns, but there are %d states", i, len(r), len(alphabet))
+			return
+		}
+		for j, v := range r {
+			if i != j {
+				if v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+					err = fmt.Errorf("Rate from %s to %s must be a positive number", alphabet[i], alphabet[j])
+					return
+				}
+				outrates[i] += v
+			}
+		}
+	}
+	if lengths, err = simulationLengths(t, nodes); err != nil {
+		return
+	}
+
+	root := rand.Intn(len(alphabet))
+	if rootState != "" {
+		idx, ok := AncestralStateIndices(alphabet)[rootState]
+		if !ok {
+			err = fmt.Errorf("Root state %s does not exist in the alphabet", rootState)
+			return
+		}
+		root = idx
+	}
+
+	t.PreOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) (keep bool) {
+		if prev == nil {
+			nodestates[cur.Id()] = root
+			return true
+		}
+		state := nodestates[prev.Id()]
+		remaining := lengths[cur.Id()]
+		for outrates[state] > 0 {
+			remaining -= rand.ExpFloat64() / outrates[state]
+			if remaining < 0 {
+				break
+			}
+			// Chooses the new state proportionally to the rates
+			r := rand.Float64() * outrates[state]
+			next := state
+			for j, v := range rates[state] {
+				if j != state && v > 0 {
+					next = j
+					if r < v {
+						break
+					}
+					r -= v
+				}
+			}
+			state = next
+		}
+		nodestates[cur.Id()] = state
+		return true
+	})
+
+	states = make(map[string]string, len(nodes))
+	for _, n := range nodes {
+		states[simulationKey(n)] = alphabet[nodestates[n.Id()]]
+	}
+	return
+}
+
+// Simulates the evolution of a continuous character along the tree, starting from
+// rootValue at the root, under:
+//   - Brownian motion with rate sigma2 if alpha == 0: the value at the end of a
+//     branch of length l is drawn from N(x, sigma2*l), x being the value at its start;
+//   - Ornstein-Uhlenbeck process with rate sigma2, strength of selection alpha, and optimum
+//     theta if alpha > 0: the value is drawn from N(theta+(x-theta)*exp(-alpha*l), sigma2/(2*alpha)*(1-exp(-2*alpha*l))).
+//
+// The tree must have branch lengths. Returns the values of all nodes, with the same keys as SimulateDiscrete.
+func SimulateContinuous(t *tree.Tree, rootValue, sigma2, alpha, theta float64) (values map[string]float64, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var nodevalues []float64 = make([]float64, len(nodes))
+	var lengths []float64
+
+	if sigma2 < 0 {
+		err = errors.New("Rate (sigma2) must be >= 0")
+		return
+	}
+	if alpha < 0 {
+		err = errors.New("Strength of selection (alpha) must be >= 0")
+		return
+	}
+	if lengths, err = simulationLengths(t, nodes); err != nil {
+		return
+	}
+
+	t.PreOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) (keep bool) {
+		if prev == nil {
+			nodevalues[cur.Id()] = rootValue
+			return true
+		}
+		x, l := nodevalues[prev.Id()], lengths[cur.Id()]
+		if alpha == 0 {
+			nodevalues[cur.Id()] = x + rand.NormFloat64()*math.Sqrt(sigma2*l)
+		} else {
+			mean := theta + (x-theta)*math.Exp(-alpha*l)
+			variance := sigma2 / (2 * alpha) * (1 - math.Exp(-2*alpha*l))
+			nodevalues[cur.Id()] = mean + rand.NormFloat64()*math.Sqrt(variance)
+		}
+		return true
+	})
+
+	values = make(map[string]float64, len(nodes))
+	for _, n := range nodes {
+		values[simulationKey(n)] = nodevalues[n.Id()]
+	}
+	return
+}
+
+// Sets the node ids, and returns the length of the branch
+// going to the parent of each node
+func simulationLengths(t *tree.Tree, nodes []*tree.Node) (lengths []float64, err error) {
+	lengths = make([]float64, len(nodes))
+	for i, n := range nodes {
+		n.SetId(i)
+	}
+	for _, e := range t.Edges() {
+		if e.Length() == tree.NIL_LENGTH {
+			err = errors.New("Trait simulation requires branch lengths")
+			return
+		}
+		lengths[e.Right().Id()] = math.Max(0, e.Length())
+	}
+	return
+}
+
+// Key of a node in the simulation outputs: Its name, or its id if it has no name
+func simulationKey(n *tree.Node) string {
+	if n.Name() != "" {
+		return n.Name()
+	}
+	return fmt.Sprintf("%d", n.Id())
+}
@@ -0,0 +1,103 @@
+package acr
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Star tree with n tips named t0..tn-1, and branches of length l
+func testStarTree(t *testing.T, n int, l float64) *tree.Tree {
+	tips := make([]string, n)
+	for i := range tips {
+		tips[i] = fmt.Sprintf("t%d:%f", i, l)
+	}
+	tr, err := newick.NewParser(strings.NewReader("(" + strings.Join(tips, ",") + ")root;")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestSimulateDiscrete(t *testing.T) {
+	rand.Seed(10)
+	n := 4000
+	tr := testStarTree(t, n, 0.5)
+	states, err := SimulateDiscrete(tr, []string{"A", "B"}, MkRates(2, 1), "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if states["root"] != "A" {
+		t.Errorf("Root state is %s and should be %s", states["root"], "A")
+	}
+	// Probability of change along a branch of length 0.5
+	expected := (1 - math.Exp(-2*0.5)) / 2
+	changes := 0
+	for i := 0; i < n; i++ {
+		if states[fmt.Sprintf("t%d", i)] != "A" {
+			changes++
+		}
+	}
+	if math.Abs(float64(changes)/float64(n)-expected) > 0.03 {
+		t.Errorf("Proportion of changes is %f and should be close to %f", float64(changes)/float64(n), expected)
+	}
+
+	// Irreversible model: B never changes
+	states, err = SimulateDiscrete(tr, []string{"A", "B"}, [][]float64{{0, 1}, {0, 0}}, "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, s := range states {
+		if s != "B" {
+			t.Errorf("State of node %s is %s and should be %s", k, s, "B")
+		}
+	}
+
+	if _, err = SimulateDiscrete(tr, []string{"A", "B"}, MkRates(2, 1), "C"); err == nil {
+		t.Errorf("Unknown root state should return an error")
+	}
+}
+
+func TestSimulateContinuous(t *testing.T) {
+	rand.Seed(10)
+	n := 4000
+	tr := testStarTree(t, n, 2)
+
+	// Brownian motion: tip values are drawn from N(1, 0.5*2)
+	values, err := SimulateContinuous(tr, 1, 0.5, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mean, variance := testMeanVariance(values, n)
+	if math.Abs(mean-1) > 0.1 || math.Abs(variance-1) > 0.1 {
+		t.Errorf("Mean and variance of BM tip values are %f and %f and should be close to %f and %f", mean, variance, 1.0, 1.0)
+	}
+
+	// Ornstein-Uhlenbeck with a strong selection: tip values are close to the stationary distribution N(5, 0.5/(2*10))
+	values, err = SimulateContinuous(tr, 1, 0.5, 10, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mean, variance = testMeanVariance(values, n)
+	if math.Abs(mean-5) > 0.05 || math.Abs(variance-0.025) > 0.005 {
+		t.Errorf("Mean and variance of OU tip values are %f and %f and should be close to %f and %f", mean, variance, 5.0, 0.025)
+	}
+}
+
+func testMeanVariance(values map[string]float64, n int) (mean, variance float64) {
+	for i := 0; i < n; i++ {
+		mean += values[fmt.Sprintf("t%d", i)]
+	}
+	mean /= float64(n)
+	for i := 0; i < n; i++ {
+		v := values[fmt.Sprintf("t%d", i)]
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(n - 1)
+	return
+}
@@ -0,0 +1,123 @@
+package acr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Homoplasy statistics of a character (or of a set of characters)
+// reconstructed using Fitch parsimony
+type ParsimonyStats struct {
+	Steps    int // Number of parsimony steps on the tree (s)
+	MinSteps int // Minimum possible number of steps on any tree (m): number of states - 1
+	MaxSteps int // Maximum possible number of steps on any tree (g): steps on a star tree
+}
+
+// Computes the homoplasy statistics of a character, given its number of parsimony
+// steps on the tree and the number of tips having each state.
+func NewParsimonyStats(steps int, counts []int) ParsimonyStats {
+	nstates, ntips, max := 0, 0, 0
+	for _, c := range counts {
+		if c > 0 {
+			nstates++
+		}
+		if c > max {
+			max = c
+		}
+		ntips += c
+	}
+	minsteps := 0
+	if nstates > 0 {
+		minsteps = nstates - 1
+	}
+	return ParsimonyStats{Steps: steps, MinSteps: minsteps, MaxSteps: ntips - max}
+}
+
+// Ensemble statistics of a set of characters:
+// Steps, minimum and maximum steps are summed over all characters.
+func SumParsimonyStats(stats []ParsimonyStats) (sum ParsimonyStats) {
+	for _, s := range stats {
+		sum.Steps += s.Steps
+		sum.MinSteps += s.MinSteps
+		sum.MaxSteps += s.MaxSteps
+	}
+	return
+}
+
+// Consistency index: m/s (Kluge & Farris, 1969).
+// NaN if the character has no step.
+func (s ParsimonyStats) CI() float64 {
+	if s.Steps == 0 {
+		return math.NaN()
+	}
+	return float64(s.MinSteps) / float64(s.Steps)
+}
+
+// Retention index: (g-s)/(g-m) (Farris, 1989).
+// NaN if g == m (e.g. uninformative characters).
+func (s ParsimonyStats) RI() float64 {
+	if s.MaxSteps == s.MinSteps {
+		return math.NaN()
+	}
+	return float64(s.MaxSteps-s.Steps) / float64(s.MaxSteps-s.MinSteps)
+}
+
+// Rescaled consistency index: CI*RI
+func (s ParsimonyStats) RC() float64 {
+	return s.CI() * s.RI()
+}
+
+// Computes the homoplasy statistics of a character, given the mapping between
+// tip names and states, and its number of parsimony steps on the tree
+// (as returned by ParsimonyAcr).
+func CharacterParsimonyStats(t *tree.Tree, tipCharacters map[string]string, nsteps int) (stats ParsimonyStats, err error) {
+	counts := make(map[string]int)
+	for _, tip := range t.Tips() {
+		state, ok := tipCharacters[tip.Name()]
+		if !ok {
+			err = fmt.Errorf("Tip %s does not exist in the tip/state mapping file", tip.Name())
+			return
+		}
+		counts[state]++
+	}
+	c := make([]int, 0, len(counts))
+	for _, v := range counts {
+		c = append(c, v)
+	}
+	stats = NewParsimonyStats(nsteps, c)
+	return
+}
+
+// Tests the phylogenetic association of a character using a permutation test:
+// The tip names of a copy of the tree are shuffled nperm times (see tree.ShuffleTips),
+// and the number of parsimony steps on each shuffled tree is compared to
+// the number of steps on the original tree.
+//
+// Returns the number of steps on the original tree, and the p-value:
+// (1 + number of shuffled trees with at most as many steps) / (1 + nperm).
+func ParsimonyPermutationTest(t *tree.Tree, tipCharacters map[string]string, nperm int) (nsteps int, pvalue float64, err error) {
+	var permsteps, nlower int
+
+	if nperm <= 0 {
+		err = errors.New("Number of permutations should be > 0")
+		return
+	}
+	shuffled := t.Clone()
+	if _, nsteps, err = ParsimonyAcr(shuffled, tipCharacters, ALGO_NONE, false); err != nil {
+		return
+	}
+	for i := 0; i < nperm; i++ {
+		shuffled.ShuffleTips()
+		if _, permsteps, err = ParsimonyAcr(shuffled, tipCharacters, ALGO_NONE, false); err != nil {
+			return
+		}
+		if permsteps <= nsteps {
+			nlower++
+		}
+	}
+	pvalue = float64(nlower+1) / float64(nperm+1)
+	return
+}
@@ -0,0 +1,83 @@
+package acr
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+)
+
+func TestParsimonyStats(t *testing.T) {
+	s := NewParsimonyStats(4, []int{7, 4})
+	if s.MinSteps != 1 || s.MaxSteps != 4 {
+		t.Errorf("Min and max steps are %d and %d and should be %d and %d", s.MinSteps, s.MaxSteps, 1, 4)
+	}
+	if s.CI() != 0.25 || s.RI() != 0 || s.RC() != 0 {
+		t.Errorf("CI, RI and RC are %f, %f and %f and should be %f, %f and %f", s.CI(), s.RI(), s.RC(), 0.25, 0.0, 0.0)
+	}
+
+	s2 := NewParsimonyStats(2, []int{2, 3, 6})
+	if s2.MinSteps != 2 || s2.MaxSteps != 5 {
+		t.Errorf("Min and max steps are %d and %d and should be %d and %d", s2.MinSteps, s2.MaxSteps, 2, 5)
+	}
+	if s2.CI() != 1 || s2.RI() != 1 || s2.RC() != 1 {
+		t.Errorf("CI, RI and RC are %f, %f and %f and should be 1", s2.CI(), s2.RI(), s2.RC())
+	}
+
+	sum := SumParsimonyStats([]ParsimonyStats{s, s2})
+	if sum.Steps != 6 || sum.MinSteps != 3 || sum.MaxSteps != 9 {
+		t.Errorf("Sum of stats is %v and should be {6 3 9}", sum)
+	}
+	if math.Abs(sum.RI()-0.5) > 1e-10 {
+		t.Errorf("Ensemble RI is %f and should be %f", sum.RI(), 0.5)
+	}
+
+	constant := NewParsimonyStats(0, []int{10})
+	if !math.IsNaN(constant.CI()) || !math.IsNaN(constant.RI()) {
+		t.Errorf("CI and RI of a constant character should be undefined")
+	}
+}
+
+func TestParsimonyPermutationTest(t *testing.T) {
+	rand.Seed(10)
+	// Balanced tree with 16 tips: the first 8 tips are A, the other 8 are B
+	treeString := "((((t1,t2),(t3,t4)),((t5,t6),(t7,t8))),(((t9,t10),(t11,t12)),((t13,t14),(t15,t16))));"
+	tipstates := make(map[string]string)
+	for i := 1; i <= 16; i++ {
+		tipstates[fmt.Sprintf("t%d", i)] = "A"
+		if i > 8 {
+			tipstates[fmt.Sprintf("t%d", i)] = "B"
+		}
+	}
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.ReinitIndexes()
+
+	nsteps, pvalue, err := ParsimonyPermutationTest(tr, tipstates, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nsteps != 1 {
+		t.Errorf("Number of steps is %d and should be %d", nsteps, 1)
+	}
+	if pvalue > 0.01 {
+		t.Errorf("P-value of a perfectly clustered trait should be low, but is %f", pvalue)
+	}
+	// The input tree should not be modified
+	if tr.Newick() != treeString {
+		t.Errorf("Input tree has been modified: %s", tr.Newick())
+	}
+
+	stats, err := CharacterParsimonyStats(tr, tipstates, nsteps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.CI() != 1 || stats.RI() != 1 {
+		t.Errorf("CI and RI are %f and %f and should be 1", stats.CI(), stats.RI())
+	}
+}
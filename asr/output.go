@@ -0,0 +1,143 @@
+package asr
+
+import (
+	"fmt"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// A substitution on a branch of the tree, at a given site
+// of the alignment.
+type Substitution struct {
+	Parent      *tree.Node // Parent node of the branch
+	Child       *tree.Node // Child node of the branch
+	Site        int        // Index of the site in the alignment (starting at 0)
+	ParentState string     // Possible states of the parent node (several characters if ambiguous)
+	ChildState  string     // Possible states of the child node (several characters if ambiguous)
+	Ambiguous   bool       // true if the parent or the child has several possible states
+}
+
+// IUPAC codes of sets of nucleotides, indexed by bitmask (A=1, C=2, G=4, T=8)
+var iupacNucleotides = []rune{'-', 'A', 'C', 'M', 'G', 'R', 'S', 'V', 'T', 'W', 'Y', 'H', 'K', 'D', 'B', 'N'}
+
+// Name of the node in the outputs: its name if it has one,
+// its id otherwise.
+func nodeName(n *tree.Node) string {
+	if n.Name() != "" {
+		return n.Name()
+	}
+	return fmt.Sprintf("%d", n.Id())
+}
+
+// Returns the possible characters of the state
+func (s AncestralState) characters(alphabet []rune) (chars []rune) {
+	for i, c := range s.counts {
+		if c > 0 {
+			chars = append(chars, alphabet[i])
+		}
+	}
+	return
+}
+
+// Returns a single character representing the state:
+//	* The character itself if the state is not ambiguous;
+//	* The IUPAC code of the possible nucleotides if the alignment is nucleotidic;
+//	* 'X' otherwise ('N' for nucleotides if all characters are possible).
+func (s AncestralState) character(alphabet []rune, alphabetType int) rune {
+	chars := s.characters(alphabet)
+	if len(chars) == 1 {
+		return chars[0]
+	}
+	if alphabetType == align.NUCLEOTIDS {
+		if len(chars) == 0 {
+			return 'N'
+		}
+		mask := 0
+		for _, c := range chars {
+			switch c {
+			case 'A':
+				mask |= 1
+			case 'C':
+				mask |= 2
+			case 'G':
+				mask |= 4
+			case 'T', 'U':
+				mask |= 8
+			default:
+				return 'N'
+			}
+		}
+		return iupacNucleotides[mask]
+	}
+	return 'X'
+}
+
+// Builds an alignment of the ancestral sequences of internal nodes
+// (and of tips if withTips is true), returned by ParsimonyAsrSequences.
+//
+// Sequences are named after the nodes, or after their ids if they have no name.
+// Ambiguous states are written using IUPAC codes for nucleotides, and 'X' for amino acids.
+func AncestralAlignment(t *tree.Tree, seqs []*AncestralSequence, a align.Alignment, withTips bool) (anc align.Alignment, err error) {
+	alphabet := a.AlphabetCharacters()
+	anc = align.NewAlign(a.Alphabet())
+	for _, n := range t.Nodes() {
+		if n.Tip() && !withTips {
+			continue
+		}
+		seq := make([]rune, len(seqs[n.Id()].seq))
+		for i, s := range seqs[n.Id()].seq {
+			seq[i] = s.character(alphabet, a.Alphabet())
+		}
+		if err = anc.AddSequence(nodeName(n), string(seq), ""); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Lists the substitutions on each branch of the tree, given the ancestral
+// sequences returned by ParsimonyAsrSequences.
+//
+// A substitution is reported at a site of a branch if the possible states of the parent
+// and of the child are different. Sites where any state is possible (e.g. gaps) are ignored.
+// If the parent or the child has several possible states, the substitution is flagged as ambiguous.
+// Substitutions are given in the pre-order traversal of the tree, and then by site.
+func Substitutions(t *tree.Tree, seqs []*AncestralSequence, a align.Alignment) (subs []Substitution) {
+	alphabet := a.AlphabetCharacters()
+	subs = make([]Substitution, 0)
+	t.PreOrder(func(cur, prev *tree.Node, e *tree.Edge) (keep bool) {
+		if prev == nil {
+			return true
+		}
+		for i, s := range seqs[cur.Id()].seq {
+			ps := seqs[prev.Id()].seq[i].characters(alphabet)
+			cs := s.characters(alphabet)
+			if len(ps) == 0 || len(cs) == 0 || string(ps) == string(cs) {
+				continue
+			}
+			subs = append(subs, Substitution{
+				Parent:      prev,
+				Child:       cur,
+				Site:        i,
+				ParentState: string(ps),
+				ChildState:  string(cs),
+				Ambiguous:   len(ps) > 1 || len(cs) > 1,
+			})
+		}
+		return true
+	})
+	return
+}
+
+// Name of the parent node of the substitution: its name if it has one,
+// its id otherwise.
+func (s Substitution) ParentName() string {
+	return nodeName(s.Parent)
+}
+
+// Name of the child node of the substitution: its name if it has one,
+// its id otherwise.
+func (s Substitution) ChildName() string {
+	return nodeName(s.Child)
+}
@@ -15,6 +15,7 @@ const (
 	ALGO_ACCTRAN
 	ALGO_DOWNPASS
 	ALGO_NONE
+	ALGO_SANKOFF // Sankoff parsimony with a cost matrix (see SankoffAsrSequences)
 )
 
 // Will annotate the tree nodes with ancestral sequences
@@ -22,8 +23,22 @@ const (
 // Sequences will be located in the comment field of each node
 // at the first index
 func ParsimonyAsr(t *tree.Tree, a align.Alignment, algo int, randomResolve bool) (nsteps []int, err error) {
+	var seqs []*AncestralSequence
+
+	if seqs, nsteps, err = ParsimonyAsrSequences(t, a, algo, randomResolve); err != nil {
+		return
+	}
+	AssignSequencesToTree(t, seqs, a)
+	return
+}
+
+// Reconstructs ancestral sequences using parsimony, without annotating the tree.
+// Returns the ancestral sequences of all nodes, indexed by node ids (set during
+// the reconstruction), and the number of parsimony steps of each site.
+// See AncestralAlignment and Substitutions to use the returned sequences.
+func ParsimonyAsrSequences(t *tree.Tree, a align.Alignment, algo int, randomResolve bool) (seqs []*AncestralSequence, nsteps []int, err error) {
 	var nodes []*tree.Node = t.Nodes()
-	var seqs []*AncestralSequence = make([]*AncestralSequence, len(nodes))
+	seqs = make([]*AncestralSequence, len(nodes))
 	var upseqs []*AncestralSequence = make([]*AncestralSequence, len(nodes)) // Upside seqs of each  node
 
 	// Initialize indices of characters
@@ -39,10 +54,10 @@ func ParsimonyAsr(t *tree.Tree, a align.Alignment, algo int, randomResolve bool)
 	for i, n := range nodes {
 		n.SetId(i)
 		if seqs[i], err = NewAncestralSequence(a.Length(), len(a.AlphabetCharacters())); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		if upseqs[i], err = NewAncestralSequence(a.Length(), len(a.AlphabetCharacters())); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
@@ -63,8 +78,6 @@ func ParsimonyAsr(t *tree.Tree, a align.Alignment, algo int, randomResolve bool)
 		err = fmt.Errorf("Parsimony algorithm %d unkown", algo)
 		return
 	}
-
-	assignSequencesToTree(t, seqs, a.AlphabetCharacters())
 	return
 }
 
@@ -350,9 +363,13 @@ func randomlyResolveNodeStates(node *tree.Node, seqs []*AncestralSequence) {
 	}
 }
 
-func assignSequencesToTree(t *tree.Tree, seqs []*AncestralSequence, alphabet []rune) {
+// Annotates the tree nodes with the ancestral sequences returned by ParsimonyAsrSequences.
+// Sequences will be located in the comment field of each node
+// at the first index. Ambiguous states are written between '{}'.
+func AssignSequencesToTree(t *tree.Tree, seqs []*AncestralSequence, a align.Alignment) {
 	var buffer bytes.Buffer
 	var subbuffer bytes.Buffer
+	var alphabet []rune = a.AlphabetCharacters()
 
 	for _, n := range t.Nodes() {
 		buffer.Reset()
@@ -0,0 +1,261 @@
+package asr
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Will annotate the tree nodes with ancestral sequences
+// Computed using Sankoff (weighted) parsimony
+// Sequences will be located in the comment field of each node
+// at the first index
+// See SankoffAsrSequences for the parameters.
+func SankoffAsr(t *tree.Tree, a align.Alignment, costStates []string, costs [][]float64, randomResolve bool) (sitecosts []float64, err error) {
+	var seqs []*AncestralSequence
+
+	if seqs, sitecosts, err = SankoffAsrSequences(t, a, costStates, costs, randomResolve); err != nil {
+		return
+	}
+	AssignSequencesToTree(t, seqs, a)
+	return
+}
+
+// Reconstructs ancestral sequences using Sankoff (weighted) parsimony, without annotating the tree.
+//
+// costStates: characters of the cost matrix, which must contain all characters of the alphabet of the alignment;
+// costs: costs[i][j] is the cost of a change from costStates[i] (parent) to costStates[j] (child).
+// It may be asymmetric, and may contain +Inf for forbidden changes.
+// Tip characters that are not in the alphabet (e.g. gaps) are considered as unknown.
+//
+// Returns the ancestral sequences of all nodes, indexed by node ids (set during
+// the reconstruction), and the minimum cost of each site. States of a node are all states
+// found in at least one most parsimonious reconstruction of the site.
+// If randomResolve is true, then one most parsimonious reconstruction is chosen randomly.
+func SankoffAsrSequences(t *tree.Tree, a align.Alignment, costStates []string, costs [][]float64, randomResolve bool) (seqs []*AncestralSequence, sitecosts []float64, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var c [][]float64
+	var alphabet []rune = a.AlphabetCharacters()
+	var down []*AncestralSequence = make([]*AncestralSequence, len(nodes)) // Minimum cost of the subtree of each node given its state
+	var toup []*AncestralSequence = make([]*AncestralSequence, len(nodes)) // Minimum cost of the subtree of each node given the state of its parent
+
+	// Initialize indices of characters
+	var charToIndex map[rune]int = make(map[rune]int)
+	for i, ch := range alphabet {
+		charToIndex[ch] = i
+	}
+
+	if c, err = alphabetCostMatrix(charToIndex, costStates, costs); err != nil {
+		return
+	}
+
+	seqs = make([]*AncestralSequence, len(nodes))
+	for i, n := range nodes {
+		n.SetId(i)
+		if seqs[i], err = NewAncestralSequence(a.Length(), len(alphabet)); err != nil {
+			return nil, nil, err
+		}
+		if down[i], err = NewAncestralSequence(a.Length(), len(alphabet)); err != nil {
+			return nil, nil, err
+		}
+		if toup[i], err = NewAncestralSequence(a.Length(), len(alphabet)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err = sankoffUPPASS(t.Root(), nil, a, c, charToIndex, down, toup); err != nil {
+		return
+	}
+
+	sitecosts = make([]float64, a.Length())
+	for j, state := range down[t.Root().Id()].seq {
+		sitecosts[j] = math.Inf(1)
+		for _, v := range state.counts {
+			sitecosts[j] = math.Min(sitecosts[j], v)
+		}
+		if math.IsInf(sitecosts[j], 1) {
+			err = fmt.Errorf("No reconstruction with a finite cost exists for site %d", j+1)
+			return
+		}
+	}
+
+	if randomResolve {
+		for j := range sitecosts {
+			sankoffRandomDOWNPASS(t.Root(), nil, j, -1, c, down, seqs)
+		}
+	} else {
+		up := make([]*AncestralSequence, len(nodes))
+		for i := range up {
+			if up[i], err = NewAncestralSequence(a.Length(), len(alphabet)); err != nil {
+				return nil, nil, err
+			}
+		}
+		sankoffDOWNPASS(t.Root(), nil, c, toup, up)
+		for i, seq := range seqs {
+			for j, state := range seq.seq {
+				for k := range state.counts {
+					if down[i].seq[j].counts[k]+up[i].seq[j].counts[k] <= sitecosts[j]+1e-9 {
+						state.counts[k] = 1
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// Builds the cost matrix indexed by the characters of the alphabet.
+func alphabetCostMatrix(charToIndex map[rune]int, costStates []string, costs [][]float64) (c [][]float64, err error) {
+	var seen map[int]bool = make(map[int]bool)
+	var indices []int = make([]int, len(costStates))
+
+	if len(costs) != len(costStates) {
+		err = fmt.Errorf("Cost matrix has %d rows while %d states are given", len(costs), len(costStates))
+		return
+	}
+	for i, s := range costStates {
+		r := []rune(strings.ToUpper(s))
+		if len(r) != 1 {
+			err = fmt.Errorf("States of the cost matrix should be single characters: %s", s)
+			return
+		}
+		idx, ok := charToIndex[r[0]]
+		if !ok {
+			err = fmt.Errorf("Character %s of the cost matrix does not exist in the alphabet", s)
+			return
+		}
+		if seen[idx] {
+			err = fmt.Errorf("Character %s is present several times in the cost matrix", s)
+			return
+		}
+		seen[idx] = true
+		indices[i] = idx
+	}
+	if len(seen) != len(charToIndex) {
+		err = fmt.Errorf("Cost matrix should contain all %d characters of the alphabet", len(charToIndex))
+		return
+	}
+
+	c = make([][]float64, len(costStates))
+	for i := range c {
+		c[i] = make([]float64, len(costStates))
+	}
+	for i, row := range costs {
+		if len(row) != len(costs) {
+			err = fmt.Errorf("Cost matrix is not square: row %d has %d columns", i, len(row))
+			return
+		}
+		for j, v := range row {
+			if math.IsNaN(v) || v < 0 {
+				err = fmt.Errorf("Cost from %s to %s should be positive: %f", costStates[i], costStates[j], v)
+				return
+			}
+			c[indices[i]][indices[j]] = v
+		}
+	}
+	return
+}
+
+// First step of the Sankoff parsimony: From tips to root
+// computes the minimum cost of the subtree of each node given its state, for each site
+func sankoffUPPASS(cur, prev *tree.Node, a align.Alignment, c [][]float64, charToIndex map[rune]int, down, toup []*AncestralSequence) (err error) {
+	if cur.Tip() {
+		seq, ok := a.GetSequenceChar(cur.Name())
+		if !ok {
+			err = fmt.Errorf("Sequence %s does not exist in the alignment", cur.Name())
+			return
+		}
+		for j, ch := range seq {
+			// Unknown characters: all states are possible
+			if charindex, ok := charToIndex[ch]; ok {
+				for k := range down[cur.Id()].seq[j].counts {
+					if k != charindex {
+						down[cur.Id()].seq[j].counts[k] = math.Inf(1)
+					}
+				}
+			}
+		}
+	} else {
+		for _, child := range cur.Neigh() {
+			if child != prev {
+				if err = sankoffUPPASS(child, cur, a, c, charToIndex, down, toup); err != nil {
+					return
+				}
+				for j, state := range toup[child.Id()].seq {
+					for k, v := range state.counts {
+						down[cur.Id()].seq[j].counts[k] += v
+					}
+				}
+			}
+		}
+	}
+
+	if prev != nil {
+		for j, state := range toup[cur.Id()].seq {
+			for k := range state.counts {
+				state.counts[k] = math.Inf(1)
+				for l, v := range down[cur.Id()].seq[j].counts {
+					state.counts[k] = math.Min(state.counts[k], c[k][l]+v)
+				}
+			}
+		}
+	}
+	return
+}
+
+// Second step of the Sankoff parsimony: From root to tips
+// computes the minimum cost of the rest of the tree given the state of each node, for each site
+func sankoffDOWNPASS(cur, prev *tree.Node, c [][]float64, toup, up []*AncestralSequence) {
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			for j, state := range up[child.Id()].seq {
+				for l := range state.counts {
+					state.counts[l] = math.Inf(1)
+				}
+				for k, v := range up[cur.Id()].seq[j].counts {
+					// Cost of the rest of the tree, except the child subtree, given state k of cur
+					out := v
+					for _, sister := range cur.Neigh() {
+						if sister != prev && sister != child {
+							out += toup[sister.Id()].seq[j].counts[k]
+						}
+					}
+					for l := range state.counts {
+						state.counts[l] = math.Min(state.counts[l], out+c[k][l])
+					}
+				}
+			}
+			sankoffDOWNPASS(child, cur, c, toup, up)
+		}
+	}
+}
+
+// Second step of the Sankoff parsimony, if ambiguities are resolved randomly:
+// From root to tips, chooses randomly one of the best states of each node at the
+// given site, given the state of its parent
+func sankoffRandomDOWNPASS(cur, prev *tree.Node, site int, prevstate int, c [][]float64, down, seqs []*AncestralSequence) {
+	var best []int
+	min := math.Inf(1)
+	for l, v := range down[cur.Id()].seq[site].counts {
+		if prevstate >= 0 {
+			v += c[prevstate][l]
+		}
+		if v < min-1e-9 {
+			min = v
+			best = []int{l}
+		} else if v <= min+1e-9 {
+			best = append(best, l)
+		}
+	}
+	state := best[rand.Intn(len(best))]
+	seqs[cur.Id()].seq[site].counts[state] = 1
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			sankoffRandomDOWNPASS(child, cur, site, state, c, down, seqs)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package asr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Sequence evolution models
+const (
+	MODEL_JC  = iota // Jukes-Cantor: equal frequencies and equal rates (nucleotides or amino acids)
+	MODEL_K80        // Kimura 2-parameters: equal frequencies, transition/transversion ratio
+	MODEL_HKY        // Hasegawa-Kishino-Yano: K80 with unequal frequencies
+	MODEL_GTR        // General time reversible
+)
+
+// Characters of simulated sequences, in the order of the frequencies
+const (
+	simulationNucleotides = "ACGT"
+	simulationAminoAcids  = "ARNDCQEGHILKMFPSTWYV"
+)
+
+// Parameters of a sequence evolution model
+type SimulationModel struct {
+	Model       int       // One of MODEL_JC, MODEL_K80, MODEL_HKY, and MODEL_GTR
+	Alphabet    int       // align.NUCLEOTIDS, or align.AMINOACIDS (only with MODEL_JC)
+	Kappa       float64   // Transition/transversion rate ratio (MODEL_K80 and MODEL_HKY)
+	Rates       []float64 // Relative rates of GTR, in the order AC, AG, AT, CG, CT, GT (MODEL_GTR)
+	Frequencies []float64 // Equilibrium frequencies of A, C, G, T (MODEL_HKY and MODEL_GTR only), equal if nil
+	Alpha       float64   // Shape of the gamma distribution of site rates, no rate heterogeneity if <= 0
+	PInv        float64   // Proportion of invariant sites
+}
+
+// Eigen decomposition of a reversible rate matrix,
+// used to compute transition probabilities
+type simulationMatrix struct {
+	freqs  []float64
+	values []float64   // Eigen values of the symmetrized rate matrix
+	vecs   [][]float64 // Eigen vectors (columns) of the symmetrized rate matrix
+}
+
+// Simulates the evolution of sequences of the given length along the tree,
+// under the given model. The root sequence is drawn from the equilibrium frequencies,
+// and branch lengths are in expected number of substitutions per site.
+//
+// If Alpha > 0, the rate of each site is drawn from a gamma distribution with mean 1 and
+// shape Alpha (continuous gamma), and a proportion PInv of the sites are invariant (rate 0),
+// the rates of the other sites being scaled so that the mean rate is 1.
+//
+// Returns the alignment of tip sequences, and the alignment of the sequences
+// of internal nodes. Sequences are named after the nodes, or after their ids if
+// they have no name.
+func SimulateSequences(t *tree.Tree, length int, m SimulationModel) (tips, ancestral align.Alignment, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var chars string
+	var mat *simulationMatrix
+	var lengths []float64 = make([]float64, len(nodes))
+	var seqs [][]int = make([][]int, len(nodes))
+	var siterates []float64 = make([]float64, length)
+
+	if length <= 0 {
+		err = errors.New("Sequence length must be > 0")
+		return
+	}
+	if m.PInv < 0 || m.PInv >= 1 {
+		err = errors.New("Proportion of invariant sites must be in [0,1[")
+		return
+	}
+	if chars, mat, err = newSimulationMatrix(m); err != nil {
+		return
+	}
+
+	for i, n := range nodes {
+		n.SetId(i)
+	}
+	for _, e := range t.Edges() {
+		if e.Length() == tree.NIL_LENGTH {
+			err = errors.New("Sequence simulation requires branch lengths")
+			return
+		}
+		lengths[e.Right().Id()] = math.Max(0, e.Length())
+	}
+
+	for i := range siterates {
+		if m.PInv > 0 && rand.Float64() < m.PInv {
+			siterates[i] = 0
+			continue
+		}
+		siterates[i] = 1.0
+		if m.Alpha > 0 {
+			siterates[i] = randGamma(m.Alpha) / m.Alpha
+		}
+		siterates[i] /= (1 - m.PInv)
+	}
+
+	probas := make([]float64, len(chars))
+	t.PreOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) (keep bool) {
+		seq := make([]int, length)
+		if prev == nil {
+			for i := range seq {
+				seq[i] = drawState(mat.freqs)
+			}
+		} else {
+			parent := seqs[prev.Id()]
+			for i := range seq {
+				if siterates[i] == 0 || lengths[cur.Id()] == 0 {
+					seq[i] = parent[i]
+				} else {
+					mat.transitions(parent[i], siterates[i]*lengths[cur.Id()], probas)
+					seq[i] = drawState(probas)
+				}
+			}
+		}
+		seqs[cur.Id()] = seq
+		return true
+	})
+
+	tips = align.NewAlign(m.Alphabet)
+	ancestral = align.NewAlign(m.Alphabet)
+	for _, n := range nodes {
+		seq := make([]byte, length)
+		for i, s := range seqs[n.Id()] {
+			seq[i] = chars[s]
+		}
+		if n.Tip() {
+			err = tips.AddSequence(nodeName(n), string(seq), "")
+		} else {
+			err = ancestral.AddSequence(nodeName(n), string(seq), "")
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Builds the normalized rate matrix of the model (one substitution per unit of time),
+// and computes the eigen decomposition of its symmetrized version:
+// B = Pi^1/2 Q Pi^-1/2, with B_ij = R_ij * sqrt(pi_i * pi_j).
+func newSimulationMatrix(m SimulationModel) (chars string, mat *simulationMatrix, err error) {
+	var n int
+
+	switch m.Alphabet {
+	case align.NUCLEOTIDS:
+		chars = simulationNucleotides
+	case align.AMINOACIDS:
+		if m.Model != MODEL_JC {
+			err = errors.New("Only the JC model is supported for amino acid sequences")
+			return
+		}
+		chars = simulationAminoAcids
+	default:
+		err = fmt.Errorf("Unknown alphabet %d", m.Alphabet)
+		return
+	}
+	n = len(chars)
+
+	// Exchangeabilities
+	exch := make([][]float64, n)
+	for i := range exch {
+		exch[i] = make([]float64, n)
+		for j := range exch[i] {
+			exch[i][j] = 1
+		}
+	}
+	switch m.Model {
+	case MODEL_JC:
+	case MODEL_K80, MODEL_HKY:
+		if m.Kappa <= 0 {
+			err = errors.New("Transition/transversion ratio (kappa) must be > 0")
+			return
+		}
+		// Transitions: A<->G and C<->T
+		exch[0][2], exch[2][0], exch[1][3], exch[3][1] = m.Kappa, m.Kappa, m.Kappa, m.Kappa
+	case MODEL_GTR:
+		if len(m.Rates) != 6 {
+			err = fmt.Errorf("GTR model requires 6 relative rates, %d given", len(m.Rates))
+			return
+		}
+		k := 0
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				if m.Rates[k] < 0 {
+					err = errors.New("GTR relative rates must be >= 0")
+					return
+				}
+				exch[i][j], exch[j][i] = m.Rates[k], m.Rates[k]
+				k++
+			}
+		}
+	default:
+		err = fmt.Errorf("Unknown sequence evolution model %d", m.Model)
+		return
+	}
+
+	// Frequencies
+	if m.Frequencies != nil && (m.Model == MODEL_JC || m.Model == MODEL_K80) {
+		err = errors.New("Frequencies cannot be given with the JC and K80 models (equal frequencies)")
+		return
+	}
+	mat = &simulationMatrix{freqs: make([]float64, n)}
+	if m.Frequencies == nil {
+		for i := range mat.freqs {
+			mat.freqs[i] = 1.0 / float64(n)
+		}
+	} else {
+		if len(m.Frequencies) != n {
+			err = fmt.Errorf("%d frequencies given, %d expected", len(m.Frequencies), n)
+			return
+		}
+		sum := 0.0
+		for _, f := range m.Frequencies {
+			if f <= 0 {
+				err = errors.New("Frequencies must be > 0")
+				return
+			}
+			sum += f
+		}
+		for i, f := range m.Frequencies {
+			mat.freqs[i] = f / sum
+		}
+	}
+
+	// Symmetrized and normalized rate matrix
+	b := make([][]float64, n)
+	total := 0.0
+	for i := range b {
+		b[i] = make([]float64, n)
+		for j := range b[i] {
+			if i != j {
+				b[i][j] = exch[i][j] * math.Sqrt(mat.freqs[i]*mat.freqs[j])
+				b[i][i] -= exch[i][j] * mat.freqs[j]
+				total += mat.freqs[i] * exch[i][j] * mat.freqs[j]
+			}
+		}
+	}
+	if total <= 0 {
+		err = errors.New("Rate matrix must have at least one non null rate")
+		return
+	}
+	for i := range b {
+		for j := range b[i] {
+			b[i][j] /= total
+		}
+	}
+	mat.values, mat.vecs = jacobiEigen(b)
+	return
+}
+
+// Computes the probabilities of each state at the end of a branch
+// of length l, given state i at its start:
+// P_ij(l) = sqrt(pi_j/pi_i) * sum_k V_ik * V_jk * exp(lambda_k * l)
+func (mat *simulationMatrix) transitions(i int, l float64, probas []float64) {
+	exps := make([]float64, len(mat.values))
+	for k, v := range mat.values {
+		exps[k] = math.Exp(v * l)
+	}
+	for j := range probas {
+		p := 0.0
+		for k := range exps {
+			p += mat.vecs[i][k] * mat.vecs[j][k] * exps[k]
+		}
+		probas[j] = math.Max(0, p*math.Sqrt(mat.freqs[j]/mat.freqs[i]))
+	}
+}
+
+// Eigen decomposition of a symmetric matrix using the cyclic Jacobi method.
+// Returns the eigen values and the matrix of eigen vectors (in columns).
+func jacobiEigen(m [][]float64) (values []float64, vecs [][]float64) {
+	n := len(m)
+	a := make([][]float64, n)
+	vecs = make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n)
+		copy(a[i], m[i])
+		vecs[i] = make([]float64, n)
+		vecs[i][i] = 1
+	}
+	for sweep := 0; sweep < 100; sweep++ {
+		off := 0.0
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				off += a[i][j] * a[i][j]
+			}
+		}
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < n; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p], a[k][q] = c*akp-s*akq, s*akp+c*akq
+				}
+				for k := 0; k < n; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k], a[q][k] = c*apk-s*aqk, s*apk+c*aqk
+				}
+				for k := 0; k < n; k++ {
+					vkp, vkq := vecs[k][p], vecs[k][q]
+					vecs[k][p], vecs[k][q] = c*vkp-s*vkq, s*vkp+c*vkq
+				}
+			}
+		}
+	}
+	values = make([]float64, n)
+	for i := range values {
+		values[i] = a[i][i]
+	}
+	return
+}
+
+// Draws a state given the probabilities of each state
+func drawState(probas []float64) int {
+	sum := 0.0
+	for _, p := range probas {
+		sum += p
+	}
+	r := rand.Float64() * sum
+	for i, p := range probas {
+		if r < p {
+			return i
+		}
+		r -= p
+	}
+	return len(probas) - 1
+}
+
+// Draws a number from a gamma distribution with the given
+// shape and a scale of 1 (Marsaglia and Tsang, 2000)
+func randGamma(shape float64) float64 {
+	if shape < 1 {
+		return randGamma(shape+1) * math.Pow(rand.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rand.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rand.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
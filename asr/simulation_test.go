@@ -0,0 +1,114 @@
+package asr
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/io/newick"
+)
+
+func TestSimulationTransitions(t *testing.T) {
+	// Jukes-Cantor: closed form
+	_, jc, err := newSimulationMatrix(SimulationModel{Model: MODEL_JC, Alphabet: align.NUCLEOTIDS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	probas := make([]float64, 4)
+	jc.transitions(0, 0.3, probas)
+	same := 0.25 + 0.75*math.Exp(-4.0/3.0*0.3)
+	diff := 0.25 - 0.25*math.Exp(-4.0/3.0*0.3)
+	for j, p := range probas {
+		exp := diff
+		if j == 0 {
+			exp = same
+		}
+		if math.Abs(p-exp) > 1e-10 {
+			t.Errorf("JC transition probability P(0,%d) is %f and should be %f", j, p, exp)
+		}
+	}
+
+	// GTR: rows sum to 1, detailed balance, and identity for l=0
+	freqs := []float64{0.1, 0.2, 0.3, 0.4}
+	_, gtr, err := newSimulationMatrix(SimulationModel{Model: MODEL_GTR, Alphabet: align.NUCLEOTIDS,
+		Rates: []float64{1, 2, 0.5, 1.5, 3, 1}, Frequencies: freqs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([][]float64, 4)
+	for i := range p {
+		p[i] = make([]float64, 4)
+		gtr.transitions(i, 0.5, p[i])
+		sum := 0.0
+		for _, v := range p[i] {
+			sum += v
+		}
+		if math.Abs(sum-1) > 1e-10 {
+			t.Errorf("Transition probabilities from %d sum to %f", i, sum)
+		}
+		gtr.transitions(i, 0, probas)
+		if math.Abs(probas[i]-1) > 1e-10 {
+			t.Errorf("Transition probability P(%d,%d) for a null length is %f", i, i, probas[i])
+		}
+	}
+	for i := range p {
+		for j := range p {
+			if math.Abs(freqs[i]*p[i][j]-freqs[j]*p[j][i]) > 1e-10 {
+				t.Errorf("Detailed balance does not hold for %d and %d", i, j)
+			}
+		}
+	}
+
+	if _, _, err = newSimulationMatrix(SimulationModel{Model: MODEL_HKY, Alphabet: align.AMINOACIDS, Kappa: 2}); err == nil {
+		t.Errorf("HKY on amino acids should return an error")
+	}
+	if _, _, err = newSimulationMatrix(SimulationModel{Model: MODEL_K80, Alphabet: align.NUCLEOTIDS, Kappa: 2, Frequencies: freqs}); err == nil {
+		t.Errorf("Frequencies with K80 should return an error")
+	}
+}
+
+func TestSimulateSequences(t *testing.T) {
+	rand.Seed(10)
+	tr, err := newick.NewParser(strings.NewReader("((A:0,B:0.2)n1:0.3,C:0.5)root;")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	length := 20000
+	tips, anc, err := SimulateSequences(tr, length, SimulationModel{Model: MODEL_JC, Alphabet: align.NUCLEOTIDS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tips.NbSequences() != 3 || anc.NbSequences() != 2 || tips.Length() != length {
+		t.Fatalf("Wrong alignment dimensions: %d tips, %d ancestral sequences, length %d", tips.NbSequences(), anc.NbSequences(), tips.Length())
+	}
+	a, _ := tips.GetSequence("A")
+	n1, _ := anc.GetSequence("n1")
+	if a != n1 {
+		t.Errorf("Sequence of tip A should be identical to the sequence of its parent (null branch length)")
+	}
+	root, _ := anc.GetSequence("root")
+	c, _ := tips.GetSequence("C")
+	ndiff := 0
+	for i := range root {
+		if root[i] != c[i] {
+			ndiff++
+		}
+	}
+	exp := 0.75 * (1 - math.Exp(-4.0/3.0*0.5))
+	if math.Abs(float64(ndiff)/float64(length)-exp) > 0.01 {
+		t.Errorf("Proportion of differences between root and C is %f and should be close to %f", float64(ndiff)/float64(length), exp)
+	}
+
+	// Invariant sites only
+	tips, _, err = SimulateSequences(tr, 100, SimulationModel{Model: MODEL_K80, Alphabet: align.NUCLEOTIDS, Kappa: 2, Alpha: 0.5, PInv: 0.999999})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ = tips.GetSequence("A")
+	c, _ = tips.GetSequence("C")
+	if a != c {
+		t.Errorf("Sequences with invariant sites only should be identical")
+	}
+}
@@ -0,0 +1,47 @@
+package asr
+
+import (
+	"fmt"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/acr"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Computes the homoplasy statistics (see acr.ParsimonyStats) of each site of the
+// alignment, given the number of parsimony steps of each site on the tree
+// (as returned by ParsimonyAsr). Tip characters that are not in the alphabet
+// (e.g. gaps) are not taken into account.
+func SiteParsimonyStats(t *tree.Tree, a align.Alignment, nsteps []int) (stats []acr.ParsimonyStats, err error) {
+	var charToIndex map[rune]int = make(map[rune]int)
+	var counts [][]int
+
+	if len(nsteps) != a.Length() {
+		err = fmt.Errorf("Number of sites (%d) is different from the alignment length (%d)", len(nsteps), a.Length())
+		return
+	}
+	for i, c := range a.AlphabetCharacters() {
+		charToIndex[c] = i
+	}
+	counts = make([][]int, a.Length())
+	for i := range counts {
+		counts[i] = make([]int, len(charToIndex))
+	}
+	for _, tip := range t.Tips() {
+		seq, ok := a.GetSequenceChar(tip.Name())
+		if !ok {
+			err = fmt.Errorf("Sequence %s does not exist in the alignment", tip.Name())
+			return
+		}
+		for j, c := range seq {
+			if idx, ok := charToIndex[c]; ok {
+				counts[j][idx]++
+			}
+		}
+	}
+	stats = make([]acr.ParsimonyStats, a.Length())
+	for j := range stats {
+		stats[j] = acr.NewParsimonyStats(nsteps[j], counts[j])
+	}
+	return
+}
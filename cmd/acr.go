@@ -6,27 +6,37 @@ import (
 	"errors"
 	"fmt"
 	goio "io"
+	"math"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/evolbioinfo/gotree/acr"
 	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/io/utils"
 	"github.com/evolbioinfo/gotree/tree"
 	"github.com/spf13/cobra"
 )
 
 var acrstates string
+var acrtraits string
+var acroutstats string
+var acrpermutations int
 var acrrandomresolve bool // Resolve ambiguities randomly in the downpass/deltran/acctran algo
 var outstepfile string
+var acrmlmethod string
+var acrmodel string
+var acrrate float64
+var acrprobafile string
 
 // acrCmd represents the acr command
 var acrCmd = &cobra.Command{
 	Use:   "acr",
-	Short: "Reconstructs most parsimonious ancestral characters",
-	Long: `Reconstructs most parsimonious ancestral characters.
+	Short: "Reconstructs ancestral characters using parsimony or maximum likelihood",
+	Long: `Reconstructs ancestral characters using parsimony or maximum likelihood.
 
-Depending on the chosen algorithm, it will run:
+Depending on the chosen parsimony algorithm, it will run:
 1) UP-PASS and
 2) Either
    a) DOWN-PASS or
@@ -34,11 +44,54 @@ Depending on the chosen algorithm, it will run:
    c) ACCTRAN
    d) NONE
 
+If --algo sankoff is given, then Sankoff (weighted) parsimony is used, 
+with the state to state cost matrix given with --costs. The first line 
+of the cost file contains the states, and each following line contains 
+a state (parent) followed by the costs of changes to each state (child), 
+separated by tabs or commas. Costs may be asymmetric, and "inf" denotes 
+forbidden changes. Nodes are assigned all states found in at least one
+most parsimonious reconstruction, and the minimum cost is written to 
+the --out-steps file.
+
+If --traits is given instead of --states, then several traits are 
+reconstructed at once (only with parsimony algorithms acctran, deltran, 
+downpass, or none). The trait file is tab separated, its first line 
+contains "tip" followed by the trait names, and each following line 
+contains a tip name followed by its states. In that case:
+- Internal nodes without name are named "node<id>" in the output tree,
+  and states are not written in node comments (input comments are kept);
+- --out-states contains a tab separated table with one line per 
+  internal node and one column per trait;
+- --out-steps contains a tab separated table with the number of 
+  parsimony steps of each trait.
+
+If --out-stats is given (parsimony algorithms only), then the consistency 
+index (CI), the retention index (RI) and the rescaled consistency index (RC)
+of each trait (and of all traits, line "all", if --traits is given) are written 
+to the given file. If --permutations n is also given, then the phylogenetic 
+association of each trait is tested by shuffling tip names n times and 
+comparing the number of parsimony steps to the original tree
+(p-value = (1 + number of shuffled trees with at most as many steps)/(1 + n)).
+Undefined values are written as NA.
+
 Should work on multifurcated trees.
 
 If --random-resolve is given then, during the last pass, each time 
 a node with several possible states still exists, one state is chosen 
 randomly before going deeper in the tree.
+
+If --algo ml is given, then ancestral characters are reconstructed by 
+maximum likelihood, under the Mk model with equal state frequencies 
+(--model jc) or with state frequencies estimated from the tip states 
+(--model f81). The rate of the model is given with --rate, or estimated 
+by maximum likelihood if --rate <= 0. The tree must have branch lengths. 
+The states of the nodes are then selected with --ml-method:
+   a) marginal: State with the highest marginal posterior probability;
+   b) joint: States of the most likely joint reconstruction;
+   c) mppa: Set of states minimizing the Brier score of the marginal 
+      posterior probabilities of each node (MPPA).
+The log likelihood and the rate are written to the --out-steps file, and 
+the marginal posterior probabilities of each node to the --out-probas file.
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var algo int
@@ -50,6 +103,17 @@ randomly before going deeper in the tree.
 		var nsteps int
 		var f *os.File
 		var outstepsf *os.File
+		var probaf *os.File
+		var mlmethod, model int
+		var mlres *acr.MLResult
+		var coststates []string
+		var costs [][]float64
+		var cost float64
+		var traits []string
+		var traitstates []map[string]string
+		var statsf *os.File
+		var stats acr.ParsimonyStats
+		var pvalue float64
 
 		switch strings.ToLower(parsimonyAlgo) {
 		case "acctran":
@@ -60,12 +124,62 @@ randomly before going deeper in the tree.
 			algo = acr.ALGO_DOWNPASS
 		case "none":
 			algo = acr.ALGO_NONE
+		case "ml":
+			algo = acr.ALGO_ML
+		case "sankoff":
+			algo = acr.ALGO_SANKOFF
+			if coststates, costs, err = parseCostMatrix(parsimonyCosts); err != nil {
+				io.LogError(err)
+				return
+			}
 		default:
 			io.LogError(fmt.Errorf("Unkown parsimony algorithm: %s", parsimonyAlgo))
 			return
 		}
+		switch strings.ToLower(acrmlmethod) {
+		case "marginal":
+			mlmethod = acr.ML_MARGINAL
+		case "joint":
+			mlmethod = acr.ML_JOINT
+		case "mppa":
+			mlmethod = acr.ML_MPPA
+		default:
+			err = fmt.Errorf("Unkown maximum likelihood method: %s", acrmlmethod)
+			io.LogError(err)
+			return
+		}
+		switch strings.ToLower(acrmodel) {
+		case "jc":
+			model = acr.MODEL_JC
+		case "f81":
+			model = acr.MODEL_F81
+		default:
+			err = fmt.Errorf("Unkown model: %s", acrmodel)
+			io.LogError(err)
+			return
+		}
+		if (acroutstats != "none" || acrpermutations > 0) && (algo == acr.ALGO_ML || algo == acr.ALGO_SANKOFF) {
+			err = errors.New("--out-stats and --permutations are only supported with acctran, deltran, downpass, or none algorithms")
+			io.LogError(err)
+			return
+		}
+		if acrpermutations > 0 && acroutstats == "none" {
+			err = errors.New("--permutations requires --out-stats")
+			io.LogError(err)
+			return
+		}
 		// Reading tip state in an input file
-		if tipstates, err = parseTipStates(acrstates); err != nil {
+		if acrtraits != "none" {
+			if algo == acr.ALGO_ML || algo == acr.ALGO_SANKOFF {
+				err = errors.New("--traits is only supported with acctran, deltran, downpass, or none algorithms")
+				io.LogError(err)
+				return
+			}
+			if traits, traitstates, err = parseTraitMatrix(acrtraits); err != nil {
+				io.LogError(err)
+				return
+			}
+		} else if tipstates, err = parseTipStates(acrstates); err != nil {
 			io.LogError(err)
 			return
 		}
@@ -96,14 +210,68 @@ randomly before going deeper in the tree.
 			}
 			defer closeWriteFile(resfile, outresfile)
 		}
-		for t := range treechan {
-			statemap, nsteps, err = acr.ParsimonyAcr(t.Tree, tipstates, algo, acrrandomresolve)
-			if err != nil {
+		if acrprobafile != "none" {
+			if probaf, err = openWriteFile(acrprobafile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(probaf, acrprobafile)
+		}
+		if acroutstats != "none" {
+			if statsf, err = openWriteFile(acroutstats); err != nil {
 				io.LogError(err)
 				return
 			}
+			defer closeWriteFile(statsf, acroutstats)
+			fmt.Fprintf(statsf, "tree\ttrait\t%s\tpvalue\n", parsimonyStatsHeader)
+		}
+		if acrtraits != "none" {
+			var resw, statsw goio.Writer
+			if resfile != nil {
+				resw = resfile
+			}
+			if statsf != nil {
+				statsw = statsf
+			}
+			return acrTraits(treechan, traits, traitstates, algo, f, outstepsf, resw, statsw)
+		}
+
+		header := true
+		for t := range treechan {
+			if algo == acr.ALGO_ML {
+				if mlres, err = acr.MLAcr(t.Tree, tipstates, mlmethod, model, acrrate); err != nil {
+					io.LogError(err)
+					return
+				}
+				statemap = mlres.States
+				fmt.Fprintf(outstepsf, "lnl %f\n", mlres.LogLikelihood)
+				fmt.Fprintf(outstepsf, "rate %f\n", mlres.Rate)
+				if acrprobafile != "none" {
+					writeAcrProbas(probaf, t.Tree, mlres, header)
+					header = false
+				}
+			} else if algo == acr.ALGO_SANKOFF {
+				if statemap, cost, err = acr.SankoffAcr(t.Tree, tipstates, coststates, costs, acrrandomresolve); err != nil {
+					io.LogError(err)
+					return
+				}
+				fmt.Fprintf(outstepsf, "cost %f\n", cost)
+			} else {
+				statemap, nsteps, err = acr.ParsimonyAcr(t.Tree, tipstates, algo, acrrandomresolve)
+				if err != nil {
+					io.LogError(err)
+					return
+				}
+				fmt.Fprintf(outstepsf, "steps %d\n", nsteps)
+				if acroutstats != "none" {
+					if stats, pvalue, err = acrParsimonyStats(t.Tree, tipstates, nsteps); err != nil {
+						io.LogError(err)
+						return
+					}
+					fmt.Fprintf(statsf, "%d\ttrait\t%s\t%s\n", t.Id, formatParsimonyStats(stats), formatNA(pvalue))
+				}
+			}
 			f.WriteString(t.Tree.Newick() + "\n")
-			fmt.Fprintf(outstepsf, "steps %d\n", nsteps)
 			if outresfile != "none" {
 				for k, v := range statemap {
 					resfile.WriteString(fmt.Sprintf("%s,%s\n", k, v))
@@ -117,14 +285,255 @@ randomly before going deeper in the tree.
 func init() {
 	RootCmd.AddCommand(acrCmd)
 	acrCmd.PersistentFlags().StringVar(&acrstates, "states", "stdin", "Tip state file (One line per tip, tab separated: tipname\\tstate)")
+	acrCmd.PersistentFlags().StringVar(&acrtraits, "traits", "none", "Tip trait matrix file (tab separated, first line: tip\\ttrait1\\ttrait2..., then one line per tip), instead of --states")
 	acrCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
+	acrCmd.PersistentFlags().StringVar(&acroutstats, "out-stats", "none", "Output file with consistency and retention indices of each trait (parsimony only)")
+	acrCmd.PersistentFlags().IntVar(&acrpermutations, "permutations", 0, "Number of tip shuffles for the permutation test of phylogenetic association (with --out-stats)")
 	acrCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file")
 	acrCmd.PersistentFlags().StringVar(&outresfile, "out-states", "none", "Output mapping file between node names and states")
-	acrCmd.PersistentFlags().StringVar(&outstepfile, "out-steps", "stdout", "Output file with number of parsimony steps")
-	acrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "Parsimony algorithm for resolving ambiguities: acctran, deltran, or downpass")
+	acrCmd.PersistentFlags().StringVar(&outstepfile, "out-steps", "stdout", "Output file with number of parsimony steps (or log likelihood and rate if --algo ml)")
+	acrCmd.PersistentFlags().StringVar(&acrprobafile, "out-probas", "none", "Output file with marginal posterior probabilities of states for each node (--algo ml)")
+	acrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "Parsimony algorithm for resolving ambiguities: acctran, deltran, or downpass, sankoff for weighted parsimony, or ml for maximum likelihood")
+	acrCmd.PersistentFlags().StringVar(&parsimonyCosts, "costs", "none", "Cost matrix file for Sankoff parsimony (--algo sankoff)")
+	acrCmd.PersistentFlags().StringVar(&acrmlmethod, "ml-method", "mppa", "Maximum likelihood method for selecting states (--algo ml): marginal, joint, or mppa")
+	acrCmd.PersistentFlags().StringVar(&acrmodel, "model", "f81", "Maximum likelihood model (--algo ml): jc (equal frequencies) or f81 (frequencies estimated from tip states)")
+	acrCmd.PersistentFlags().Float64Var(&acrrate, "rate", -1, "Rate of the model (--algo ml), estimated by maximum likelihood if <= 0")
 	acrCmd.PersistentFlags().BoolVar(&acrrandomresolve, "random-resolve", false, "Random resolve states when several possibilities in: acctran, deltran, or downpass")
 }
 
+// Reconstructs ancestral characters of several traits for each input tree,
+// and writes the trees, the number of steps of each trait, and the states
+// of each internal node for each trait (if resfile is not nil).
+func acrTraits(treechan <-chan tree.Trees, traits []string, traitstates []map[string]string, algo int, treef, stepsf, resfile, statsf goio.Writer) (err error) {
+	var statemaps []map[string]string
+	var nsteps []int
+	var stats []acr.ParsimonyStats
+	var pvalue float64
+
+	fmt.Fprintf(stepsf, "tree\ttrait\tsteps\n")
+	if resfile != nil {
+		fmt.Fprintf(resfile, "tree\tnode\t%s\n", strings.Join(traits, "\t"))
+	}
+	for t := range treechan {
+		if t.Err != nil {
+			err = t.Err
+			io.LogError(err)
+			return
+		}
+		// Internal nodes are named so that they can be identified in the output table
+		for i, n := range t.Tree.Nodes() {
+			if !n.Tip() && n.Name() == "" {
+				n.SetName(fmt.Sprintf("node%d", i))
+			}
+		}
+		if statemaps, nsteps, err = acr.ParsimonyAcrTraits(t.Tree, traitstates, algo, acrrandomresolve); err != nil {
+			io.LogError(err)
+			return
+		}
+		fmt.Fprintf(treef, "%s\n", t.Tree.Newick())
+		for i, trait := range traits {
+			fmt.Fprintf(stepsf, "%d\t%s\t%d\n", t.Id, trait, nsteps[i])
+		}
+		if statsf != nil {
+			stats = make([]acr.ParsimonyStats, len(traits))
+			for i, trait := range traits {
+				if stats[i], pvalue, err = acrParsimonyStats(t.Tree, traitstates[i], nsteps[i]); err != nil {
+					io.LogError(err)
+					return
+				}
+				fmt.Fprintf(statsf, "%d\t%s\t%s\t%s\n", t.Id, trait, formatParsimonyStats(stats[i]), formatNA(pvalue))
+			}
+			fmt.Fprintf(statsf, "%d\tall\t%s\tNA\n", t.Id, formatParsimonyStats(acr.SumParsimonyStats(stats)))
+		}
+		if resfile != nil {
+			for _, n := range t.Tree.Nodes() {
+				if !n.Tip() {
+					fmt.Fprintf(resfile, "%d\t%s", t.Id, n.Name())
+					for i := range traits {
+						fmt.Fprintf(resfile, "\t%s", statemaps[i][n.Name()])
+					}
+					fmt.Fprintf(resfile, "\n")
+				}
+			}
+		}
+	}
+	return
+}
+
+// Header of the homoplasy statistics columns
+const parsimonyStatsHeader = "steps\tmin_steps\tmax_steps\tCI\tRI\tRC"
+
+// Computes the homoplasy statistics of a trait, and the p-value of
+// the permutation test if --permutations > 0 (NaN otherwise).
+func acrParsimonyStats(t *tree.Tree, tipstates map[string]string, nsteps int) (stats acr.ParsimonyStats, pvalue float64, err error) {
+	pvalue = math.NaN()
+	if stats, err = acr.CharacterParsimonyStats(t, tipstates, nsteps); err != nil {
+		return
+	}
+	if acrpermutations > 0 {
+		_, pvalue, err = acr.ParsimonyPermutationTest(t, tipstates, acrpermutations)
+	}
+	return
+}
+
+// Formats the homoplasy statistics, tab separated
+// (see parsimonyStatsHeader)
+func formatParsimonyStats(s acr.ParsimonyStats) string {
+	return fmt.Sprintf("%d\t%d\t%d\t%s\t%s\t%s", s.Steps, s.MinSteps, s.MaxSteps, formatNA(s.CI()), formatNA(s.RI()), formatNA(s.RC()))
+}
+
+// Formats a float, or NA if it is NaN
+func formatNA(v float64) string {
+	if math.IsNaN(v) {
+		return "NA"
+	}
+	return fmt.Sprintf("%f", v)
+}
+
+// Parses a tip trait matrix file: tab separated, the first line contains
+// a header (e.g. "tip") followed by the trait names, and each following line
+// contains a tip name followed by its state for each trait.
+// Returns the trait names, and one mapping between tip names and states per trait.
+func parseTraitMatrix(file string) (traits []string, states []map[string]string, err error) {
+	var f goio.Closer
+	var r *bufio.Reader
+
+	if f, r, err = utils.GetReader(file); err != nil {
+		return
+	}
+	defer f.Close()
+
+	l, e := Readln(r)
+	if e != nil {
+		err = errors.New("Trait matrix file is empty")
+		return
+	}
+	header := strings.Split(strings.TrimRight(l, "\r"), "\t")
+	if len(header) < 2 {
+		err = errors.New("Bad format for trait matrix: at least one trait column is required")
+		return
+	}
+	traits = header[1:]
+	states = make([]map[string]string, len(traits))
+	for i := range states {
+		states[i] = make(map[string]string)
+	}
+	for l, e = Readln(r); e == nil; l, e = Readln(r) {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		cols := strings.Split(strings.TrimRight(l, "\r"), "\t")
+		if len(cols) != len(header) {
+			err = fmt.Errorf("Bad format for trait matrix: Wrong number of columns for tip %s", cols[0])
+			return
+		}
+		for i, s := range cols[1:] {
+			if s == "" {
+				err = fmt.Errorf("Tip %s has no state for trait %s", cols[0], traits[i])
+				return
+			}
+			states[i][cols[0]] = s
+		}
+	}
+	return
+}
+
+// Writes the marginal posterior probabilities of the states of each node,
+// tab separated: node name or id, and one column per state.
+func writeAcrProbas(w goio.Writer, t *tree.Tree, res *acr.MLResult, header bool) {
+	if header {
+		fmt.Fprintf(w, "node\t%s\n", strings.Join(res.Alphabet, "\t"))
+	}
+	for _, n := range t.Nodes() {
+		id := fmt.Sprintf("%d", n.Id())
+		if n.Name() != "" {
+			id = n.Name()
+		}
+		fmt.Fprint(w, id)
+		for _, p := range res.Probas[id] {
+			fmt.Fprintf(w, "\t%f", p)
+		}
+		fmt.Fprint(w, "\n")
+	}
+}
+
+// Parses a cost matrix file for Sankoff parsimony.
+// The first line contains the states, and each following line contains a state (parent)
+// followed by the costs of changes to each state (child), separated by tabs or commas.
+// costs[i][j] is the cost of a change from states[i] to states[j].
+func parseCostMatrix(file string) (states []string, costs [][]float64, err error) {
+	var f goio.Closer
+	var r *bufio.Reader
+	var indices map[string]int = make(map[string]int)
+	var seen map[string]bool = make(map[string]bool)
+
+	if file == "none" {
+		err = errors.New("A cost matrix file must be given with --costs")
+		return
+	}
+	if f, r, err = utils.GetReader(file); err != nil {
+		return
+	}
+	defer f.Close()
+
+	re := regexp.MustCompile("\t|,")
+	l, e := Readln(r)
+	for e == nil && strings.TrimSpace(l) == "" {
+		l, e = Readln(r)
+	}
+	if e != nil {
+		err = errors.New("Cost matrix file is empty")
+		return
+	}
+	// Header: first column may be empty
+	for i, s := range re.Split(strings.TrimSpace(l), -1) {
+		s = strings.TrimSpace(s)
+		if i == 0 && s == "" {
+			continue
+		}
+		if _, ok := indices[s]; ok {
+			err = fmt.Errorf("State %s is present several times in the cost matrix header", s)
+			return
+		}
+		indices[s] = len(states)
+		states = append(states, s)
+	}
+	costs = make([][]float64, len(states))
+
+	for l, e = Readln(r); e == nil; l, e = Readln(r) {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		cols := re.Split(strings.TrimSpace(l), -1)
+		if len(cols) != len(states)+1 {
+			err = fmt.Errorf("Bad format for cost matrix: Wrong number of columns in line %s", l)
+			return
+		}
+		from := strings.TrimSpace(cols[0])
+		i, ok := indices[from]
+		if !ok {
+			err = fmt.Errorf("State %s is not present in the cost matrix header", from)
+			return
+		}
+		if seen[from] {
+			err = fmt.Errorf("State %s is present several times in the cost matrix", from)
+			return
+		}
+		seen[from] = true
+		costs[i] = make([]float64, len(states))
+		for j, c := range cols[1:] {
+			if costs[i][j], err = strconv.ParseFloat(strings.TrimSpace(c), 64); err != nil {
+				err = fmt.Errorf("Cost %s (row %s) is not a number", c, from)
+				return
+			}
+		}
+	}
+	if len(seen) != len(states) {
+		err = fmt.Errorf("Cost matrix has %d rows while %d states are given in the header", len(seen), len(states))
+	}
+	return
+}
+
 func parseTipStates(file string) (states map[string]string, err error) {
 	var f *os.File
 	var r *bufio.Reader
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+	"strconv"
+
+	"github.com/evolbioinfo/gotree/acr"
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var acrcontrastsfile string
+var acrsignalfile string
+
+// acrContinuousCmd represents the acr continuous command
+var acrContinuousCmd = &cobra.Command{
+	Use:   "continuous",
+	Short: "Reconstructs ancestral values of a continuous trait under Brownian motion",
+	Long: `Reconstructs ancestral values of a continuous trait under Brownian motion.
+
+The tip values are given with --states (one line per tip, tab separated: tipname\tvalue),
+and the tree must have branch lengths. Ancestral values are maximum likelihood estimates
+under Brownian motion, rooted at the root of the tree. Their variance is computed using
+the REML estimate of the rate (sigma2: mean of the squared independent contrasts), and
+their 95% confidence interval is value +/- 1.96*sqrt(variance). Multifurcations are
+handled as if they were resolved with branches of length 0.
+
+Internal nodes without name are named "node<id>", and the ancestral values are written
+in the output tree as node attributes "value" and "value_95%_CI".
+
+Other outputs (tab separated, one line per node/tree):
+--out-states    : tree, node, value, variance, and 95% confidence interval of each internal node
+--out-contrasts : tree, node, standardized independent contrast (Felsenstein, 1985), and its
+                  expected variance (sum of corrected branch lengths)
+--out-signal    : tree, sigma2, log likelihood, Blomberg's K (with its p-value computed
+                  using --permutations shuffles of tip values, NA if 0), ML estimate of
+                  Pagel's lambda in [0,1], log likelihood at lambda and at lambda=0, and
+                  p-value of the likelihood ratio test of lambda against lambda=0
+
+Example:
+gotree acr continuous -i tree.nw --states values.txt --out-states anc.txt --out-signal signal.txt --permutations 1000
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var tipvalues map[string]float64
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var f, resfile, contrastsf, signalf *os.File
+		var res *acr.ContinuousResult
+		var signal acr.PhylogeneticSignal
+
+		if tipvalues, err = parseTipValues(acrstates); err != nil {
+			io.LogError(err)
+			return
+		}
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if outresfile != "none" {
+			if resfile, err = openWriteFile(outresfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(resfile, outresfile)
+			fmt.Fprintf(resfile, "tree\tnode\tvalue\tvariance\tci_low\tci_high\n")
+		}
+		if acrcontrastsfile != "none" {
+			if contrastsf, err = openWriteFile(acrcontrastsfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(contrastsf, acrcontrastsfile)
+			fmt.Fprintf(contrastsf, "tree\tnode\tcontrast\tvariance\n")
+		}
+		if acrsignalfile != "none" {
+			if signalf, err = openWriteFile(acrsignalfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(signalf, acrsignalfile)
+			fmt.Fprintf(signalf, "tree\tsigma2\tlnl\tK\tK_pvalue\tlambda\tlambda_lnl\tlambda0_lnl\tlambda_pvalue\n")
+		}
+
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			// Internal nodes are named so that they can be identified in the output tables
+			for i, n := range t.Tree.Nodes() {
+				if !n.Tip() && n.Name() == "" {
+					n.SetName(fmt.Sprintf("node%d", i))
+				}
+			}
+			if res, err = acr.ContinuousAcr(t.Tree, tipvalues); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+
+			if resfile != nil {
+				for _, n := range t.Tree.Nodes() {
+					if !n.Tip() {
+						s := res.States[n.Name()]
+						fmt.Fprintf(resfile, "%d\t%s\t%f\t%f\t%f\t%f\n", t.Id, n.Name(), s.Value, s.Variance, s.Low, s.High)
+					}
+				}
+			}
+			if contrastsf != nil {
+				for _, c := range res.Contrasts {
+					fmt.Fprintf(contrastsf, "%d\t%s\t%f\t%f\n", t.Id, c.Node.Name(), c.Value, c.Variance)
+				}
+			}
+			if signalf != nil {
+				if signal, err = acr.ContinuousSignal(t.Tree, tipvalues, acrpermutations); err != nil {
+					io.LogError(err)
+					return
+				}
+				fmt.Fprintf(signalf, "%d\t%f\t%f\t%f\t%s\t%f\t%f\t%f\t%f\n", t.Id, res.Sigma2, res.LogLikelihood,
+					signal.K, formatNA(signal.KPValue), signal.Lambda, signal.LambdaLogLikelihood,
+					signal.NullLogLikelihood, signal.LambdaPValue)
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	acrCmd.AddCommand(acrContinuousCmd)
+	acrContinuousCmd.PersistentFlags().StringVar(&acrcontrastsfile, "out-contrasts", "none", "Output file with independent contrasts")
+	acrContinuousCmd.PersistentFlags().StringVar(&acrsignalfile, "out-signal", "none", "Output file with the rate, Blomberg's K and Pagel's lambda")
+}
+
+// Parses a tip value file: one line per tip, tab or comma
+// separated: tipname and value (see parseTipStates).
+func parseTipValues(file string) (values map[string]float64, err error) {
+	var states map[string]string
+	var v float64
+
+	if states, err = parseTipStates(file); err != nil {
+		return
+	}
+	values = make(map[string]float64, len(states))
+	for tip, state := range states {
+		if v, err = strconv.ParseFloat(state, 64); err != nil {
+			err = fmt.Errorf("Value of tip %s is not a number: %s", tip, state)
+			return
+		}
+		values[tip] = v
+	}
+	return
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	goio "io"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"github.com/evolbioinfo/goalign/align"
 	"github.com/evolbioinfo/goalign/io/fasta"
 	"github.com/evolbioinfo/goalign/io/phylip"
+	"github.com/evolbioinfo/gotree/acr"
 	"github.com/evolbioinfo/gotree/asr"
 	"github.com/evolbioinfo/gotree/io"
 	"github.com/evolbioinfo/gotree/io/utils"
@@ -22,6 +24,11 @@ var asrphylip bool
 var asrinputstrict bool
 var asrrandomresolve bool // Resolve ambiguities randomly in the downpass/deltran/acctran algo
 var outlogfile string
+var asroutalign string
+var asroutphylip bool
+var asrwithtips bool
+var asroutsubs string
+var asroutstats string
 
 // asrCmd represents the asr command
 var asrCmd = &cobra.Command{
@@ -37,11 +44,33 @@ Depending on the chosen algorithm, it will run:
    c) ACCTRAN
    d) NONE
 
+If --algo sankoff is given, then Sankoff (weighted) parsimony is used, 
+with the character to character cost matrix given with --costs (see 
+gotree acr). The matrix must contain all characters of the alphabet, 
+and the minimum cost of each site is written to the log file.
+
 Should work on multifurcated trees
 
 If --random-resolve is given then, during the last pass, each time 
 a node with several possible states still exists, one state is chosen 
 randomly before going deeper in the tree.
+
+If --out-align is given, ancestral sequences of internal nodes (and of tips
+if --with-tips is given) are written in Fasta (or Phylip if --out-phylip is given)
+format. Ambiguous states are written using IUPAC codes for nucleotides, and X for 
+amino acids.
+
+If --out-subs is given, the substitutions of each branch are written in a tab 
+separated file with the following columns:
+tree index, parent node, child node, site (starting at 1), parent state, child state, 
+and ambiguous (true if the parent or the child has several possible states).
+
+In both cases, internal nodes without name are named "node<id>" in all outputs.
+
+If --out-stats is given (parsimony algorithms except sankoff), then the 
+consistency index (CI), the retention index (RI) and the rescaled consistency 
+index (RC) of each site (starting at 1) and of the whole alignment (line "all")
+are written to the given file, tab separated. Undefined values are written as NA.
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var align align.Alignment
@@ -53,6 +82,13 @@ randomly before going deeper in the tree.
 		var f *os.File
 		var logf *os.File
 		var nsteps []int
+		var coststates []string
+		var costs [][]float64
+		var sitecosts []float64
+		var statsf *os.File
+		var stats []acr.ParsimonyStats
+		var seqs []*asr.AncestralSequence
+		var alignf, subsf *os.File
 
 		switch strings.ToLower(parsimonyAlgo) {
 		case "acctran":
@@ -63,12 +99,24 @@ randomly before going deeper in the tree.
 			algo = asr.ALGO_DOWNPASS
 		case "none":
 			algo = asr.ALGO_NONE
+		case "sankoff":
+			algo = asr.ALGO_SANKOFF
+			if coststates, costs, err = parseCostMatrix(parsimonyCosts); err != nil {
+				io.LogError(err)
+				return
+			}
 		default:
 			err = fmt.Errorf("Unkown parsimony algorithm: %s", parsimonyAlgo)
 			io.LogError(err)
 			return
 		}
 
+		if asroutstats != "none" && algo == asr.ALGO_SANKOFF {
+			err = errors.New("--out-stats is not supported with sankoff algorithm")
+			io.LogError(err)
+			return
+		}
+
 		// Reading the alignment
 		fi, r, err = utils.GetReader(asralign)
 		if err != nil {
@@ -108,18 +156,87 @@ randomly before going deeper in the tree.
 			return
 		}
 		defer closeWriteFile(logf, outlogfile)
-
-		for t := range treechan {
-			nsteps, err = asr.ParsimonyAsr(t.Tree, align, algo, asrrandomresolve)
-			if err != nil {
+		if asroutalign != "none" {
+			if alignf, err = openWriteFile(asroutalign); err != nil {
 				io.LogError(err)
 				return
 			}
-			fmt.Fprintf(logf, "steps")
-			for _, s := range nsteps {
-				fmt.Fprintf(logf, " %d", s)
+			defer closeWriteFile(alignf, asroutalign)
+		}
+		if asroutstats != "none" {
+			if statsf, err = openWriteFile(asroutstats); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(statsf, asroutstats)
+			fmt.Fprintf(statsf, "tree\tsite\t%s\n", parsimonyStatsHeader)
+		}
+		if asroutsubs != "none" {
+			if subsf, err = openWriteFile(asroutsubs); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(subsf, asroutsubs)
+			fmt.Fprintf(subsf, "tree\tparent\tchild\tsite\tparent_state\tchild_state\tambiguous\n")
+		}
+
+		for t := range treechan {
+			if algo == asr.ALGO_SANKOFF {
+				if seqs, sitecosts, err = asr.SankoffAsrSequences(t.Tree, align, coststates, costs, asrrandomresolve); err != nil {
+					io.LogError(err)
+					return
+				}
+				fmt.Fprintf(logf, "cost")
+				for _, c := range sitecosts {
+					fmt.Fprintf(logf, " %f", c)
+				}
+				fmt.Fprintf(logf, "\n")
+			} else {
+				if seqs, nsteps, err = asr.ParsimonyAsrSequences(t.Tree, align, algo, asrrandomresolve); err != nil {
+					io.LogError(err)
+					return
+				}
+				fmt.Fprintf(logf, "steps")
+				for _, s := range nsteps {
+					fmt.Fprintf(logf, " %d", s)
+				}
+				fmt.Fprintf(logf, "\n")
+				if asroutstats != "none" {
+					if stats, err = asr.SiteParsimonyStats(t.Tree, align, nsteps); err != nil {
+						io.LogError(err)
+						return
+					}
+					for i, s := range stats {
+						fmt.Fprintf(statsf, "%d\t%d\t%s\n", t.Id, i+1, formatParsimonyStats(s))
+					}
+					fmt.Fprintf(statsf, "%d\tall\t%s\n", t.Id, formatParsimonyStats(acr.SumParsimonyStats(stats)))
+				}
+			}
+			if asroutalign != "none" || asroutsubs != "none" {
+				for _, n := range t.Tree.Nodes() {
+					if !n.Tip() && n.Name() == "" {
+						n.SetName(fmt.Sprintf("node%d", n.Id()))
+					}
+				}
+			}
+			asr.AssignSequencesToTree(t.Tree, seqs, align)
+			if asroutalign != "none" {
+				if anc, err2 := asr.AncestralAlignment(t.Tree, seqs, align, asrwithtips); err2 != nil {
+					err = err2
+					io.LogError(err)
+					return
+				} else if asroutphylip {
+					alignf.WriteString(phylip.WriteAlignment(anc, false, false, false))
+				} else {
+					alignf.WriteString(fasta.WriteAlignment(anc))
+				}
+			}
+			if asroutsubs != "none" {
+				for _, s := range asr.Substitutions(t.Tree, seqs, align) {
+					fmt.Fprintf(subsf, "%d\t%s\t%s\t%d\t%s\t%s\t%t\n",
+						t.Id, s.ParentName(), s.ChildName(), s.Site+1, s.ParentState, s.ChildState, s.Ambiguous)
+				}
 			}
-			fmt.Fprintf(logf, "\n")
 			f.WriteString(t.Tree.Newick() + "\n")
 		}
 		return
@@ -134,6 +251,12 @@ func init() {
 	asrCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
 	asrCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file")
 	asrCmd.PersistentFlags().StringVar(&outlogfile, "log", "stdout", "Output log file")
-	asrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "Parsimony algorithm for resolving ambiguities: acctran, deltran, or downpass")
+	asrCmd.PersistentFlags().StringVar(&asroutalign, "out-align", "none", "Output ancestral sequence alignment file")
+	asrCmd.PersistentFlags().BoolVar(&asroutphylip, "out-phylip", false, "Ancestral alignment is written in phylip (default Fasta)")
+	asrCmd.PersistentFlags().BoolVar(&asrwithtips, "with-tips", false, "Ancestral alignment also contains tip sequences")
+	asrCmd.PersistentFlags().StringVar(&asroutstats, "out-stats", "none", "Output file with consistency and retention indices of each site")
+	asrCmd.PersistentFlags().StringVar(&asroutsubs, "out-subs", "none", "Output file with substitutions on each branch (tab separated)")
+	asrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "Parsimony algorithm for resolving ambiguities: acctran, deltran, or downpass, or sankoff for weighted parsimony")
+	asrCmd.PersistentFlags().StringVar(&parsimonyCosts, "costs", "none", "Cost matrix file for Sankoff parsimony (--algo sankoff)")
 	asrCmd.PersistentFlags().BoolVar(&asrrandomresolve, "random-resolve", false, "Random resolve states when several possibilities in: acctran, deltran, or downpass")
 }
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/acr"
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var associationStates string
+var associationReplicates int
+
+// associationCmd represents the association command
+var associationCmd = &cobra.Command{
+	Use:   "association",
+	Short: "Tests the association between a trait and a set of trees",
+	Long: `Tests the association between a trait and a set of trees.
+
+It computes the statistics of BaTS (Parker et al., 2008), given the states of the tips
+(--states, same format as gotree acr), on each input tree (e.g. a posterior set of trees):
+- AI    : Association index (Wang et al., 2001), low values denote association
+- PS    : Fitch parsimony score, low values denote association
+- MC(s) : Maximum monophyletic clade size of each state s, high values denote association
+
+Null distributions are computed by randomizing tip states (shuffling states among tips)
+--replicates times on each tree.
+
+The output is tab separated, with one line per statistic, and the following columns:
+1) Statistic
+2-4) Mean observed value over all trees, and 95% interval (2.5% and 97.5% quantiles)
+5-7) Mean null value over all randomizations, and 95% interval
+8) P-value: Proportion of null values lower than or equal to (AI, PS), or greater
+   than or equal to (MC) the mean observed value
+
+Example:
+gotree compute association -i posterior.nw --burnin 0.1 --states states.txt --replicates 100
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var tipstates map[string]string
+		var summaries []acr.AssociationSummary
+
+		if associationStates == "none" {
+			err = errors.New("--states is required")
+			io.LogError(err)
+			return
+		}
+		if tipstates, err = parseTipStates(associationStates); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		if summaries, err = acr.AssociationTest(treechan, tipstates, associationReplicates); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		fmt.Fprintf(f, "statistic\tobserved\tobserved_low\tobserved_high\tnull\tnull_low\tnull_high\tpvalue\n")
+		for _, s := range summaries {
+			fmt.Fprintf(f, "%s\t%f\t%f\t%f\t%f\t%f\t%f\t%f\n",
+				s.Statistic, s.Observed, s.ObservedLow, s.ObservedHigh,
+				s.Null, s.NullLow, s.NullHigh, s.PValue)
+		}
+		return
+	},
+}
+
+func init() {
+	computeCmd.AddCommand(associationCmd)
+	associationCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree(s) file")
+	associationCmd.PersistentFlags().StringVar(&associationStates, "states", "none", "Tip state file (One line per tip, tab separated: tipname\\tstate)")
+	associationCmd.PersistentFlags().IntVar(&associationReplicates, "replicates", 100, "Number of tip state randomizations per tree")
+	associationCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Association statistics output file")
+}
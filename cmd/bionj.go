@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+// bionjCmd represents the bionj command
+var bionjCmd = &cobra.Command{
+	Use:   "bionj",
+	Short: "Infers a tree from a distance matrix using BIONJ",
+	Long: `Infers a tree from a distance matrix using BIONJ (Gascuel, 1997).
+
+BIONJ is a variant of Neighbor-Joining, that takes into account the variances
+of the distance estimates when agglomerating taxa.
+
+The input matrix is in PHYLIP format: the first line contains the number of taxa,
+and each following row contains the name of a taxon followed by its distances.
+The matrix may be square (as output by gotree matrix) or lower-triangular
+(with or without diagonal).
+
+The output tree is unrooted.
+
+Negative branch lengths may be (--negative):
+- keep    : Kept as is (default)
+- zero    : Set to 0
+- transfer: Set to 0, the difference being transfered to the sister branch
+
+Example:
+gotree matrix -i tree.nw | gotree compute bionj -o bionj.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		return distanceTree(func(names []string, matrix [][]float64, negative int) (*tree.Tree, error) {
+			return tree.BioNJ(names, matrix, negative)
+		})
+	},
+}
+
+func init() {
+	computeCmd.AddCommand(bionjCmd)
+	bionjCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input distance matrix (PHYLIP format)")
+	bionjCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output tree")
+	bionjCmd.Flags().StringVar(&distanceNegative, "negative", "keep", "Handling of negative branch lengths: keep, zero, or transfer")
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var bdAge float64
+var bdBirth string
+var bdDeath string
+var bdSampling string
+var bdShifts string
+var bdRho float64
+var bdComplete bool
+
+// birthdeathCmd represents the birthdeath command
+var birthdeathCmd = &cobra.Command{
+	Use:   "birthdeath",
+	Short: "Generates a random birth-death tree",
+	Long: `Generates a random birth-death tree.
+
+Trees are time-calibrated, rooted, and simulated forward in time, starting with
+two lineages at the root, under a birth-death process with:
+- Birth rate (--birth), death rate (--death), and serial sampling rate (--sampling).
+  Serially sampled lineages are removed from the process;
+- Probability of sampling each lineage alive at present (--rho).
+
+Rates may be piecewise constant: --shifts gives the comma separated times (since the root)
+at which rates change, and rates are then given as comma separated lists, one rate per
+time interval (a single rate applies to all intervals).
+
+Two stopping conditions are possible:
+- Number of tips (-l): the present is drawn uniformly among the times at which l lineages
+  would be sampled, following the general sampling approach (Hartmann, Wong & Stadler, 2010):
+  several processes are simulated well beyond l/rho lineages, and the present is drawn
+  among the periods of all simulations, according to their length and to the probability
+  to sample l lineages out of the lineages alive. Exactly l of the lineages alive at present
+  are then sampled;
+- Age (--age): the process is simulated during the given time, and each lineage alive at
+  present is sampled with probability rho.
+Simulations are repeated until at least 2 tips are sampled.
+
+By default, the reconstructed tree is written: unsampled and extinct lineages are pruned.
+Without serial sampling, this tree is ultrametric. If --complete is given, all lineages are
+kept, and each tip has a status attribute: extant, unsampled, extinct, or sampled (serially).
+
+Example:
+gotree generate birthdeath -l 100 --birth 1 --death 0.5 --rho 0.5 --seed 10
+gotree generate birthdeath --age 5 --birth 2,1 --death 0.5 --sampling 0,0.2 --shifts 3
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var t *tree.Tree
+		var m tree.BirthDeathModel
+
+		m.Rho = bdRho
+		if m.Birth, err = parseFloatList(bdBirth); err != nil {
+			io.LogError(err)
+			return
+		}
+		if m.Death, err = parseFloatList(bdDeath); err != nil {
+			io.LogError(err)
+			return
+		}
+		if m.Sampling, err = parseFloatList(bdSampling); err != nil {
+			io.LogError(err)
+			return
+		}
+		if bdShifts != "none" {
+			if m.Shifts, err = parseFloatList(bdShifts); err != nil {
+				io.LogError(err)
+				return
+			}
+		}
+		nbtips := generateNbTips
+		if bdAge > 0 {
+			if cmd.Flags().Changed("nbtips") {
+				err = errors.New("Number of tips (-l) and age (--age) cannot be given together")
+				io.LogError(err)
+				return
+			}
+			nbtips = -1
+		}
+
+		if f, err = openWriteFile(generateOutputfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, generateOutputfile)
+
+		for i := 0; i < generateNbTrees; i++ {
+			if t, err = tree.RandomBirthDeathTree(m, nbtips, bdAge, bdComplete); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Newick() + "\n")
+		}
+		return
+	},
+}
+
+func init() {
+	generateCmd.AddCommand(birthdeathCmd)
+	birthdeathCmd.PersistentFlags().IntVarP(&generateNbTips, "nbtips", "l", 10, "Number of tips/leaves of the tree to generate")
+	birthdeathCmd.PersistentFlags().Float64Var(&bdAge, "age", -1, "Age of the tree (time from the root to the present), instead of a number of tips")
+	birthdeathCmd.PersistentFlags().StringVar(&bdBirth, "birth", "1", "Birth rate(s), comma separated if several time intervals")
+	birthdeathCmd.PersistentFlags().StringVar(&bdDeath, "death", "0", "Death rate(s), comma separated if several time intervals")
+	birthdeathCmd.PersistentFlags().StringVar(&bdSampling, "sampling", "0", "Serial sampling rate(s), comma separated if several time intervals")
+	birthdeathCmd.PersistentFlags().StringVar(&bdShifts, "shifts", "none", "Comma separated times (since the root) of rate shifts")
+	birthdeathCmd.PersistentFlags().Float64Var(&bdRho, "rho", 1.0, "Probability of sampling each lineage alive at present")
+	birthdeathCmd.PersistentFlags().BoolVar(&bdComplete, "complete", false, "Keep unsampled and extinct lineages")
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var coalPopSize float64
+var coalGrowth float64
+var coalDates string
+
+// coalescentCmd represents the coalescent command
+var coalescentCmd = &cobra.Command{
+	Use:   "coalescent",
+	Short: "Generates a random coalescent tree",
+	Long: `Generates a random coalescent tree.
+
+Genealogies are simulated backward in time under Kingman's coalescent, with
+a constant population size (--popsize, in time units, e.g. Ne * generation time),
+or an exponentially growing population (--growth g > 0): N(t) = popsize * exp(-g*t),
+t being the time before present.
+
+Tips are either all sampled at present (-l: number of tips, named Tip<i>), or
+serially sampled, with sampling dates given in a file (--dates: one line per tip,
+tab separated: tipname\tdate, dates being forward in time, e.g. in years).
+
+Output trees are rooted, and their branch lengths are in time units.
+
+Example:
+gotree generate coalescent -l 100 --popsize 10 --growth 0.5 --seed 10
+gotree generate coalescent --dates dates.txt --popsize 10 -n 100
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var t *tree.Tree
+		var dates map[string]float64
+
+		m := tree.CoalescentModel{PopSize: coalPopSize, Growth: coalGrowth}
+		if coalDates != "none" {
+			if cmd.Flags().Changed("nbtips") {
+				err = errors.New("Number of tips (-l) and sampling dates (--dates) cannot be given together")
+				io.LogError(err)
+				return
+			}
+			if dates, err = parseTipValues(coalDates); err != nil {
+				io.LogError(err)
+				return
+			}
+		}
+
+		if f, err = openWriteFile(generateOutputfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, generateOutputfile)
+
+		for i := 0; i < generateNbTrees; i++ {
+			if dates != nil {
+				t, err = tree.RandomSerialCoalescentTree(m, dates)
+			} else {
+				t, err = tree.RandomCoalescentTree(m, generateNbTips)
+			}
+			if err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Newick() + "\n")
+		}
+		return
+	},
+}
+
+func init() {
+	generateCmd.AddCommand(coalescentCmd)
+	coalescentCmd.PersistentFlags().IntVarP(&generateNbTips, "nbtips", "l", 10, "Number of tips/leaves of the tree to generate")
+	coalescentCmd.PersistentFlags().Float64Var(&coalPopSize, "popsize", 1.0, "Effective population size at present, in time units")
+	coalescentCmd.PersistentFlags().Float64Var(&coalGrowth, "growth", 0.0, "Exponential growth rate of the population")
+	coalescentCmd.PersistentFlags().StringVar(&coalDates, "dates", "none", "Tip sampling date file (One line per tip, tab separated: tipname\\tdate)")
+}
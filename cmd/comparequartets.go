@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var comparequartetsdist bool
+
+// compareQuartetsCmd represents the compare quartets command
+var compareQuartetsCmd = &cobra.Command{
+	Use:   "quartets",
+	Short: "Compare quartets of a reference tree with a set of trees",
+	Long: `Compare quartets of a reference tree with a set of trees.
+
+Each quartet of tips {a,b,c,d} is either resolved (e.g. ab|cd) or unresolved
+in each tree (multifurcated trees are allowed).
+
+For each tree in the compared tree file, it will print tab separated values with:
+1) The index of the compared tree in the file
+2) The number of quartets resolved the same way in both trees
+3) The number of quartets resolved differently in both trees
+4) The number of quartets resolved in the reference tree and unresolved in the compared tree
+5) The number of quartets resolved in the compared tree and unresolved in the reference tree
+6) The number of quartets unresolved in both trees
+7) The quartet distance: 3)+4)+5)
+
+If --dist is given, it only prints the quartet distance.
+
+Quartets are not enumerated, so that trees with several thousands of tips
+can be compared (time complexity in O(n^2) for trees of bounded degree).
+Trees must have the same set of tips.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var refTree *tree.Tree
+		var stats <-chan tree.QuartetStats
+
+		if intree2file == "none" {
+			err = errors.New("You must provide a file containing compared trees")
+			io.LogError(err)
+			return
+		}
+
+		maxcpus := runtime.NumCPU()
+		if rootCpus > maxcpus {
+			rootCpus = maxcpus
+		}
+		if refTree, err = readTree(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if treefile, treechan, err = readTrees(intree2file); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+		if stats, err = tree.CompareQuartets(refTree, treechan, rootCpus); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if !comparequartetsdist {
+			fmt.Printf("tree\tcommon\tconflict\treference\tcompared\tunresolved\tdistance\n")
+		}
+		for st := range stats {
+			if st.Err != nil {
+				/* We empty the channel if needed*/
+				for range stats {
+				}
+				io.LogError(st.Err)
+				return st.Err
+			}
+			if comparequartetsdist {
+				fmt.Printf("%d\n", st.Distance())
+			} else {
+				fmt.Printf("%d\t%d\t%d\t%d\t%d\t%d\t%d\n", st.Id, st.Common, st.Conflict, st.RefResolved, st.CompResolved, st.Unresolved, st.Distance())
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	compareCmd.AddCommand(compareQuartetsCmd)
+	compareQuartetsCmd.Flags().BoolVar(&comparequartetsdist, "dist", false, "If true, only outputs the quartet distance (number of quartets not resolved the same way in both trees)")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	goio "io"
 	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -14,6 +15,12 @@ import (
 
 var comparetreeidentical bool
 var comparetreerf bool
+var comparetreeweightedrf bool
+var comparetreebranchscore bool
+var comparetreepath bool
+var comparetreepatristic bool
+var comparetreematchingsplit bool
+var comparetreegeneralizedrf bool
 
 // compareCmd represents the compare command
 var compareTreesCmd = &cobra.Command{
@@ -36,12 +43,34 @@ For each trees in the compared tree file, it will print tab separated values wit
 
 If --rf is given, it only computes the Robinson-Foulds distance, as the sum of 
 reference + compared specific branches.
+
+Other distances may be computed instead, with the following options (several can be
+given, and the output has one column per distance, in this order):
+--weighted-rf    : Weighted Robinson-Foulds distance: sum over all the branches of both
+                   trees of the absolute differences of their lengths (0 if absent);
+--branch-score   : Kuhner-Felsenstein branch score distance: square root of the sum of
+                   the squared differences of branch lengths;
+--path           : Path difference (Steel & Penny, 1993): square root of the sum, over all
+                   pairs of tips, of the squared differences of the number of branches
+                   between them;
+--patristic      : Same as --path with patristic distances;
+--matching-split : Matching split distance (Bogdanowicz & Giaro, 2012): minimum number of
+                   tips to move, over all matchings of internal branches of both trees;
+--generalized-rf : Generalized Robinson-Foulds distance: clustering information distance
+                   (Smith, 2020), in bits.
+
+These distances consider trees as unrooted, and tip branches are taken into account
+by --weighted-rf and --branch-score. --weighted-rf, --branch-score and --patristic require
+branch lengths. --matching-split and --generalized-rf run in O(n^3), n being the number
+of tips.
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var treefile goio.Closer
 		var treechan <-chan tree.Trees
 		var refTree *tree.Tree
 		var stats <-chan tree.BipartitionStats
+		var distances []int
+		var names []string
 
 		if intree2file == "none" {
 			err = errors.New("You must provide a file containing compared trees")
@@ -49,6 +78,29 @@ reference + compared specific branches.
 			return
 		}
 
+		for _, d := range []struct {
+			set      bool
+			distance int
+			name     string
+		}{
+			{comparetreeweightedrf, tree.DISTANCE_WEIGHTED_RF, "weighted_rf"},
+			{comparetreebranchscore, tree.DISTANCE_BRANCH_SCORE, "branch_score"},
+			{comparetreepath, tree.DISTANCE_PATH, "path"},
+			{comparetreepatristic, tree.DISTANCE_PATRISTIC, "patristic"},
+			{comparetreematchingsplit, tree.DISTANCE_MATCHING_SPLIT, "matching_split"},
+			{comparetreegeneralizedrf, tree.DISTANCE_GENERALIZED_RF, "generalized_rf"},
+		} {
+			if d.set {
+				distances = append(distances, d.distance)
+				names = append(names, d.name)
+			}
+		}
+		if len(distances) > 0 && (comparetreeidentical || comparetreerf) {
+			err = errors.New("--binary and --rf cannot be combined with other distances")
+			io.LogError(err)
+			return
+		}
+
 		maxcpus := runtime.NumCPU()
 		if rootCpus > maxcpus {
 			rootCpus = maxcpus
@@ -67,6 +119,11 @@ reference + compared specific branches.
 			return
 		}
 		defer treefile.Close()
+
+		if len(distances) > 0 {
+			return compareTreeDistances(refTree, treechan, distances, names)
+		}
+
 		if stats, err = tree.Compare(refTree, treechan, compareTips, comparetreeidentical, rootCpus); err != nil {
 			io.LogError(err)
 			return
@@ -112,9 +169,42 @@ reference + compared specific branches.
 	},
 }
 
+// Prints the given distances between the reference tree and the compared trees
+func compareTreeDistances(refTree *tree.Tree, treechan <-chan tree.Trees, distances []int, names []string) (err error) {
+	var stats <-chan tree.DistanceStats
+
+	if stats, err = tree.CompareDistances(refTree, treechan, distances, rootCpus); err != nil {
+		io.LogError(err)
+		return
+	}
+
+	fmt.Printf("tree\t%s\n", strings.Join(names, "\t"))
+	for st := range stats {
+		if st.Err != nil {
+			/* We empty the channel if needed*/
+			for range stats {
+			}
+			io.LogError(st.Err)
+			return st.Err
+		}
+		fmt.Printf("%d", st.Id)
+		for _, d := range st.Distances {
+			fmt.Printf("\t%f", d)
+		}
+		fmt.Printf("\n")
+	}
+	return
+}
+
 func init() {
 	compareCmd.AddCommand(compareTreesCmd)
 	compareTreesCmd.Flags().BoolVarP(&compareTips, "tips", "l", false, "Include tips in the comparison")
 	compareTreesCmd.Flags().BoolVar(&comparetreeidentical, "binary", false, "If true, then just print true (identical tree) or false (different tree) for each compared tree")
 	compareTreesCmd.Flags().BoolVar(&comparetreerf, "rf", false, "If true, outputs Robinson-Foulds distance, as the sum of reference + compared specific branches")
+	compareTreesCmd.Flags().BoolVar(&comparetreeweightedrf, "weighted-rf", false, "Outputs the weighted Robinson-Foulds distance")
+	compareTreesCmd.Flags().BoolVar(&comparetreebranchscore, "branch-score", false, "Outputs the Kuhner-Felsenstein branch score distance")
+	compareTreesCmd.Flags().BoolVar(&comparetreepath, "path", false, "Outputs the path difference (number of branches)")
+	compareTreesCmd.Flags().BoolVar(&comparetreepatristic, "patristic", false, "Outputs the path difference (patristic distances)")
+	compareTreesCmd.Flags().BoolVar(&comparetreematchingsplit, "matching-split", false, "Outputs the matching split distance")
+	compareTreesCmd.Flags().BoolVar(&comparetreegeneralizedrf, "generalized-rf", false, "Outputs the generalized Robinson-Foulds (clustering information) distance")
 }
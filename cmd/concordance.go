@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var concordanceGeneTrees string
+var concordanceTsv string
+
+// concordanceCmd represents the concordance command
+var concordanceCmd = &cobra.Command{
+	Use:   "concordance",
+	Short: "Computes gene concordance factors of a species tree given a set of gene trees",
+	Long: `Computes gene concordance factors of a species tree given a set of gene trees.
+
+Gene trees (-g) may have incomplete sets of tips, but all their tips must be present
+in the reference species tree (-i).
+
+Each internal branch of the species tree defines 4 subtrees around it (L1 and L2 on
+one side, R1 and R2 on the other side). For a given branch, a gene tree is decisive
+if it contains at least one tip of each subtree. Then, among the decisive gene trees:
+- gCF : Percentage of gene trees containing the branch (restricted to their tips)
+- gDF1: Percentage of gene trees containing the first NNI alternative (L1R1|L2R2)
+- gDF2: Percentage of gene trees containing the second NNI alternative (L1R2|L2R1)
+- gDFP: Percentage of gene trees containing none of them (paraphyly)
+- gN  : Number of decisive gene trees
+Discordance factors are only computed for branches whose extremities have 3 neighbors.
+
+The output tree has these values as branch attributes ([&gCF=...,gN=...]).
+
+If --tsv is given, it also writes, for each internal branch, tab separated values with:
+1) The index of the branch in the species tree
+2) Its length
+3) Its support
+4) The name of the node on its right
+5) gN
+6) gCF
+7) gDF1
+8) gDF2
+9) gDFP
+
+Example:
+gotree compute concordance -i species.nw -g genes.nw -o annotated.nw --tsv concordance.tsv
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f, tsvf *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var species *tree.Tree
+		var conc []*tree.EdgeConcordance
+
+		if concordanceGeneTrees == "none" {
+			err = errors.New("You must provide a file containing gene trees")
+			io.LogError(err)
+			return
+		}
+
+		if species, err = readTree(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		if err = species.ReinitIndexes(); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if treefile, treechan, err = readTrees(concordanceGeneTrees); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		if conc, err = tree.GeneConcordance(species, treechan); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+		f.WriteString(species.Newick() + "\n")
+
+		if concordanceTsv != "none" {
+			if tsvf, err = openWriteFile(concordanceTsv); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(tsvf, concordanceTsv)
+			fmt.Fprintf(tsvf, "brid\tlength\tsupport\trightname\tgN\tgCF\tgDF1\tgDF2\tgDFP\n")
+			for _, ec := range conc {
+				fmt.Fprintf(tsvf, "%d\t%s\t%s\t%s\t%d\t%f\t%f\t%f\t%f\n",
+					ec.Edge.Id(), ec.Edge.LengthString(), ec.Edge.SupportString(),
+					ec.Edge.Right().Name(), ec.Decisive,
+					ec.GCF(), ec.GDF1(), ec.GDF2(), ec.GDFP())
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	computeCmd.AddCommand(concordanceCmd)
+	concordanceCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Reference species tree input file")
+	concordanceCmd.PersistentFlags().StringVarP(&concordanceGeneTrees, "genes", "g", "none", "Gene trees input file")
+	concordanceCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Annotated species tree output file")
+	concordanceCmd.PersistentFlags().StringVar(&concordanceTsv, "tsv", "none", "Per branch concordance statistics output file")
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	goio "io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/io/utils"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var datingDates string
+var datingDateRegexp string
+var datingCalibrations string
+var datingRate float64
+var datingSeqLen int
+var datingOutStats string
+
+// datingCmd represents the compute dating command
+var datingCmd = &cobra.Command{
+	Use:   "dating",
+	Short: "Converts trees into time trees using least-squares dating",
+	Long: `Converts trees into time trees using least-squares dating (as LSD).
+
+The input trees must be rooted, and have branch lengths in substitutions per site.
+Tip sampling dates are given either:
+- In a file (--dates), with one line per tip: tipname\tdate (tab or comma separated);
+- Or with a regular expression over tip names (--date-regexp), whose first
+  capture group is the date. For example: "_([0-9.]+)$" for tips named
+  "A_2015.3".
+All the tips of the trees must have a date.
+
+Internal nodes may be calibrated (--calibrations), with a file containing one line
+per calibration: tip1,tip2,...\tmin\tmax, where min and max are the minimum
+and maximum dates of the LCA of the given tips, "-" meaning no bound.
+
+The substitution rate and node dates minimize the weighted sum of squared differences
+between branch lengths and rate*durations, under the constraints that branch lengths
+are >= 0 and that calibrated nodes are in their intervals. If --seqlen is given,
+branches are weighted by the inverse of their variance, (b + 1/seqlen)/seqlen.
+If --rate is given, the rate is not estimated.
+
+Outputs:
+-o          : Time trees: branch lengths are durations, and each node has a "date"
+              attribute;
+--out-stats : Substitution rate, date of the root, and weighted sum of squares,
+              one line per tree.
+
+Example:
+
+gotree compute dating -i tree.nw --dates dates.txt --calibrations calibrations.txt -o timetree.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f, statsf *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var dates map[string]float64
+		var re *regexp.Regexp
+		var res *tree.DatingResult
+		var opts tree.DatingOptions
+
+		if datingDates != "none" && datingDateRegexp != "none" {
+			err = errors.New("--dates and --date-regexp are mutually exclusive")
+			io.LogError(err)
+			return
+		} else if datingDates != "none" {
+			if dates, err = parseTipValues(datingDates); err != nil {
+				io.LogError(err)
+				return
+			}
+		} else if datingDateRegexp != "none" {
+			if re, err = regexp.Compile(datingDateRegexp); err != nil {
+				io.LogError(err)
+				return
+			}
+			if re.NumSubexp() < 1 {
+				err = errors.New("The date regular expression must have a capture group")
+				io.LogError(err)
+				return
+			}
+		} else {
+			err = errors.New("Tip dates must be given with --dates or --date-regexp")
+			io.LogError(err)
+			return
+		}
+
+		opts.Rate, opts.SeqLen = datingRate, datingSeqLen
+		if datingCalibrations != "none" {
+			if opts.Calibrations, err = parseCalibrations(datingCalibrations); err != nil {
+				io.LogError(err)
+				return
+			}
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if datingOutStats != "none" {
+			if statsf, err = openWriteFile(datingOutStats); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(statsf, datingOutStats)
+			statsf.WriteString("tree\trate\troot_date\tobjective\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			if re != nil {
+				if dates, err = parseTipDates(t.Tree, re); err != nil {
+					io.LogError(err)
+					return
+				}
+			}
+			if res, err = t.Tree.LeastSquaresDating(dates, opts); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+			if statsf != nil {
+				fmt.Fprintf(statsf, "%d\t%f\t%f\t%f\n", t.Id, res.Rate, res.RootDate, res.Objective)
+			}
+		}
+		return
+	},
+}
+
+// Parses a calibration file: one line per calibration,
+// tip1,tip2,...\tmin\tmax, "-" meaning no bound
+func parseCalibrations(file string) (calibrations []tree.DatingCalibration, err error) {
+	var reader *bufio.Reader
+	var calfile goio.Closer
+	var line string
+	var err2 error
+
+	if calfile, reader, err = utils.GetReader(file); err != nil {
+		return
+	}
+	defer calfile.Close()
+
+	for line, err2 = Readln(reader); err2 == nil; line, err2 = Readln(reader) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		cols := strings.Split(line, "\t")
+		if len(cols) != 3 {
+			err = fmt.Errorf("Bad format for calibration: %s (expected: tip1,tip2,...\\tmin\\tmax)", line)
+			return
+		}
+		c := tree.DatingCalibration{Tips: strings.Split(cols[0], ","), Lower: math.Inf(-1), Upper: math.Inf(1)}
+		for i, bound := range []*float64{&c.Lower, &c.Upper} {
+			if s := strings.TrimSpace(cols[i+1]); s != "-" {
+				if *bound, err = strconv.ParseFloat(s, 64); err != nil {
+					err = fmt.Errorf("Calibration bound is not a number: %s", s)
+					return
+				}
+			}
+		}
+		calibrations = append(calibrations, c)
+	}
+	return
+}
+
+func init() {
+	computeCmd.AddCommand(datingCmd)
+	datingCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input rooted trees")
+	datingCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output time trees")
+	datingCmd.PersistentFlags().StringVar(&datingDates, "dates", "none", "File with tip dates (tipname\\tdate)")
+	datingCmd.PersistentFlags().StringVar(&datingDateRegexp, "date-regexp", "none", "Regular expression extracting dates from tip names (first capture group)")
+	datingCmd.PersistentFlags().StringVar(&datingCalibrations, "calibrations", "none", "File with calibrations of internal nodes (tip1,tip2,...\\tmin\\tmax)")
+	datingCmd.PersistentFlags().Float64Var(&datingRate, "rate", -1, "Substitution rate, estimated if <= 0")
+	datingCmd.PersistentFlags().IntVar(&datingSeqLen, "seqlen", -1, "Alignment length, used to weight branches by their variance (unweighted if <= 0)")
+	datingCmd.PersistentFlags().StringVar(&datingOutStats, "out-stats", "none", "Output file with the rate and the date of the root")
+}
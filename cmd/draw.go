@@ -11,6 +11,7 @@ var drawSupport bool
 var drawSupportCutoff float64
 var drawInternalNodeSymbols bool
 var drawNodeComment bool
+var drawNodeAttribute string
 
 // drawCmd represents the draw command
 var drawCmd = &cobra.Command{
@@ -31,4 +32,5 @@ func init() {
 	drawCmd.PersistentFlags().BoolVar(&drawSupport, "with-branch-support", false, "Highlight highly supported branches")
 	drawCmd.PersistentFlags().Float64Var(&drawSupportCutoff, "support-cutoff", 0.7, "Cutoff for highlithing supported branches")
 	drawCmd.PersistentFlags().BoolVar(&drawNodeComment, "with-node-comments", false, "Draw the tree with internal node comments (if --with-node-labels is not set)")
+	drawCmd.PersistentFlags().StringVar(&drawNodeAttribute, "node-attribute", "", "Draw the tree with the value of the given node attribute (e.g. posterior) instead of node comments (if --with-node-labels is not set)")
 }
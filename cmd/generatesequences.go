@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/goalign/io/fasta"
+	"github.com/evolbioinfo/goalign/io/phylip"
+	"github.com/evolbioinfo/gotree/asr"
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var seqsModel string
+var seqsAlphabet string
+var seqsLength int
+var seqsKappa float64
+var seqsRates string
+var seqsFreqs string
+var seqsAlpha float64
+var seqsPInv float64
+var seqsPhylip bool
+var seqsOutAncestral string
+
+// generateSequencesCmd represents the generate sequences command
+var generateSequencesCmd = &cobra.Command{
+	Use:   "sequences",
+	Short: "Simulates sequences along a tree",
+	Long: `Simulates sequences along a tree.
+
+The input tree must have branch lengths, in expected number of substitutions per site,
+and its root is the root of the simulation. The root sequence is drawn from the
+equilibrium frequencies of the model.
+
+Available models (--model):
+- jc : Jukes-Cantor, for nucleotides or amino acids (--alphabet nt or aa)
+- k80: Kimura 2-parameters, with transition/transversion ratio --kappa
+- hky: HKY, with --kappa and equilibrium frequencies --freqs (A,C,G,T)
+- gtr: GTR, with relative rates --rates (AC,AG,AT,CG,CT,GT) and frequencies --freqs
+If --freqs is not given, frequencies are equal (jc and k80 only accept equal frequencies).
+
+Rate heterogeneity:
+- +G: If --alpha > 0, the rate of each site is drawn from a gamma distribution of mean 1
+      and shape --alpha (continuous gamma);
+- +I: A proportion --pinv of the sites are invariant.
+
+The random number generator is initialized with --seed. If -n > 1, several alignments
+are simulated, and --phylip is required.
+
+Outputs:
+-o              : Alignment of the tips, in Fasta (default) or Phylip (--phylip) format;
+--out-ancestral : Alignment of the internal nodes (true ancestral sequences), named after the
+                  nodes, or "node<id>" if they have no name.
+
+Example:
+gotree generate sequences -i tree.nw --model gtr --rates 1,2,1,1,2,1 --freqs 0.3,0.2,0.2,0.3 --alpha 0.5 --length 1000 --seed 10 -o align.fa
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var t *tree.Tree
+		var f, ancf *os.File
+		var tips, anc align.Alignment
+		var m asr.SimulationModel
+
+		m.Kappa, m.Alpha, m.PInv = seqsKappa, seqsAlpha, seqsPInv
+		switch strings.ToLower(seqsModel) {
+		case "jc":
+			m.Model = asr.MODEL_JC
+		case "k80":
+			m.Model = asr.MODEL_K80
+		case "hky":
+			m.Model = asr.MODEL_HKY
+		case "gtr":
+			m.Model = asr.MODEL_GTR
+			if m.Rates, err = parseFloatList(seqsRates); err != nil {
+				io.LogError(err)
+				return
+			}
+		default:
+			err = fmt.Errorf("Unknown sequence evolution model: %s", seqsModel)
+			io.LogError(err)
+			return
+		}
+		switch strings.ToLower(seqsAlphabet) {
+		case "nt":
+			m.Alphabet = align.NUCLEOTIDS
+		case "aa":
+			m.Alphabet = align.AMINOACIDS
+		default:
+			err = fmt.Errorf("Unknown alphabet: %s", seqsAlphabet)
+			io.LogError(err)
+			return
+		}
+		if seqsFreqs != "none" {
+			if m.Frequencies, err = parseFloatList(seqsFreqs); err != nil {
+				io.LogError(err)
+				return
+			}
+		}
+		if generateNbTrees < 1 {
+			err = errors.New("Number of alignments (-n) must be >= 1")
+			io.LogError(err)
+			return
+		}
+		if generateNbTrees > 1 && !seqsPhylip {
+			err = errors.New("Several alignments (-n > 1) can only be written in Phylip format (--phylip)")
+			io.LogError(err)
+			return
+		}
+
+		if t, err = readTree(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		for i, n := range t.Nodes() {
+			if !n.Tip() && n.Name() == "" {
+				n.SetName(fmt.Sprintf("node%d", i))
+			}
+		}
+
+		if f, err = openWriteFile(generateOutputfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, generateOutputfile)
+		if seqsOutAncestral != "none" {
+			if ancf, err = openWriteFile(seqsOutAncestral); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(ancf, seqsOutAncestral)
+		}
+
+		for i := 0; i < generateNbTrees; i++ {
+			if tips, anc, err = asr.SimulateSequences(t, seqsLength, m); err != nil {
+				io.LogError(err)
+				return
+			}
+			writeSimulatedAlignment(f, tips)
+			if ancf != nil {
+				writeSimulatedAlignment(ancf, anc)
+			}
+		}
+		return
+	},
+}
+
+// Writes the alignment in Phylip format if --phylip is given, in Fasta format otherwise
+func writeSimulatedAlignment(f *os.File, a align.Alignment) {
+	if seqsPhylip {
+		f.WriteString(phylip.WriteAlignment(a, false, false, false))
+	} else {
+		f.WriteString(fasta.WriteAlignment(a))
+	}
+}
+
+// Parses a comma separated list of numbers
+func parseFloatList(s string) (values []float64, err error) {
+	var v float64
+	for _, c := range strings.Split(s, ",") {
+		if v, err = strconv.ParseFloat(strings.TrimSpace(c), 64); err != nil {
+			err = fmt.Errorf("%s is not a number", c)
+			return
+		}
+		values = append(values, v)
+	}
+	return
+}
+
+func init() {
+	generateCmd.AddCommand(generateSequencesCmd)
+	generateSequencesCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
+	generateSequencesCmd.PersistentFlags().StringVar(&seqsModel, "model", "jc", "Sequence evolution model: jc, k80, hky, or gtr")
+	generateSequencesCmd.PersistentFlags().StringVar(&seqsAlphabet, "alphabet", "nt", "Alphabet: nt (nucleotides) or aa (amino acids, jc model only)")
+	generateSequencesCmd.PersistentFlags().IntVar(&seqsLength, "length", 1000, "Length of the simulated sequences")
+	generateSequencesCmd.PersistentFlags().Float64Var(&seqsKappa, "kappa", 2.0, "Transition/transversion ratio (k80 and hky)")
+	generateSequencesCmd.PersistentFlags().StringVar(&seqsRates, "rates", "1,1,1,1,1,1", "Comma separated relative rates AC,AG,AT,CG,CT,GT (gtr)")
+	generateSequencesCmd.PersistentFlags().StringVar(&seqsFreqs, "freqs", "none", "Comma separated equilibrium frequencies of A,C,G,T (hky and gtr)")
+	generateSequencesCmd.PersistentFlags().Float64Var(&seqsAlpha, "alpha", -1, "Shape of the gamma distribution of site rates, no rate heterogeneity if <= 0")
+	generateSequencesCmd.PersistentFlags().Float64Var(&seqsPInv, "pinv", 0, "Proportion of invariant sites")
+	generateSequencesCmd.PersistentFlags().BoolVar(&seqsPhylip, "phylip", false, "Write alignments in Phylip format")
+	generateSequencesCmd.PersistentFlags().StringVar(&seqsOutAncestral, "out-ancestral", "none", "Output file with the alignment of internal nodes")
+}
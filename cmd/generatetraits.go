@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/acr"
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var traitsModel string
+var traitsAlphabet string
+var traitsRate float64
+var traitsRateMatrix string
+var traitsRootState string
+var traitsSigma2 float64
+var traitsAlpha float64
+var traitsTheta float64
+var traitsRootValue float64
+var traitsOutStates string
+var traitsOutTree string
+
+// generateTraitsCmd represents the generate traits command
+var generateTraitsCmd = &cobra.Command{
+	Use:   "traits",
+	Short: "Simulates tip traits along a tree",
+	Long: `Simulates tip traits along a tree.
+
+The input tree must have branch lengths, and its root is the root of the simulation.
+Several models are available (--model):
+- mk: Discrete character evolving under the Mk model with states given by --alphabet
+      (comma separated), and rate --rate (expected number of changes per unit of branch
+      length, same parameterization as gotree acr --algo ml --model jc);
+      If --rate-matrix is given, then it contains the instantaneous rates of change
+      between states: its first line contains the states, and each following line
+      contains a state followed by the rates of change to each other state, separated
+      by tabs or commas (diagonal is ignored). The root state is given with --root-state,
+      or drawn uniformly at random if not given;
+- bm: Continuous character evolving under Brownian motion with rate --sigma2, starting
+      from --root-value;
+- ou: Continuous character evolving under an Ornstein-Uhlenbeck process with rate --sigma2,
+      strength of selection --alpha, and optimum --theta, starting from --root-value.
+
+The random number generator is initialized with --seed.
+
+Outputs:
+-o           : Tip states, one line per tip (tipname\tstate), as read by gotree acr --states;
+               If -n > 1 independent traits are simulated, then a trait matrix (first line:
+               tip\ttrait1\ttrait2..., then one line per tip) as read by gotree acr --traits;
+--out-states : True ancestral states of internal nodes, in the same format;
+--out-tree   : Input tree, with internal nodes without name named "node<id>", as in the
+               --out-states file.
+
+Example:
+gotree generate traits -i tree.nw --model mk --alphabet A,B,C --rate 0.5 --seed 10 -o states.txt --out-states true_states.txt
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var t *tree.Tree
+		var alphabet []string
+		var rates [][]float64
+		var f, statesf, treef *os.File
+		var traits []map[string]string
+
+		model := strings.ToLower(traitsModel)
+		switch model {
+		case "mk":
+			if traitsRateMatrix != "none" {
+				if alphabet, rates, err = parseCostMatrix(traitsRateMatrix); err != nil {
+					io.LogError(err)
+					return
+				}
+			} else {
+				alphabet = strings.Split(traitsAlphabet, ",")
+				rates = acr.MkRates(len(alphabet), traitsRate)
+			}
+		case "bm", "ou":
+		default:
+			err = fmt.Errorf("Unknown trait model: %s", traitsModel)
+			io.LogError(err)
+			return
+		}
+		if generateNbTrees < 1 {
+			err = errors.New("Number of traits (-n) must be >= 1")
+			io.LogError(err)
+			return
+		}
+
+		if t, err = readTree(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		// Internal nodes are named so that they can be identified in the output files
+		for i, n := range t.Nodes() {
+			if !n.Tip() && n.Name() == "" {
+				n.SetName(fmt.Sprintf("node%d", i))
+			}
+		}
+
+		for i := 0; i < generateNbTrees; i++ {
+			var states map[string]string
+			switch model {
+			case "mk":
+				states, err = acr.SimulateDiscrete(t, alphabet, rates, traitsRootState)
+			case "bm", "ou":
+				var values map[string]float64
+				alpha := 0.0
+				if model == "ou" {
+					alpha = traitsAlpha
+				}
+				if values, err = acr.SimulateContinuous(t, traitsRootValue, traitsSigma2, alpha, traitsTheta); err == nil {
+					states = make(map[string]string, len(values))
+					for k, v := range values {
+						states[k] = fmt.Sprintf("%f", v)
+					}
+				}
+			}
+			if err != nil {
+				io.LogError(err)
+				return
+			}
+			traits = append(traits, states)
+		}
+
+		if f, err = openWriteFile(generateOutputfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, generateOutputfile)
+		writeSimulatedTraits(f, "tip", t.Tips(), traits)
+
+		if traitsOutStates != "none" {
+			if statesf, err = openWriteFile(traitsOutStates); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(statesf, traitsOutStates)
+			internals := make([]*tree.Node, 0)
+			for _, n := range t.Nodes() {
+				if !n.Tip() {
+					internals = append(internals, n)
+				}
+			}
+			writeSimulatedTraits(statesf, "node", internals, traits)
+		}
+
+		if traitsOutTree != "none" {
+			if treef, err = openWriteFile(traitsOutTree); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(treef, traitsOutTree)
+			treef.WriteString(t.Newick() + "\n")
+		}
+		return
+	},
+}
+
+// Writes the simulated states of the given nodes: one line per node, tab separated.
+// If there are several traits, a header line "<first>\ttrait1\ttrait2..." is written first.
+func writeSimulatedTraits(f *os.File, first string, nodes []*tree.Node, traits []map[string]string) {
+	if len(traits) > 1 {
+		f.WriteString(first)
+		for i := range traits {
+			fmt.Fprintf(f, "\ttrait%d", i+1)
+		}
+		f.WriteString("\n")
+	}
+	for _, n := range nodes {
+		f.WriteString(n.Name())
+		for _, states := range traits {
+			fmt.Fprintf(f, "\t%s", states[n.Name()])
+		}
+		f.WriteString("\n")
+	}
+}
+
+func init() {
+	generateCmd.AddCommand(generateTraitsCmd)
+	generateTraitsCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
+	generateTraitsCmd.PersistentFlags().StringVar(&traitsModel, "model", "mk", "Trait evolution model: mk, bm, or ou")
+	generateTraitsCmd.PersistentFlags().StringVar(&traitsAlphabet, "alphabet", "0,1", "Comma separated states (--model mk)")
+	generateTraitsCmd.PersistentFlags().Float64Var(&traitsRate, "rate", 1.0, "Expected number of changes per unit of branch length (--model mk)")
+	generateTraitsCmd.PersistentFlags().StringVar(&traitsRateMatrix, "rate-matrix", "none", "Rate matrix file, instead of --alphabet and --rate (--model mk)")
+	generateTraitsCmd.PersistentFlags().StringVar(&traitsRootState, "root-state", "", "State of the root, random if not given (--model mk)")
+	generateTraitsCmd.PersistentFlags().Float64Var(&traitsSigma2, "sigma2", 1.0, "Rate of the process (--model bm and ou)")
+	generateTraitsCmd.PersistentFlags().Float64Var(&traitsAlpha, "alpha", 1.0, "Strength of selection (--model ou)")
+	generateTraitsCmd.PersistentFlags().Float64Var(&traitsTheta, "theta", 0.0, "Optimum (--model ou)")
+	generateTraitsCmd.PersistentFlags().Float64Var(&traitsRootValue, "root-value", 0.0, "Value at the root (--model bm and ou)")
+	generateTraitsCmd.PersistentFlags().StringVar(&traitsOutStates, "out-states", "none", "Output file with the true states of internal nodes")
+	generateTraitsCmd.PersistentFlags().StringVar(&traitsOutTree, "out-tree", "none", "Output tree file with named internal nodes")
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var rerootOutScores string
+var madOutStats string
+
+// madCmd represents the reroot mad command
+var madCmd = &cobra.Command{
+	Use:   "mad",
+	Short: "Reroot trees using the minimal ancestor deviation method",
+	Long: `Reroot trees using the minimal ancestor deviation method (MAD, Tria et al., 2017).
+
+For a root position, the ancestor deviation of a pair of tips is the relative deviation
+of their LCA from the middle of the path between them. The root is placed at the position
+minimizing the root mean square of the ancestor deviations of all pairs of tips.
+Input trees must have branch lengths. Unlike midpoint rooting, MAD is robust to rate
+heterogeneity among lineages.
+
+If --out-stats is given, the root ambiguity index of each tree (ratio of the best score
+to the second best score over all branches) is written to the given file, with the
+columns tree and ambiguity: the closer it is to 1, the more ambiguous the root position.
+
+If --out-scores is given, the score of the best root position on each branch of the
+unrooted tree is written to the given file, with the following columns:
+tree, brid (index of the branch in the unrooted tree), length, righttips (number of
+tips on the right side of the branch), rightname (name of the right node), position
+(distance of the best root position from the right node), and score.
+
+Computations are in O(n²*h), n being the number of tips, and h the mean number
+of branches between pairs of tips.
+
+Example:
+
+gotree reroot mad -i tree.nw --out-scores scores.txt --out-stats stats.txt > reroot.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f, scoresf, statsf *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var scores []tree.RootScore
+		var ambiguity float64
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if rerootOutScores != "none" {
+			if scoresf, err = openWriteFile(rerootOutScores); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(scoresf, rerootOutScores)
+			scoresf.WriteString("tree\tbrid\tlength\trighttips\trightname\tposition\tscore\n")
+		}
+
+		if madOutStats != "none" {
+			if statsf, err = openWriteFile(madOutStats); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(statsf, madOutStats)
+			statsf.WriteString("tree\tambiguity\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			if scores, ambiguity, err = t.Tree.RerootMAD(); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+			if scoresf != nil {
+				writeRootScores(scoresf, t.Id, scores)
+			}
+			if statsf != nil {
+				fmt.Fprintf(statsf, "%d\t%f\n", t.Id, ambiguity)
+			}
+		}
+		return
+	},
+}
+
+// Writes the score of the best root position on each branch
+func writeRootScores(f *os.File, id int, scores []tree.RootScore) {
+	for _, s := range scores {
+		fmt.Fprintf(f, "%d\t%d\t%f\t%d\t%s\t%f\t%f\n", id, s.Id, s.Length, s.RightTips, s.RightName, s.Position, s.Score)
+	}
+}
+
+func init() {
+	rerootCmd.AddCommand(madCmd)
+	madCmd.PersistentFlags().StringVar(&rerootOutScores, "out-scores", "none", "Output file with the score of each branch")
+	madCmd.PersistentFlags().StringVar(&madOutStats, "out-stats", "none", "Output file with the root ambiguity index of each tree")
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+// mccCmd represents the mcc command
+var mccCmd = &cobra.Command{
+	Use:   "mcc",
+	Short: "Computes the maximum clade credibility tree of a set of trees",
+	Long: `Computes the maximum clade credibility (MCC) tree of a set of input trees
+(e.g. a posterior sample of trees from BEAST or MrBayes).
+Trees must have the same tip names.
+
+The first trees may be discarded as burn-in (--burnin):
+- If < 1 : Fraction of the input trees to discard
+- If >= 1: Number of input trees to discard
+Input trees may also be thinned with --thin.
+
+The posterior probability of a clade is the proportion of remaining trees
+in which it is present. The MCC tree is the sampled tree that maximizes
+the product of its clade posterior probabilities.
+
+In the output MCC tree, nodes are annotated with the following attributes:
+1) posterior: posterior probability of the clade (internal nodes)
+2) height, height_mean, height_median, height_95%_HPD: height of the node
+   in the MCC tree, and mean, median, and 95% HPD interval of the heights
+   of the clade over all trees in which it is present
+3) length, length_mean, length_median, length_95%_HPD: the same for the
+   branch leading to the node
+
+The index of the MCC tree and its log clade credibility are printed
+on stderr.
+
+Example:
+gotree compute mcc -i trees.nexus --format nexus --burnin 0.1 -o mcc.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var mcc *tree.Tree
+		var mccid int
+		var lcc float64
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+		// Burn-in and thinning (global --burnin and --thin) are done
+		// while reading the trees
+		if mcc, mccid, lcc, err = tree.MaxCladeCredibility(treechan); err != nil {
+			io.LogError(err)
+			return
+		}
+		io.LogInfo(fmt.Sprintf("MCC tree: %d, log clade credibility: %f", mccid, lcc))
+		f.WriteString(mcc.Newick() + "\n")
+		return
+	},
+}
+
+func init() {
+	computeCmd.AddCommand(mccCmd)
+	mccCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input trees")
+	mccCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file")
+}
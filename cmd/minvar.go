@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+// minvarCmd represents the reroot minvar command
+var minvarCmd = &cobra.Command{
+	Use:   "minvar",
+	Short: "Reroot trees using the minimum variance method",
+	Long: `Reroot trees using the minimum variance method (MinVar, Mai et al., 2017).
+
+The root is placed at the position minimizing the variance of root-to-tip
+distances. Input trees must have branch lengths.
+
+If --out-scores is given, the score (variance of root-to-tip distances) of the
+best root position on each branch of the unrooted tree is written to the given
+file, with the following columns: tree, brid (index of the branch in the unrooted
+tree), length, righttips (number of tips on the right side of the branch), rightname
+(name of the right node), position (distance of the best root position from the right
+node), and score.
+
+Example:
+
+gotree reroot minvar -i tree.nw --out-scores scores.txt > reroot.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f, scoresf *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var scores []tree.RootScore
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if rerootOutScores != "none" {
+			if scoresf, err = openWriteFile(rerootOutScores); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(scoresf, rerootOutScores)
+			scoresf.WriteString("tree\tbrid\tlength\trighttips\trightname\tposition\tscore\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			if scores, err = t.Tree.RerootMinVar(); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+			if scoresf != nil {
+				writeRootScores(scoresf, t.Id, scores)
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	rerootCmd.AddCommand(minvarCmd)
+	minvarCmd.PersistentFlags().StringVar(&rerootOutScores, "out-scores", "none", "Output file with the score of each branch")
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/io/utils"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var distanceNegative string
+
+// njCmd represents the nj command
+var njCmd = &cobra.Command{
+	Use:   "nj",
+	Short: "Infers a tree from a distance matrix using Neighbor-Joining",
+	Long: `Infers a tree from a distance matrix using Neighbor-Joining (Saitou & Nei, 1987).
+
+The input matrix is in PHYLIP format: the first line contains the number of taxa,
+and each following row contains the name of a taxon followed by its distances.
+The matrix may be square (as output by gotree matrix) or lower-triangular
+(with or without diagonal).
+
+The output tree is unrooted.
+
+Negative branch lengths may be (--negative):
+- keep    : Kept as is (default)
+- zero    : Set to 0
+- transfer: Set to 0, the difference being transfered to the sister branch
+
+Example:
+gotree matrix -i tree.nw | gotree compute nj --negative zero -o nj.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		return distanceTree(func(names []string, matrix [][]float64, negative int) (*tree.Tree, error) {
+			return tree.NeighborJoining(names, matrix, negative)
+		})
+	},
+}
+
+// Reads the input distance matrix, infers the tree with the given method,
+// and writes it in the output file
+func distanceTree(method func(names []string, matrix [][]float64, negative int) (*tree.Tree, error)) (err error) {
+	var f *os.File
+	var names []string
+	var matrix [][]float64
+	var t *tree.Tree
+	var negative int
+
+	switch strings.ToLower(distanceNegative) {
+	case "keep":
+		negative = tree.NEGATIVE_KEEP
+	case "zero":
+		negative = tree.NEGATIVE_ZERO
+	case "transfer":
+		negative = tree.NEGATIVE_TRANSFER
+	default:
+		err = fmt.Errorf("Unknown negative branch length handling: %s", distanceNegative)
+		io.LogError(err)
+		return
+	}
+
+	if names, matrix, err = utils.ReadDistanceMatrix(intreefile); err != nil {
+		io.LogError(err)
+		return
+	}
+
+	if t, err = method(names, matrix, negative); err != nil {
+		io.LogError(err)
+		return
+	}
+
+	if f, err = openWriteFile(outtreefile); err != nil {
+		io.LogError(err)
+		return
+	}
+	defer closeWriteFile(f, outtreefile)
+	f.WriteString(t.Newick() + "\n")
+	return
+}
+
+func init() {
+	computeCmd.AddCommand(njCmd)
+	njCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input distance matrix (PHYLIP format)")
+	njCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output tree")
+	njCmd.Flags().StringVar(&distanceNegative, "negative", "keep", "Handling of negative branch lengths: keep, zero, or transfer")
+}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	goio "io"
 	"os"
+	"strings"
 
 	"github.com/evolbioinfo/gotree/io"
 	"github.com/evolbioinfo/gotree/tree"
 	"github.com/spf13/cobra"
 )
 
+var statsNodeAttributes string
+
 // nodesCmd represents the nodes command
 var nodesCmd = &cobra.Command{
 	Use:   "nodes",
@@ -21,10 +24,17 @@ Statistics are displayed in text format (tab separated):
 2 - Nb neighbors
 3 - Name of node
 4 - depth of node (shortest path to a tip)
+5 - comments of node
+
+If --attributes is given (comma separated list of keys, e.g. 
+height,height_95%_HPD,posterior), then one column per given key
+is added, with the value of the node attribute (N/A if the node 
+does not have it).
 
 Example of usage:
 
 gotree stats nodes -i t.nw
+gotree stats nodes --format nexus --attributes height,posterior -i beast.nexus
 
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
@@ -38,7 +48,16 @@ gotree stats nodes -i t.nw
 		}
 		defer closeWriteFile(f, outtreefile)
 
-		f.WriteString("tree\tnid\tnneigh\tname\tdepth\tcomments\n")
+		var attributes []string
+		if statsNodeAttributes != "" {
+			attributes = strings.Split(statsNodeAttributes, ",")
+		}
+
+		f.WriteString("tree\tnid\tnneigh\tname\tdepth\tcomments")
+		for _, k := range attributes {
+			f.WriteString("\t" + k)
+		}
+		f.WriteString("\n")
 		var depth int
 		if treefile, treechan, err = readTrees(intreefile); err != nil {
 			io.LogError(err)
@@ -57,7 +76,15 @@ gotree stats nodes -i t.nw
 					io.LogError(err)
 					return
 				}
-				f.WriteString(fmt.Sprintf("%d\t%d\t%d\t%s\t%d\t%s\n", t.Id, i, n.Nneigh(), n.Name(), depth, n.CommentsString()))
+				f.WriteString(fmt.Sprintf("%d\t%d\t%d\t%s\t%d\t%s", t.Id, i, n.Nneigh(), n.Name(), depth, n.CommentsString()))
+				for _, k := range attributes {
+					if a, ok := n.Attribute(k); ok {
+						f.WriteString("\t" + a.String())
+					} else {
+						f.WriteString("\tN/A")
+					}
+				}
+				f.WriteString("\n")
 			}
 		}
 		return
@@ -66,4 +93,5 @@ gotree stats nodes -i t.nw
 
 func init() {
 	statsCmd.AddCommand(nodesCmd)
+	nodesCmd.Flags().StringVar(&statsNodeAttributes, "attributes", "", "Comma separated list of node attributes to display (e.g. height,posterior)")
 }
@@ -69,7 +69,8 @@ var pngCmd = &cobra.Command{
 				l = draw.NewNormalLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
 			}
 			l.SetDisplayInternalNodes(drawInternalNodeSymbols)
-			l.SetDisplayNodeComments(drawNodeComment)
+			l.SetDisplayNodeComments(drawNodeComment || drawNodeAttribute != "")
+			l.SetNodeAttribute(drawNodeAttribute)
 			l.SetSupportCutoff(drawSupportCutoff)
 			l.DrawTree(t.Tree)
 			closeWriteFile(f, fname)
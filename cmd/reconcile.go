@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var reconcileSpeciesTree string
+var reconcileMap string
+var reconcileRegexp string
+var reconcileRoot bool
+var reconcileOutStats string
+var reconcileOutBranches string
+
+// reconcileCmd represents the compute reconcile command
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Reconciles gene trees with a species tree",
+	Long: `Reconciles gene trees with a species tree, using the LCA mapping.
+
+Each node of the gene trees is mapped on the LCA, in the species tree, of the species of
+its tips. An internal node mapped on the same species tree node as one of its children
+is a duplication, otherwise it is a speciation. Gene trees and the species tree (-s) must
+be rooted and binary.
+
+The species of each gene tree tip is given either:
+- In a map file (--map), with one line per gene: genename\tspeciesname;
+- Or with a regular expression over tip names (--species-regexp), whose first
+  capture group is the species. For example: "_([^_]+)$" for tips named
+  "gene1_HUMAN".
+
+If --root is given, gene trees are (re)rooted at the position minimizing the number
+of duplications, and then the number of losses.
+
+Outputs:
+-o             : Gene trees, whose internal nodes have an "event" attribute
+                 (speciation or duplication), and whose nodes have a "species" attribute
+                 (name of the species tree node on which they are mapped, if any);
+--out-stats    : Numbers of duplications and losses, one line per gene tree;
+--out-branches : Numbers of duplications and losses on each branch of the species tree,
+                 one line per gene tree and species tree node (pre-order index, name, and
+                 species under the node).
+
+Example:
+
+gotree compute reconcile -i genetrees.nw -s species.nw --species-regexp '_([^_]+)$' --root --out-branches branches.txt -o reconciled.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f, statsf, branchesf *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var speciestree *tree.Tree
+		var species map[string]string
+		var re *regexp.Regexp
+		var rec *tree.Reconciliation
+
+		if reconcileSpeciesTree == "none" {
+			err = errors.New("A species tree must be given with -s")
+			io.LogError(err)
+			return
+		}
+		if speciestree, err = readTree(reconcileSpeciesTree); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if reconcileMap != "none" && reconcileRegexp != "none" {
+			err = errors.New("--map and --species-regexp are mutually exclusive")
+			io.LogError(err)
+			return
+		} else if reconcileMap != "none" {
+			if species, err = readMapFile(reconcileMap, false); err != nil {
+				io.LogError(err)
+				return
+			}
+		} else if reconcileRegexp != "none" {
+			if re, err = regexp.Compile(reconcileRegexp); err != nil {
+				io.LogError(err)
+				return
+			}
+			if re.NumSubexp() < 1 {
+				err = errors.New("The species regular expression must have a capture group")
+				io.LogError(err)
+				return
+			}
+		} else {
+			err = errors.New("Species of gene tree tips must be given with --map or --species-regexp")
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if reconcileOutStats != "none" {
+			if statsf, err = openWriteFile(reconcileOutStats); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(statsf, reconcileOutStats)
+			statsf.WriteString("tree\tduplications\tlosses\n")
+		}
+
+		if reconcileOutBranches != "none" {
+			if branchesf, err = openWriteFile(reconcileOutBranches); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(branchesf, reconcileOutBranches)
+			branchesf.WriteString("tree\tnode\tname\tspecies\tduplications\tlosses\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			if re != nil {
+				if species, err = parseTipSpecies(t.Tree, re); err != nil {
+					io.LogError(err)
+					return
+				}
+			}
+			if reconcileRoot {
+				if err = t.Tree.RootDuplications(speciestree, species); err != nil {
+					io.LogError(err)
+					return
+				}
+			}
+			if rec, err = t.Tree.Reconcile(speciestree, species); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+			if statsf != nil {
+				fmt.Fprintf(statsf, "%d\t%d\t%d\n", t.Id, rec.Duplications, rec.Losses)
+			}
+			if branchesf != nil {
+				for i, b := range rec.Branches {
+					name := b.Node.Name()
+					if name == "" {
+						name = "-"
+					}
+					fmt.Fprintf(branchesf, "%d\t%d\t%s\t%s\t%d\t%d\n", t.Id, i, name, strings.Join(b.Species, ","), b.Duplications, b.Losses)
+				}
+			}
+		}
+		return
+	},
+}
+
+// Extracts the species of each tip of the tree from its name, using the
+// first capture group of the given regular expression
+func parseTipSpecies(t *tree.Tree, re *regexp.Regexp) (species map[string]string, err error) {
+	species = make(map[string]string)
+	for _, tip := range t.Tips() {
+		m := re.FindStringSubmatch(tip.Name())
+		if m == nil {
+			err = fmt.Errorf("Tip %s does not match the species regular expression", tip.Name())
+			return
+		}
+		species[tip.Name()] = m[1]
+	}
+	return
+}
+
+func init() {
+	computeCmd.AddCommand(reconcileCmd)
+	reconcileCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input gene trees")
+	reconcileCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output reconciled gene trees")
+	reconcileCmd.PersistentFlags().StringVarP(&reconcileSpeciesTree, "species-tree", "s", "none", "Rooted species tree")
+	reconcileCmd.PersistentFlags().StringVar(&reconcileMap, "map", "none", "Map file with the species of each gene (genename\\tspeciesname)")
+	reconcileCmd.PersistentFlags().StringVar(&reconcileRegexp, "species-regexp", "none", "Regular expression extracting species from tip names (first capture group)")
+	reconcileCmd.PersistentFlags().BoolVar(&reconcileRoot, "root", false, "Roots gene trees minimizing the number of duplications")
+	reconcileCmd.PersistentFlags().StringVar(&reconcileOutStats, "out-stats", "none", "Output file with the numbers of duplications and losses")
+	reconcileCmd.PersistentFlags().StringVar(&reconcileOutBranches, "out-branches", "none", "Output file with the numbers of duplications and losses on each species tree branch")
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var reformatnhx bool
+
 // newickCmd represents the newick command
 var newickCmd = &cobra.Command{
 	Use:   "newick",
@@ -16,7 +18,10 @@ var newickCmd = &cobra.Command{
 	Long: `Reformats an input tree file into Newick format.
 
 - Input formats: Newick, Nexus,
-- Output format: Newick.`,
+- Output format: Newick.
+
+If --nhx is given, node and edge attributes are written in the NHX
+format ([&&NHX:key=value:...]) instead of [&key=value,...].`,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var f *os.File
 		var treefile goio.Closer
@@ -39,7 +44,11 @@ var newickCmd = &cobra.Command{
 				io.LogError(t.Err)
 				return t.Err
 			}
-			f.WriteString(t.Tree.Newick() + "\n")
+			if reformatnhx {
+				f.WriteString(t.Tree.NewickNHX() + "\n")
+			} else {
+				f.WriteString(t.Tree.Newick() + "\n")
+			}
 		}
 		return
 	},
@@ -47,4 +56,5 @@ var newickCmd = &cobra.Command{
 
 func init() {
 	reformatCmd.AddCommand(newickCmd)
+	newickCmd.Flags().BoolVar(&reformatnhx, "nhx", false, "Writes node/edge attributes in the NHX format")
 }
@@ -7,8 +7,8 @@ import (
 // rerootCmd represents the reroot command
 var rerootCmd = &cobra.Command{
 	Use:   "reroot",
-	Short: "Reroot trees using an outgroup or at midpoint",
-	Long: `Reroot trees using an outgroup or at midpoint.
+	Short: "Reroot trees using an outgroup, at midpoint, by minimal ancestor deviation, by minimum variance, or maximizing the temporal signal",
+	Long: `Reroot trees using an outgroup, at midpoint, by minimal ancestor deviation, by minimum variance, or maximizing the temporal signal.
 `,
 }
 
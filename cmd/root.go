@@ -32,6 +32,7 @@ var transferdist bool
 var deepestedge bool
 var edgeformattext bool
 var parsimonyAlgo string
+var parsimonyCosts string
 var compareTips bool
 var tipfile string
 var cutoff float64
@@ -43,6 +44,9 @@ var rootCpus int
 var rootInputFormat string
 var removeoutgroup bool
 var rerootstrict bool
+var rootBurnin float64
+var rootThin int
+var rootRange string
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
@@ -102,6 +106,9 @@ func init() {
 	RootCmd.PersistentFlags().Int64Var(&seed, "seed", -1, "Random Seed: -1 = nano seconds since 1970/01/01 00:00:00")
 	RootCmd.PersistentFlags().IntVarP(&rootCpus, "threads", "t", 1, "Number of threads (Max="+strconv.Itoa(maxcpus)+")")
 	RootCmd.PersistentFlags().StringVar(&rootInputFormat, "format", "newick", "Input tree format (newick, nexus, or phyloxml)")
+	RootCmd.PersistentFlags().Float64Var(&rootBurnin, "burnin", 0, "Multi-tree input: Number (>=1) or fraction (<1) of first trees to discard")
+	RootCmd.PersistentFlags().IntVar(&rootThin, "thin", 1, "Multi-tree input: Keeps one tree every <thin> trees after burn-in")
+	RootCmd.PersistentFlags().StringVar(&rootRange, "range", "", "Multi-tree input: Range of tree indices to keep, of the form start:end (0-based, end excluded)")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -144,9 +151,13 @@ func Readln(r *bufio.Reader) (string, error) {
 func readTrees(infile string) (treefile goio.Closer, treeChannel <-chan tree.Trees, err error) {
 	// Read Tree
 	var treereader *bufio.Reader
+	var selection *utils.TreeSelection
 
+	if selection, err = utils.ParseTreeSelection(rootBurnin, rootThin, rootRange); err != nil {
+		return
+	}
 	if treefile, treereader, err = utils.GetReader(infile); err == nil {
-		treeChannel = utils.ReadMultiTrees(treereader, treeformat)
+		treeChannel = utils.ReadMultiTreesSelection(treereader, treeformat, selection)
 	}
 
 	return
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	goio "io"
+	"math/rand"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var rearrangeRadius int
+var rearrangeRandom int
+var rearrangeNbTrees int
+
+// sprCmd represents the spr command
+var sprCmd = &cobra.Command{
+	Use:   "spr",
+	Short: "Generates SPR neighbors from a given tree",
+	Long: `Generates SPR (Subtree Prune and Regraft) neighbors from a given tree.
+
+For each branch of the input tree, the subtree on each side of the branch
+is pruned and regrafted on other branches of the tree, at a maximum distance
+of --radius branches from the pruning point (0: no limit). With --radius 1,
+it corresponds to NNI.
+
+By default, all SPR neighbors are written (several neighbors may have the same
+topology). If --random k is given (k>0), then k successive SPR moves are chosen
+randomly and applied to each input tree, and the resulting tree is written.
+This is repeated --nb-trees times for each input tree.
+
+Works only on binary trees.
+
+Examples:
+gotree spr -i tree.nw --radius 2 -o neighbors.nw
+gotree spr -i tree.nw --random 5 --nb-trees 100 -o perturbed.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		return rearrangeTrees(&tree.SPRRearranger{Radius: rearrangeRadius})
+	},
+}
+
+// Writes all the neighbors of the input trees given by the rearranger, or
+// if rearrangeRandom > 0, rearrangeNbTrees trees obtained by applying
+// rearrangeRandom random successive moves
+func rearrangeTrees(r tree.Rearranger) (err error) {
+	var f *os.File
+	var treefile goio.Closer
+	var treechan <-chan tree.Trees
+
+	if treefile, treechan, err = readTrees(intreefile); err != nil {
+		io.LogError(err)
+		return
+	}
+	defer treefile.Close()
+
+	if f, err = openWriteFile(outtreefile); err != nil {
+		io.LogError(err)
+		return
+	}
+	defer closeWriteFile(f, outtreefile)
+
+	for t := range treechan {
+		if t.Err != nil {
+			io.LogError(t.Err)
+			return t.Err
+		}
+		if rearrangeRandom > 0 {
+			for i := 0; i < rearrangeNbTrees; i++ {
+				c := t.Tree.Clone()
+				for j := 0; j < rearrangeRandom; j++ {
+					moves := make([]tree.Rearrangement, 0)
+					r.Rearrange(c, func(re tree.Rearrangement) bool {
+						moves = append(moves, re)
+						return true
+					})
+					if len(moves) == 0 {
+						break
+					}
+					if err = moves[rand.Intn(len(moves))].Apply(); err != nil {
+						io.LogError(err)
+						return
+					}
+				}
+				f.WriteString(c.Newick() + "\n")
+			}
+			continue
+		}
+
+		r.Rearrange(t.Tree, func(re tree.Rearrangement) bool {
+			if err = re.Apply(); err != nil {
+				return false
+			}
+			if err = t.Tree.CheckTreePostOrder(); err != nil {
+				return false
+			}
+
+			f.WriteString(t.Tree.Newick() + "\n")
+
+			if err = re.Undo(); err != nil {
+				return false
+			}
+			return true
+		})
+
+		if err != nil {
+			io.LogError(err)
+			return
+		}
+	}
+	return
+}
+
+func init() {
+	RootCmd.AddCommand(sprCmd)
+	sprCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input Tree")
+	sprCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "SPR output tree file")
+	sprCmd.Flags().IntVar(&rearrangeRadius, "radius", 0, "Maximum regraft distance, in number of branches (0: no limit)")
+	sprCmd.Flags().IntVar(&rearrangeRandom, "random", 0, "If > 0, number of successive random SPR moves applied to each input tree")
+	sprCmd.Flags().IntVar(&rearrangeNbTrees, "nb-trees", 1, "With --random, number of random trees generated per input tree")
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+// tbrCmd represents the tbr command
+var tbrCmd = &cobra.Command{
+	Use:   "tbr",
+	Short: "Generates TBR neighbors from a given tree",
+	Long: `Generates TBR (Tree Bisection and Reconnection) neighbors from a given tree.
+
+For each branch of the input tree, the tree is bisected by removing the branch,
+and the two resulting subtrees are reconnected by a new branch between any
+branch of the first subtree and any branch of the second subtree, at a maximum
+distance of --radius branches from the removed branch on each side (0: no limit).
+
+By default, all TBR neighbors are written (several neighbors may have the same
+topology). If --random k is given (k>0), then k successive TBR moves are chosen
+randomly and applied to each input tree, and the resulting tree is written.
+This is repeated --nb-trees times for each input tree.
+
+Works only on binary trees.
+
+Examples:
+gotree tbr -i tree.nw --radius 2 -o neighbors.nw
+gotree tbr -i tree.nw --random 5 --nb-trees 100 -o perturbed.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		return rearrangeTrees(&tree.TBRRearranger{Radius: rearrangeRadius})
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(tbrCmd)
+	tbrCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input Tree")
+	tbrCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "TBR output tree file")
+	tbrCmd.Flags().IntVar(&rearrangeRadius, "radius", 0, "Maximum reconnection distance, in number of branches on each side (0: no limit)")
+	tbrCmd.Flags().IntVar(&rearrangeRandom, "random", 0, "If > 0, number of successive random TBR moves applied to each input tree")
+	tbrCmd.Flags().IntVar(&rearrangeNbTrees, "nb-trees", 1, "With --random, number of random trees generated per input tree")
+}
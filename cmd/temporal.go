@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var temporalDates string
+var temporalDateRegexp string
+var temporalCriterion string
+var temporalKeepRoot bool
+var temporalOutStats string
+var temporalOutResiduals string
+
+// temporalCmd represents the reroot temporal command
+var temporalCmd = &cobra.Command{
+	Use:   "temporal",
+	Short: "Reroot trees maximizing the temporal signal",
+	Long: `Reroot trees at the position maximizing the temporal signal (as TempEst).
+
+Tip sampling dates are given either:
+- In a file (--dates), with one line per tip: tipname\tdate (tab or comma separated);
+- Or with a regular expression over tip names (--date-regexp), whose first
+  capture group is the date. For example: "_([0-9.]+)$" for tips named
+  "A_2015.3".
+
+All the tips of the tree must have a date, and branch lengths.
+
+The root is placed on the branch, and at the position on this branch, that
+optimizes the regression of root-to-tip distances against tip dates, according
+to --criterion:
+- correlation: Maximizes the correlation coefficient (default);
+- r2         : Maximizes the coefficient of determination (R²);
+- rms        : Minimizes the residual mean square.
+
+If --keep-root is given, the tree is not rerooted, and the regression is computed
+with its current root.
+
+Outputs:
+-o              : Rerooted trees;
+--out-stats     : Regression statistics, one line per tree: clock rate (slope),
+                  date of the root (TMRCA, x-intercept), correlation, R² and
+                  residual mean square;
+--out-residuals : Per tip date, root-to-tip distance and residual, for outlier detection.
+
+Example:
+
+gotree reroot temporal -i tree.nw --dates dates.txt --out-stats stats.txt --out-residuals residuals.txt > reroot.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f, statsf, residualsf *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var dates map[string]float64
+		var re *regexp.Regexp
+		var criterion int
+		var res *tree.TemporalSignal
+
+		switch strings.ToLower(temporalCriterion) {
+		case "correlation":
+			criterion = tree.TEMPORAL_CORRELATION
+		case "r2":
+			criterion = tree.TEMPORAL_R2
+		case "rms":
+			criterion = tree.TEMPORAL_RMS
+		default:
+			err = fmt.Errorf("Unknown temporal rooting criterion: %s", temporalCriterion)
+			io.LogError(err)
+			return
+		}
+
+		if temporalDates != "none" && temporalDateRegexp != "none" {
+			err = errors.New("--dates and --date-regexp are mutually exclusive")
+			io.LogError(err)
+			return
+		} else if temporalDates != "none" {
+			if dates, err = parseTipValues(temporalDates); err != nil {
+				io.LogError(err)
+				return
+			}
+		} else if temporalDateRegexp != "none" {
+			if re, err = regexp.Compile(temporalDateRegexp); err != nil {
+				io.LogError(err)
+				return
+			}
+			if re.NumSubexp() < 1 {
+				err = errors.New("The date regular expression must have a capture group")
+				io.LogError(err)
+				return
+			}
+		} else {
+			err = errors.New("Tip dates must be given with --dates or --date-regexp")
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if temporalOutStats != "none" {
+			if statsf, err = openWriteFile(temporalOutStats); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(statsf, temporalOutStats)
+			statsf.WriteString("tree\trate\ttmrca\tcorrelation\tr2\trms\n")
+		}
+		if temporalOutResiduals != "none" {
+			if residualsf, err = openWriteFile(temporalOutResiduals); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(residualsf, temporalOutResiduals)
+			residualsf.WriteString("tree\ttip\tdate\tdistance\tresidual\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			if re != nil {
+				if dates, err = parseTipDates(t.Tree, re); err != nil {
+					io.LogError(err)
+					return
+				}
+			}
+			if temporalKeepRoot {
+				res, err = t.Tree.RootToTipRegression(dates)
+			} else {
+				res, err = t.Tree.RerootTemporal(dates, criterion)
+			}
+			if err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+			if statsf != nil {
+				fmt.Fprintf(statsf, "%d\t%f\t%f\t%f\t%f\t%f\n", t.Id, res.Rate, res.TMRCA, res.Correlation, res.R2, res.RMS)
+			}
+			if residualsf != nil {
+				for _, tip := range t.Tree.Tips() {
+					fmt.Fprintf(residualsf, "%d\t%s\t%f\t%f\t%f\n", t.Id, tip.Name(), res.Dates[tip.Name()], res.Distances[tip.Name()], res.Residuals[tip.Name()])
+				}
+			}
+		}
+		return
+	},
+}
+
+// Extracts the date of each tip of the tree from its name, using the
+// first capture group of the given regular expression
+func parseTipDates(t *tree.Tree, re *regexp.Regexp) (dates map[string]float64, err error) {
+	var v float64
+	dates = make(map[string]float64)
+	for _, tip := range t.Tips() {
+		m := re.FindStringSubmatch(tip.Name())
+		if m == nil {
+			err = fmt.Errorf("Tip %s does not match the date regular expression", tip.Name())
+			return
+		}
+		if v, err = strconv.ParseFloat(m[1], 64); err != nil {
+			err = fmt.Errorf("Date of tip %s is not a number: %s", tip.Name(), m[1])
+			return
+		}
+		dates[tip.Name()] = v
+	}
+	return
+}
+
+func init() {
+	rerootCmd.AddCommand(temporalCmd)
+	temporalCmd.PersistentFlags().StringVar(&temporalDates, "dates", "none", "File with tip dates (tipname\\tdate)")
+	temporalCmd.PersistentFlags().StringVar(&temporalDateRegexp, "date-regexp", "none", "Regular expression extracting dates from tip names (first capture group)")
+	temporalCmd.PersistentFlags().StringVar(&temporalCriterion, "criterion", "correlation", "Rooting criterion: correlation, r2, or rms")
+	temporalCmd.PersistentFlags().BoolVar(&temporalKeepRoot, "keep-root", false, "Does not reroot the tree, and computes the regression with its current root")
+	temporalCmd.PersistentFlags().StringVar(&temporalOutStats, "out-stats", "none", "Output file with regression statistics")
+	temporalCmd.PersistentFlags().StringVar(&temporalOutResiduals, "out-residuals", "none", "Output file with per tip residuals")
+}
@@ -41,7 +41,8 @@ var textCmd = &cobra.Command{
 			}
 			d = draw.NewTextTreeDrawer(f, termwidth, len(t.Tree.Tips())*2, 10)
 			l = draw.NewNormalLayout(d, !drawNoBranchLengths, !drawNoTipLabels, drawInternalNodeLabels, drawSupport)
-			l.SetDisplayNodeComments(drawNodeComment)
+			l.SetDisplayNodeComments(drawNodeComment || drawNodeAttribute != "")
+			l.SetNodeAttribute(drawNodeAttribute)
 			l.SetSupportCutoff(drawSupportCutoff)
 			l.DrawTree(t.Tree)
 		}
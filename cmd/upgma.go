@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+// upgmaCmd represents the upgma command
+var upgmaCmd = &cobra.Command{
+	Use:   "upgma",
+	Short: "Infers a tree from a distance matrix using UPGMA",
+	Long: `Infers a rooted ultrametric tree from a distance matrix using UPGMA
+(average linkage clustering).
+
+The input matrix is in PHYLIP format: the first line contains the number of taxa,
+and each following row contains the name of a taxon followed by its distances.
+The matrix may be square (as output by gotree matrix) or lower-triangular
+(with or without diagonal).
+
+Example:
+gotree matrix -i tree.nw | gotree compute upgma -o upgma.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		distanceNegative = "keep"
+		return distanceTree(func(names []string, matrix [][]float64, negative int) (*tree.Tree, error) {
+			return tree.UPGMA(names, matrix)
+		})
+	},
+}
+
+func init() {
+	computeCmd.AddCommand(upgmaCmd)
+	upgmaCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input distance matrix (PHYLIP format)")
+	upgmaCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output tree")
+}
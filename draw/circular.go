@@ -17,6 +17,7 @@ type circularLayout struct {
 	hasSupport             bool
 	supportCutoff          float64
 	cache                  *layoutCache
+	nodeAttribute          string
 }
 
 /*
@@ -37,6 +38,7 @@ func NewCircularLayout(td TreeDrawer, withBranchLengths, withTipLabels, withInte
 		withSupportCircles,
 		0.7,
 		newLayoutCache(),
+		"",
 	}
 }
 
@@ -52,6 +54,10 @@ func (layout *circularLayout) SetDisplayNodeComments(s bool) {
 	layout.hasNodeComments = s
 }
 
+func (layout *circularLayout) SetNodeAttribute(key string) {
+	layout.nodeAttribute = key
+}
+
 /*
 Draw the tree on the specific drawer. Does not close the file. The caller must do it.
 */
@@ -76,7 +82,7 @@ func (layout *circularLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, suppo
 		angle = float64(*curtip)*2*math.Pi/float64(nbtips) + math.Pi/2
 		x3 := distToRoot * math.Cos(angle)
 		y3 := distToRoot * math.Sin(angle)
-		node := &layoutPoint{x3, y3, angle, n.Name(), n.CommentsString()}
+		node := &layoutPoint{x3, y3, angle, n.Name(), nodeComment(n, layout.nodeAttribute)}
 		layout.cache.tipLabelPoints = append(layout.cache.tipLabelPoints, node)
 		*curtip++
 	} else {
@@ -102,7 +108,7 @@ func (layout *circularLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, suppo
 
 		x4 := distToRoot * math.Cos(angle)
 		y4 := distToRoot * math.Sin(angle)
-		inode := &layoutPoint{x4, y4, angle, n.Name(), n.CommentsString()}
+		inode := &layoutPoint{x4, y4, angle, n.Name(), nodeComment(n, layout.nodeAttribute)}
 		layout.cache.nodePoints = append(layout.cache.nodePoints, inode)
 		curve := &layoutCurve{&layoutPoint{0, 0, 0.0, "", ""}, inode, distToRoot, minangle, maxangle}
 		layout.cache.curvePaths = append(layout.cache.curvePaths, curve)
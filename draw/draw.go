@@ -43,4 +43,20 @@ type TreeLayout interface {
 	SetSupportCutoff(float64)
 	SetDisplayInternalNodes(bool)
 	SetDisplayNodeComments(bool)
+	SetNodeAttribute(string)
+}
+
+/*
+Returns the comment to display next to the node: If attribute is not empty,
+it is the value of the given node attribute (empty if the node does not have
+it), otherwise it is the comments of the node.
+*/
+func nodeComment(n *tree.Node, attribute string) string {
+	if attribute != "" {
+		if a, ok := n.Attribute(attribute); ok {
+			return a.String()
+		}
+		return ""
+	}
+	return n.CommentsString()
 }
@@ -14,6 +14,7 @@ type normalLayout struct {
 	hasSupport             bool
 	supportCutoff          float64
 	cache                  *layoutCache
+	nodeAttribute          string
 }
 
 func NewNormalLayout(td TreeDrawer, withBranchLengths, withTipLabels, withInternalNodeLabel, withSupportCircles bool) TreeLayout {
@@ -27,6 +28,7 @@ func NewNormalLayout(td TreeDrawer, withBranchLengths, withTipLabels, withIntern
 		withSupportCircles,
 		0.7,
 		newLayoutCache(),
+		"",
 	}
 }
 
@@ -42,6 +44,10 @@ func (layout *normalLayout) SetDisplayNodeComments(s bool) {
 	layout.hasNodeComments = s
 }
 
+func (layout *normalLayout) SetNodeAttribute(key string) {
+	layout.nodeAttribute = key
+}
+
 /*
 Draw the tree on the specific drawer. Does not close the file. The caller must do it.
 */
@@ -67,7 +73,7 @@ func (layout *normalLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, support
 		ypos = float64(*curtip)
 		nbchild = 1.0
 		if layout.hasTipLabels {
-			node := &layoutPoint{distToRoot, ypos, 0.0, n.Name(), n.CommentsString()}
+			node := &layoutPoint{distToRoot, ypos, 0.0, n.Name(), nodeComment(n, layout.nodeAttribute)}
 			layout.cache.tipLabelPoints = append(layout.cache.tipLabelPoints, node)
 		}
 		*curtip++
@@ -96,7 +102,7 @@ func (layout *normalLayout) drawTreeRecur(n *tree.Node, prev *tree.Node, support
 		line := &layoutVLine{distToRoot, minpos, maxpos, tree.NIL_SUPPORT}
 		layout.cache.verticalPaths = append(layout.cache.verticalPaths, line)
 
-		inode := &layoutPoint{distToRoot, ypos, 0.0, n.Name(), n.CommentsString()}
+		inode := &layoutPoint{distToRoot, ypos, 0.0, n.Name(), nodeComment(n, layout.nodeAttribute)}
 		layout.cache.nodePoints = append(layout.cache.nodePoints, inode)
 	}
 
@@ -17,6 +17,7 @@ type radialLayout struct {
 	hasSupport            bool
 	supportCutoff         float64
 	cache                 *layoutCache
+	nodeAttribute         string
 }
 
 func NewRadialLayout(td TreeDrawer, withBranchLengths, withTipLabels, withInternalNodeLabels, withSuppportCircles bool) TreeLayout {
@@ -31,6 +32,7 @@ func NewRadialLayout(td TreeDrawer, withBranchLengths, withTipLabels, withIntern
 		withSuppportCircles,
 		0.7,
 		newLayoutCache(),
+		"",
 	}
 }
 
@@ -45,6 +47,10 @@ func (layout *radialLayout) SetDisplayNodeComments(s bool) {
 	layout.hasNodeComments = s
 }
 
+func (layout *radialLayout) SetNodeAttribute(key string) {
+	layout.nodeAttribute = key
+}
+
 /*
 Draw the tree on the specific drawer. Does not close the file. The caller must do it.
 This layout is an adaptation in Go of the figtree radial layout : figtree/treeviewer/treelayouts/RadialTreeLayout.java
@@ -65,7 +71,7 @@ func (layout *radialLayout) constructNode(t *tree.Tree, node *tree.Node, prev *t
 	directionX := math.Cos(branchAngle)
 	directionY := math.Sin(branchAngle)
 
-	nodePoint := &layoutPoint{xPosition + (length * directionX), yPosition + (length * directionY), branchAngle, node.Name(), node.CommentsString()}
+	nodePoint := &layoutPoint{xPosition + (length * directionX), yPosition + (length * directionY), branchAngle, node.Name(), nodeComment(node, layout.nodeAttribute)}
 
 	if !node.Tip() {
 		leafCounts := make([]int, 0)
@@ -159,16 +159,40 @@ func (p *Parser) parseIter(t *tree.Tree, level *int) (prevTok Token, err error)
 			node, edge, _ = nodeStack.Head()
 		case OPENBRACK:
 			var comment string
+			var attrs *tree.Attributes
 			//if prevTok == OPENPAR || prevTok == NEWSIBLING || prevTok == -1 {
 			if comment, err = p.consumeComment(tok, lit); err != nil {
 				return
 			}
+			// Comments of the form [&key=value,...] are parsed as attributes,
+			// as well as NHX comments [&&NHX:key=value:...], the others are
+			// kept as is. NHX attributes always describe the node, even
+			// if located after the branch length.
+			nhx := false
+			if attrs, _ = tree.ParseAttributes(comment); attrs == nil {
+				attrs, _ = tree.ParseNHXAttributes(comment)
+				nhx = attrs != nil
+			}
 			// Add comment to edge if comment located after branch length
-			if prevTok == STARTLEN {
-				edge.AddComment(comment)
-			} else if (prevTok == CLOSEPAR || prevTok == IDENT || prevTok == NUMERIC || prevTok == CLOSEBRACK) && node != nil {
+			if prevTok == STARTLEN && !nhx {
+				if attrs != nil {
+					for _, k := range attrs.Keys() {
+						a, _ := attrs.Get(k)
+						edge.SetAttribute(k, a)
+					}
+				} else {
+					edge.AddComment(comment)
+				}
+			} else if (prevTok == CLOSEPAR || prevTok == IDENT || prevTok == NUMERIC || prevTok == CLOSEBRACK || prevTok == STARTLEN) && node != nil {
 				// Else we add comment to node
-				node.AddComment(comment)
+				if attrs != nil {
+					for _, k := range attrs.Keys() {
+						a, _ := attrs.Get(k)
+						node.SetAttribute(k, a)
+					}
+				} else {
+					node.AddComment(comment)
+				}
 			} else {
 				err = errors.New("Newick Error: Comment should not be located here: " + lit)
 				return
@@ -290,7 +314,9 @@ func (p *Parser) parseIter(t *tree.Tree, level *int) (prevTok Token, err error)
 // If the given token is not a [, then returns an error
 func (p *Parser) consumeComment(curtoken Token, curlit string) (comment string, err error) {
 	if curtoken == OPENBRACK {
-		commenttoken, commentlit := p.scanIgnoreWhitespace()
+		// Whitespaces inside comments are kept (they may be part of
+		// attribute values), except at the beginning and at the end
+		commenttoken, commentlit := p.scan()
 		for commenttoken != CLOSEBRACK {
 			if commenttoken == EOF || commenttoken == ILLEGAL {
 				err = fmt.Errorf("Unmatched bracket")
@@ -298,8 +324,9 @@ func (p *Parser) consumeComment(curtoken Token, curlit string) (comment string,
 			} else {
 				comment += commentlit
 			}
-			commenttoken, commentlit = p.scanIgnoreWhitespace()
+			commenttoken, commentlit = p.scan()
 		}
+		comment = strings.TrimSpace(comment)
 	} else {
 		err = fmt.Errorf("A comment must start with [")
 	}
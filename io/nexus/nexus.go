@@ -91,10 +91,7 @@ func WriteNexus(tchan <-chan tree.Trees) (string, error) {
 			buffer.WriteString("BEGIN TREES;\n")
 			taxlabels = true
 		}
-		buffer.WriteString("  TREE tree")
-		buffer.WriteString(strconv.Itoa(t.Id))
-		buffer.WriteString(" = ")
-		buffer.WriteString(t.Tree.Newick())
+		buffer.WriteString(t.Tree.NexusTree("tree" + strconv.Itoa(t.Id)))
 		buffer.WriteString("\n")
 	}
 	buffer.WriteString("END;\n")
@@ -9,6 +9,7 @@ import (
 	"github.com/evolbioinfo/goalign/align"
 	treeio "github.com/evolbioinfo/gotree/io"
 	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
 )
 
 // Parser represents a parser.
@@ -73,7 +74,8 @@ func (p *Parser) Parse() (*Nexus, error) {
 	missing := '*'
 	gap := '-'
 	var taxlabels map[string]bool = nil
-	var names, treestrings, treenames []string
+	var names, treestrings, treenames, treeflags []string
+	var treeattrs []*tree.Attributes
 	var sequences map[string]string
 
 	nexus := NewNexus()
@@ -121,7 +123,7 @@ func (p *Parser) Parse() (*Nexus, error) {
 				taxantax, taxlabels, err = p.parseTaxa()
 			case TREES:
 				// TREES BLOCK
-				treenames, treestrings, err = p.parseTrees()
+				treenames, treestrings, treeattrs, treeflags, err = p.parseTrees()
 			case DATA:
 				// DATA/CHARACTERS BLOCK
 				names, sequences, nchar, ntax, datatype, missing, gap, err = p.parseData()
@@ -208,6 +210,11 @@ func (p *Parser) Parse() (*Nexus, error) {
 					return nil, fmt.Errorf("Some tax names defined in TAXLABELS are not present in the tree %d", i)
 				}
 			}
+			for _, k := range treeattrs[i].Keys() {
+				a, _ := treeattrs[i].Get(k)
+				t.SetAttribute(k, a)
+			}
+			t.SetRootFlag(treeflags[i])
 			//t.ReinitIndexes()
 			nexus.AddTree(treenames[i], t)
 		}
@@ -306,9 +313,15 @@ func (p *Parser) parseTaxa() (int64, map[string]bool, error) {
 }
 
 // Parse TREES block
-func (p *Parser) parseTrees() (treenames, treestrings []string, err error) {
+//
+// Also returns, for each tree, the attributes given in comments between
+// its name and '=' (e.g. BEAST [&lnP=-1234.5], nil if none), and its
+// rooting flag given just after '=' ("R" for [&R], "U" for [&U], "" if none).
+func (p *Parser) parseTrees() (treenames, treestrings []string, treeattrs []*tree.Attributes, treeflags []string, err error) {
 	treenames = make([]string, 0)
 	treestrings = make([]string, 0)
+	treeattrs = make([]*tree.Attributes, 0)
+	treeflags = make([]string, 0)
 	stoptrees := false
 	for !stoptrees {
 		tok, lit := p.scanIgnoreWhitespace()
@@ -341,31 +354,68 @@ func (p *Parser) parseTrees() (treenames, treestrings []string, err error) {
 				stoptrees = true
 				break
 			}
+			var attrs *tree.Attributes
+			var comment, flag string
 			tok3, lit3 := p.scanIgnoreWhitespace()
+			// Attribute comments between the tree name and '=' (e.g. BEAST
+			// [&lnP=-1234.5]) are kept as tree attributes, other comments
+			// are skipped
+			for tok3 == OPENBRACK {
+				if comment, err = p.parseComment(tok3, lit3); err != nil {
+					break
+				}
+				if a, err2 := tree.ParseAttributes(comment); err2 == nil {
+					if attrs == nil {
+						attrs = tree.NewAttributes()
+					}
+					attrs.Merge(a)
+				}
+				tok3, lit3 = p.scanIgnoreWhitespace()
+			}
+			if err != nil {
+				stoptrees = true
+				break
+			}
 			if tok3 != EQUAL {
 				err = fmt.Errorf("Expecting '=' after tree name, got %q", lit3)
 				stoptrees = true
 				break
 			}
 			tok4, lit4 := p.scanIgnoreWhitespace()
+			// Rooting flag [&R] or [&U], other comments are skipped
 			if tok4 == OPENBRACK {
-				if tok4, lit4, err = p.consumeComment(tok4, lit4); err != nil {
+				if comment, err = p.parseComment(tok4, lit4); err != nil {
 					stoptrees = true
 					break
 				}
+				switch strings.ToUpper(comment) {
+				case "&R", "&U":
+					flag = strings.ToUpper(comment[1:])
+				}
 				tok4, lit4 = p.scanIgnoreWhitespaceAndEOL()
 			}
-			tree := ""
+			treestr := ""
 			// We remove whitespaces in the tree string if any,
 			// and keep comments in brackets as part of the newick string
+			// (with their whitespaces, that may be part of attribute values)
+			incomment := 0
 			for tok4 != ENDOFCOMMAND {
-				if tok4 != IDENT && tok4 != OPENBRACK && tok4 != CLOSEBRACK && tok4 != COMMA && tok4 != EQUAL && tok4 != NUMERIC {
+				if tok4 != IDENT && tok4 != OPENBRACK && tok4 != CLOSEBRACK && tok4 != COMMA && tok4 != EQUAL && tok4 != NUMERIC && (tok4 != WS || incomment == 0) {
 					err = fmt.Errorf("Expecting a tree after 'TREE name =', got  %q", lit4)
 					stoptrees = true
 					break
 				}
-				tree += lit4
-				tok4, lit4 = p.scanIgnoreWhitespace()
+				if tok4 == OPENBRACK {
+					incomment++
+				} else if tok4 == CLOSEBRACK {
+					incomment--
+				}
+				treestr += lit4
+				if incomment > 0 {
+					tok4, lit4 = p.scan()
+				} else {
+					tok4, lit4 = p.scanIgnoreWhitespace()
+				}
 			}
 			if tok4 != ENDOFCOMMAND {
 				err = fmt.Errorf("Expecting ';' after 'TREE name = tree', got %q", lit4)
@@ -373,7 +423,9 @@ func (p *Parser) parseTrees() (treenames, treestrings []string, err error) {
 				break
 			}
 			treenames = append(treenames, lit2)
-			treestrings = append(treestrings, tree)
+			treestrings = append(treestrings, treestr)
+			treeattrs = append(treeattrs, attrs)
+			treeflags = append(treeflags, flag)
 		case OPENBRACK:
 			if tok, lit, err = p.consumeComment(tok, lit); err != nil {
 				stoptrees = true
@@ -702,6 +754,27 @@ func addseq(sequences map[string]string, names *[]string, sequence string, name
 	}
 }
 
+// Parses a comment inside brakets [comment] starting at the given current token,
+// that must be a [, and returns its content, without leading and trailing
+// whitespaces. The matching ] token is consumed.
+func (p *Parser) parseComment(curtoken Token, curlit string) (comment string, err error) {
+	if curtoken != OPENBRACK {
+		err = fmt.Errorf("A comment must start with [")
+		return
+	}
+	tok, lit := p.scan()
+	for tok != CLOSEBRACK {
+		if tok == EOF || tok == ILLEGAL {
+			err = fmt.Errorf("Unmatched bracket")
+			return
+		}
+		comment += lit
+		tok, lit = p.scan()
+	}
+	comment = strings.TrimSpace(comment)
+	return
+}
+
 // Consumes comment inside brakets [comment] if the given current token is a [.
 // At the end returns the matching ] token and lit.
 // If the given token is not a [, then returns the input token and lit
@@ -70,3 +70,91 @@ EOF
 		}
 	}
 }
+
+// Ensure the parser keeps BEAST/FigTree annotations as typed attributes
+func TestParser_ParseBeastTree(t *testing.T) {
+	intree := `#NEXUS
+Begin trees;
+	Translate
+		1 A_2001,
+		2 B_2002,
+		3 C_2003
+		;
+tree STATE_0 [&lnP=-1234.5] = [&R] ((1[&rate=0.001]:1.5,2:1.0)[&height=1.5,height_95%_HPD={1.2,1.9},posterior=0.98]:0.5,3[&state="Asia"]:2.0)[&state.set={"Asia","Europe"}];
+End;
+`
+	expected := `((A_2001[&rate=0.001]:1.5,B_2002:1)[&height=1.5,height_95%_HPD={1.2,1.9},posterior=0.98]:0.5,C_2003[&state="Asia"]:2)[&state.set={"Asia","Europe"}];`
+	nex, err := nexus.NewParser(strings.NewReader(intree)).Parse()
+	if err != nil {
+		t.Errorf("ERROR: %s\n", err.Error())
+		return
+	}
+	if nex.NTrees() != 1 {
+		t.Errorf("There should be 1 tree in the nexus file, and there are %d\n", nex.NTrees())
+		return
+	}
+	nex.IterateTrees(func(name string, tr *tree.Tree) {
+		if tr.Newick() != expected {
+			t.Errorf("Tree should be: %q and is %q\n", expected, tr.Newick())
+		}
+		if a, ok := tr.Attribute("lnP"); !ok || a.String() != "-1234.5" {
+			t.Errorf("Tree attribute lnP should be -1234.5\n")
+		}
+		if tr.RootFlag() != "R" {
+			t.Errorf("Tree rooting flag should be R and is %q\n", tr.RootFlag())
+		}
+		for _, n := range tr.Nodes() {
+			if a, ok := n.Attribute("height_95%_HPD"); ok {
+				if hpd, err := a.FloatList(); err != nil || len(hpd) != 2 || hpd[0] != 1.2 || hpd[1] != 1.9 {
+					t.Errorf("HPD interval should be {1.2,1.9} and is %s\n", a.String())
+				}
+				if p, _ := n.Attribute("posterior"); p.String() != "0.98" {
+					t.Errorf("Posterior should be 0.98 and is %s\n", p.String())
+				}
+			}
+		}
+	})
+}
+
+// Ensure tree-level annotations and the rooting flag are written back
+// and parsed again identically
+func TestParser_BeastTreeRoundTrip(t *testing.T) {
+	intree := `#NEXUS
+Begin trees;
+tree STATE_0 [&lnP=-1234.5] = [&R] ((A[&rate=0.001]:1.5,B:1.0)[&posterior=0.98]:0.5,C:2.0);
+End;
+`
+	expected := `  TREE tree1 [&lnP=-1234.5] = [&R] ((A[&rate=0.001]:1.5,B:1)[&posterior=0.98]:0.5,C:2);`
+	nex, err := nexus.NewParser(strings.NewReader(intree)).Parse()
+	if err != nil {
+		t.Errorf("ERROR: %s\n", err.Error())
+		return
+	}
+	tr := nex.FirstTree()
+	if line := tr.NexusTree("tree1"); line != expected {
+		t.Errorf("Nexus tree should be: %q and is %q\n", expected, line)
+	}
+
+	treechan := make(chan tree.Trees, 1)
+	treechan <- tree.Trees{Tree: tr, Id: 1}
+	close(treechan)
+	out, err := nexus.WriteNexus(treechan)
+	if err != nil {
+		t.Errorf("ERROR: %s\n", err.Error())
+		return
+	}
+	if !strings.Contains(out, expected+"\n") {
+		t.Errorf("Nexus output should contain %q: %q\n", expected, out)
+	}
+
+	for _, s := range []string{out, tr.Nexus()} {
+		nex2, err := nexus.NewParser(strings.NewReader(s)).Parse()
+		if err != nil {
+			t.Errorf("ERROR: %s\n", err.Error())
+			return
+		}
+		if line := nex2.FirstTree().NexusTree("tree1"); line != expected {
+			t.Errorf("Parsed again, nexus tree should be: %q and is %q\n", expected, line)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/evolbioinfo/gotree/tree"
 )
@@ -22,12 +24,13 @@ type Phylogeny struct {
 }
 
 type Clade struct {
-	XMLName      xml.Name `xml:"clade"`
-	Clades       []Clade  `xml:"clade"`
-	BranchLength *float64 `xml:"branch_length"`
-	Confidence   *float64 `xml:"confidence"`
-	Name         string   `xml:"name"`
-	Tax          Taxonomy `xml:"taxonomy"`
+	XMLName      xml.Name   `xml:"clade"`
+	Clades       []Clade    `xml:"clade"`
+	BranchLength *float64   `xml:"branch_length"`
+	Confidence   *float64   `xml:"confidence"`
+	Name         string     `xml:"name"`
+	Tax          Taxonomy   `xml:"taxonomy"`
+	Properties   []Property `xml:"property"`
 }
 
 type Taxonomy struct {
@@ -42,6 +45,21 @@ type TaxonomyId struct {
 	Provider string `xml:"provider,attr"`
 }
 
+// Custom data associated to a clade or to its parent branch.
+// They are converted to node/edge attributes.
+type Property struct {
+	XMLName   xml.Name `xml:"property"`
+	Ref       string   `xml:"ref,attr"`
+	Datatype  string   `xml:"datatype,attr"`
+	AppliesTo string   `xml:"applies_to,attr"`
+	Value     string   `xml:",chardata"`
+}
+
+// Prefix added to attribute keys without prefix when
+// written as PhyloXML properties (ref must be of the
+// form prefix:name)
+const PROPERTY_PREFIX = "gotree:"
+
 // Parser represents a parser.
 type Parser struct {
 	reader io.Reader
@@ -102,6 +120,16 @@ func cladeToTree(c *Clade, t *tree.Tree, parent *tree.Node, nedges, nnodes *int)
 				e.SetSupport(*(c.Confidence))
 			}
 		}
+		for _, prop := range c.Properties {
+			if prop.AppliesTo == "parent_branch" || prop.AppliesTo == "branch" {
+				e.SetAttribute(propertyKey(prop), propertyToAttribute(prop))
+			}
+		}
+	}
+	for _, prop := range c.Properties {
+		if prop.AppliesTo != "parent_branch" && prop.AppliesTo != "branch" {
+			newNode.SetAttribute(propertyKey(prop), propertyToAttribute(prop))
+		}
 	}
 	if c.Name != "" {
 		newNode.SetName(c.Name)
@@ -122,6 +150,56 @@ func cladeToTree(c *Clade, t *tree.Tree, parent *tree.Node, nedges, nnodes *int)
 	return
 }
 
+// Converts a PhyloXML property into a tree attribute,
+// according to its datatype. Lists are written as xsd:string
+// of the form {v1,v2,...}, and are parsed back as lists
+func propertyToAttribute(prop Property) tree.Attribute {
+	value := strings.TrimSpace(prop.Value)
+	switch prop.Datatype {
+	case "xsd:double", "xsd:float", "xsd:decimal":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return tree.NewFloatAttribute(f)
+		}
+	case "xsd:integer", "xsd:int", "xsd:long", "xsd:short", "xsd:byte",
+		"xsd:nonNegativeInteger", "xsd:positiveInteger", "xsd:unsignedInt":
+		if i, err := strconv.Atoi(value); err == nil {
+			return tree.NewIntAttribute(i)
+		}
+	case "xsd:boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return tree.NewBoolAttribute(b)
+		}
+	case "xsd:string":
+		if a := tree.ParseAttribute(value); a.Type() == tree.ATTRIBUTE_LIST {
+			return a
+		}
+		return tree.NewStringAttribute(value)
+	case "xsd:anyURI", "xsd:token", "xsd:normalizedString":
+		return tree.NewStringAttribute(value)
+	}
+	return tree.ParseAttribute(value)
+}
+
+// Key of the attribute corresponding to the property: its ref
+// without the default gotree prefix
+func propertyKey(prop Property) string {
+	return strings.TrimPrefix(prop.Ref, PROPERTY_PREFIX)
+}
+
+// Returns the xsd datatype corresponding to the attribute type
+func attributeDatatype(a tree.Attribute) string {
+	switch a.Type() {
+	case tree.ATTRIBUTE_FLOAT:
+		return "xsd:double"
+	case tree.ATTRIBUTE_INT:
+		return "xsd:integer"
+	case tree.ATTRIBUTE_BOOL:
+		return "xsd:boolean"
+	default:
+		return "xsd:string"
+	}
+}
+
 // func printTaxonomy(t Taxonomy, level int) {
 // 	tab := ""
 // 	for i := 0; i < level; i++ {
@@ -175,6 +253,10 @@ func writeClade(n *tree.Node, prev *tree.Node, e *tree.Edge, buf *bytes.Buffer,
 			buf.WriteString(fmt.Sprintf("%s<confidence type=\"bootstrap\">%s</confidence>\n", tab, e.SupportString()))
 		}
 	}
+	writeProperties(n.Attributes(), "clade", buf, tab)
+	if prev != nil && e != nil {
+		writeProperties(e.Attributes(), "parent_branch", buf, tab)
+	}
 	for i, child := range n.Neigh() {
 		if child != prev {
 			nextedge := n.Edges()[i]
@@ -183,3 +265,17 @@ func writeClade(n *tree.Node, prev *tree.Node, e *tree.Edge, buf *bytes.Buffer,
 	}
 	buf.WriteString(tab + "</clade>\n")
 }
+
+// Writes attributes as PhyloXML properties
+func writeProperties(attrs *tree.Attributes, appliesTo string, buf *bytes.Buffer, tab string) {
+	for _, k := range attrs.Keys() {
+		a, _ := attrs.Get(k)
+		ref := k
+		if !strings.Contains(ref, ":") {
+			ref = PROPERTY_PREFIX + ref
+		}
+		buf.WriteString(fmt.Sprintf("%s<property ref=\"%s\" datatype=\"%s\" applies_to=\"%s\">", tab, ref, attributeDatatype(a), appliesTo))
+		xml.EscapeText(buf, []byte(a.String()))
+		buf.WriteString("</property>\n")
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Reads a PHYLIP distance matrix from the given file.
+//
+// See ReadDistanceMatrixReader for the accepted formats.
+func ReadDistanceMatrix(inputfile string) (names []string, matrix [][]float64, err error) {
+	var r *bufio.Reader
+	if f, r2, err2 := GetReader(inputfile); err2 != nil {
+		err = err2
+		return
+	} else {
+		defer f.Close()
+		r = r2
+	}
+	return ReadDistanceMatrixReader(r)
+}
+
+// Reads a PHYLIP distance matrix from the given reader.
+// This function does not close the reader.
+//
+// The first token is the number of taxa n, followed by n rows. Each row
+// starts with the name of the taxon (without spaces), followed by its distances
+// (separated by spaces or tabs). The matrix may be:
+//	* square: n distances per row;
+//	* lower-triangular without diagonal: i distances for row i (starting at 0);
+//	* lower-triangular with diagonal: i+1 distances for row i.
+// Rows may span several lines.
+//
+// The returned matrix is always square. An error is returned if the square matrix
+// is not symmetric, or if the number of distances does not match any format.
+func ReadDistanceMatrixReader(reader *bufio.Reader) (names []string, matrix [][]float64, err error) {
+	var tokens []string
+	var n int
+	var square, diagonal bool
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024*1024)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if len(tokens) == 0 {
+		err = errors.New("Empty distance matrix")
+		return
+	}
+	if n, err = strconv.Atoi(tokens[0]); err != nil || n <= 0 {
+		err = fmt.Errorf("First token of the distance matrix should be the number of taxa: %s", tokens[0])
+		return
+	}
+	tokens = tokens[1:]
+
+	switch len(tokens) {
+	case n * (n + 1):
+		square = true
+	case n + n*(n-1)/2:
+		diagonal = false
+	case n + n*(n+1)/2:
+		diagonal = true
+	default:
+		err = fmt.Errorf("Number of values in the distance matrix does not correspond to %d taxa", n)
+		return
+	}
+
+	names = make([]string, n)
+	matrix = make([][]float64, n)
+	for i := range matrix {
+		matrix[i] = make([]float64, n)
+	}
+	pos := 0
+	for i := 0; i < n; i++ {
+		names[i] = tokens[pos]
+		pos++
+		nvalues := i
+		if square {
+			nvalues = n
+		} else if diagonal {
+			nvalues = i + 1
+		}
+		for j := 0; j < nvalues; j++ {
+			var d float64
+			if d, err = strconv.ParseFloat(tokens[pos], 64); err != nil {
+				err = fmt.Errorf("Distance %s (row %s) is not a number", tokens[pos], names[i])
+				return
+			}
+			pos++
+			matrix[i][j] = d
+			if !square {
+				matrix[j][i] = d
+			}
+		}
+	}
+
+	if square {
+		for i := 0; i < n; i++ {
+			for j := 0; j < i; j++ {
+				if math.Abs(matrix[i][j]-matrix[j][i]) > 1e-6 {
+					err = fmt.Errorf("Distance matrix is not symmetric: d(%s,%s)=%f != d(%s,%s)=%f",
+						names[i], names[j], matrix[i][j], names[j], names[i], matrix[j][i])
+					return
+				}
+			}
+		}
+	}
+	return
+}
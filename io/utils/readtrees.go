@@ -3,6 +3,7 @@ package utils
 import (
 	"bufio"
 	"fmt"
+	goio "io"
 	"strings"
 
 	"github.com/evolbioinfo/gotree/io/fileutils"
@@ -82,8 +83,21 @@ func ReadTreeReader(reader *bufio.Reader, format int) (*tree.Tree, error) {
 // the channel
 // Different parsing formats: utils.FORMAT_NEWICK or utils.FORMAT_NEXUS
 func ReadMultiTrees(reader *bufio.Reader, format int) <-chan tree.Trees {
+	return ReadMultiTreesSelection(reader, format, nil)
+}
+
+// Same as ReadMultiTrees, but only the trees given by the selection are sent to
+// the output channel (burn-in, thinning, and range, see TreeSelection). The Id of
+// the trees is their index in the input reader (before selection).
+//
+// If the selection is nil, then all trees are sent.
+func ReadMultiTreesSelection(reader *bufio.Reader, format int, selection *TreeSelection) <-chan tree.Trees {
 	var compTrees chan tree.Trees = make(chan tree.Trees, 10)
 
+	if selection == nil {
+		selection = NewTreeSelection()
+	}
+
 	go func() {
 		var err error
 		var id int = 0
@@ -91,7 +105,31 @@ func ReadMultiTrees(reader *bufio.Reader, format int) <-chan tree.Trees {
 
 		switch format {
 		case FORMAT_NEWICK:
-			line, e := fileutils.ReadUntilSemiColon(reader)
+			var lines []string
+			var line string
+			var e error
+			nbtrees := -1
+			// If the burn-in is a fraction of the trees, we need the
+			// total number of trees first
+			if selection.fractionBurnin() {
+				lines = make([]string, 0, 100)
+				line, e = fileutils.ReadUntilSemiColon(reader)
+				for e == nil {
+					lines = append(lines, line)
+					line, e = fileutils.ReadUntilSemiColon(reader)
+				}
+				nbtrees = len(lines)
+			}
+			nextLine := func() (string, error) {
+				if lines == nil {
+					return fileutils.ReadUntilSemiColon(reader)
+				}
+				if id >= len(lines) {
+					return "", goio.EOF
+				}
+				return lines[id], nil
+			}
+			line, e = nextLine()
 			if e != nil {
 				compTrees <- tree.Trees{
 					nil,
@@ -99,24 +137,26 @@ func ReadMultiTrees(reader *bufio.Reader, format int) <-chan tree.Trees {
 					e,
 				}
 			}
-			for e == nil {
-				parser := newick.NewParser(strings.NewReader(line))
-				if compTree, err = parser.Parse(); err != nil {
-					compTrees <- tree.Trees{
-						nil,
-						id,
-						err,
-					}
-					break
-				} else {
-					compTrees <- tree.Trees{
-						compTree,
-						id,
-						nil,
+			for e == nil && !selection.done(id) {
+				if selection.keep(id, nbtrees) {
+					parser := newick.NewParser(strings.NewReader(line))
+					if compTree, err = parser.Parse(); err != nil {
+						compTrees <- tree.Trees{
+							nil,
+							id,
+							err,
+						}
+						break
+					} else {
+						compTrees <- tree.Trees{
+							compTree,
+							id,
+							nil,
+						}
 					}
 				}
 				id++
-				line, e = fileutils.ReadUntilSemiColon(reader)
+				line, e = nextLine()
 			}
 		case FORMAT_NEXUS:
 			if n, err := nexus.NewParser(reader).Parse(); err != nil {
@@ -126,11 +166,14 @@ func ReadMultiTrees(reader *bufio.Reader, format int) <-chan tree.Trees {
 					err,
 				}
 			} else {
+				nbtrees := n.NTrees()
 				n.IterateTrees(func(name string, t *tree.Tree) {
-					compTrees <- tree.Trees{
-						t,
-						id,
-						nil,
+					if selection.keep(id, nbtrees) {
+						compTrees <- tree.Trees{
+							t,
+							id,
+							nil,
+						}
 					}
 					id++
 				})
@@ -143,11 +186,14 @@ func ReadMultiTrees(reader *bufio.Reader, format int) <-chan tree.Trees {
 					err2,
 				}
 			} else {
+				nbtrees := len(p.Phylogenies)
 				p.IterateTrees(func(t *tree.Tree, err error) {
-					compTrees <- tree.Trees{
-						t,
-						id,
-						err,
+					if selection.keep(id, nbtrees) || err != nil {
+						compTrees <- tree.Trees{
+							t,
+							id,
+							err,
+						}
 					}
 					id++
 				})
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selection of trees among a set of input trees, typically trees
+// sampled by an MCMC (MrBayes, BEAST, etc.).
+//
+// Trees are selected according to their index in the input file:
+//	1. The first trees are discarded as burn-in
+//	2. Then, one tree every Thin trees is kept
+//	3. Only trees with index in [Start,End[ are kept
+type TreeSelection struct {
+	Burnin float64 // Number (>=1) or fraction (<1) of first trees to discard
+	Thin   int     // Keeps one tree every Thin trees after burn-in (<=1: all trees)
+	Start  int     // Index of the first tree to keep
+	End    int     // Index of the last tree to keep (excluded), <0: until the end
+}
+
+// Returns a TreeSelection that keeps all trees
+func NewTreeSelection() *TreeSelection {
+	return &TreeSelection{
+		Burnin: 0,
+		Thin:   1,
+		Start:  0,
+		End:    -1,
+	}
+}
+
+// Returns a TreeSelection with the given burn-in, thinning and range.
+//
+// treerange is of the form "start:end" (start included, end excluded,
+// 0-based indices). start and/or end may be omitted (":10", "100:",
+// or ":" or "" for all trees).
+//
+// Returns an error if burnin<0, or if the range is malformed.
+func ParseTreeSelection(burnin float64, thin int, treerange string) (s *TreeSelection, err error) {
+	s = NewTreeSelection()
+	if burnin < 0 {
+		err = fmt.Errorf("Burn-in must be >= 0: %f", burnin)
+		return
+	}
+	s.Burnin = burnin
+	if thin > 1 {
+		s.Thin = thin
+	}
+	if strings.TrimSpace(treerange) == "" {
+		return
+	}
+	bounds := strings.Split(treerange, ":")
+	if len(bounds) != 2 {
+		err = fmt.Errorf("Tree range should be of the form start:end: %s", treerange)
+		return
+	}
+	if b := strings.TrimSpace(bounds[0]); b != "" {
+		if s.Start, err = strconv.Atoi(b); err != nil || s.Start < 0 {
+			err = fmt.Errorf("Start of tree range should be a positive integer: %s", treerange)
+			return
+		}
+	}
+	if b := strings.TrimSpace(bounds[1]); b != "" {
+		if s.End, err = strconv.Atoi(b); err != nil || s.End < s.Start {
+			err = fmt.Errorf("End of tree range should be an integer >= start: %s", treerange)
+			return
+		}
+	}
+	return
+}
+
+// Returns the number of trees to discard as burn-in.
+// If the burn-in is a fraction, nbtrees is the total number
+// of input trees.
+func (s *TreeSelection) NBurnin(nbtrees int) int {
+	if s.fractionBurnin() {
+		return int(s.Burnin * float64(nbtrees))
+	}
+	return int(s.Burnin)
+}
+
+// Returns true if the tree with the given index is selected.
+// nbtrees is the total number of input trees, only used if the
+// burn-in is a fraction.
+func (s *TreeSelection) keep(id, nbtrees int) bool {
+	nburnin := s.NBurnin(nbtrees)
+	if id < nburnin || id < s.Start || s.done(id) {
+		return false
+	}
+	return s.Thin <= 1 || (id-nburnin)%s.Thin == 0
+}
+
+// Returns true if no tree with index >= id can be selected
+func (s *TreeSelection) done(id int) bool {
+	return s.End >= 0 && id >= s.End
+}
+
+// Returns true if the burn-in is a fraction of the input trees,
+// in which case the total number of trees must be known before
+// the selection
+func (s *TreeSelection) fractionBurnin() bool {
+	return s.Burnin > 0 && s.Burnin < 1
+}
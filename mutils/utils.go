@@ -3,6 +3,11 @@ Package with simple math util functions such as min, max, and abs
 */
 package mutils
 
+import (
+	"math"
+	"sort"
+)
+
 func Min(a, b int) int {
 	if a < b {
 		return a
@@ -23,3 +28,57 @@ func Abs(x int) int {
 	}
 	return x
 }
+
+// Returns the mean of the given values, NaN if empty
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// Returns the median of the given values, NaN if empty.
+// The input slice is not modified.
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	result := sorted[middle]
+	if len(sorted)%2 == 0 {
+		result = (result + sorted[middle-1]) / 2
+	}
+	return result
+}
+
+// Returns the highest posterior density interval of the given values,
+// i.e. the shortest interval containing the given proportion of values
+// (e.g. 0.95). Returns NaN, NaN if empty.
+// The input slice is not modified.
+func HPD(values []float64, proportion float64) (lower, upper float64) {
+	if len(values) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	diff := int(math.Round(proportion * float64(len(sorted))))
+	if diff < 1 {
+		diff = 1
+	}
+	minrange := math.Inf(1)
+	for i := 0; i <= len(sorted)-diff; i++ {
+		if r := sorted[i+diff-1] - sorted[i]; r < minrange {
+			minrange = r
+			lower, upper = sorted[i], sorted[i+diff-1]
+		}
+	}
+	return
+}
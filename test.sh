@@ -1150,6 +1150,707 @@ ${GOTREE} reformat newick -i nexus -f nexus -o result
 diff -q -b expected result
 rm -f expected result nexus
 
+echo "->gotree reformat attributes"
+cat > input <<EOF
+(t1[&species=human,n=2]:1[&rate=0.5],t2:1,(t3:1,t4:1)[&ok=true,name="a b"]:1);
+EOF
+cat > expected <<EOF
+(t1[&species=human,n=2]:1[&rate=0.5],t2:1,(t3:1,t4:1)[&ok=true,name="a b"]:1);
+(t1[&species=human,n=2]:1[&rate=0.5],t2:1,(t3:1,t4:1)[&ok=true,name="a b"]:1);
+EOF
+${GOTREE} reformat phyloxml -i input | ${GOTREE} reformat newick -f phyloxml > result
+${GOTREE} reformat nexus -i input | ${GOTREE} reformat newick -f nexus >> result
+diff -q -b expected result
+rm -f expected result input
+
+echo "->gotree reformat newick nhx"
+cat > input <<EOF
+((Tip1:0.1[&&NHX:S=human:D=N],Tip2:0.2[&&NHX:S=mouse])0.9:0.3[&&NHX:S=mammals:D=Y],Tip3:0.4[&&NHX:S=fish]);
+EOF
+cat > expected <<EOF
+((Tip1[&S=human,D=false]:0.1,Tip2[&S=mouse]:0.2)0.9[&S=mammals,D=true]:0.3,Tip3[&S=fish]:0.4);
+((Tip1:0.1[&&NHX:S=human:D=N],Tip2:0.2[&&NHX:S=mouse])0.9:0.3[&&NHX:S=mammals:D=Y],Tip3:0.4[&&NHX:S=fish]);
+EOF
+${GOTREE} reformat newick -i input > result
+${GOTREE} reformat newick -i input | ${GOTREE} reformat newick --nhx >> result
+diff -q -b expected result
+rm -f expected result input
+
+echo "->gotree stats nodes attributes"
+cat > input <<EOF
+#NEXUS
+BEGIN TREES;
+  TRANSLATE
+    1 A,
+    2 B,
+    3 C;
+  TREE STATE_0 [&lnP=-10.5] = [&R] ((1[&height=0.0]:1.5,2[&height=0.5]:1.0)[&height=1.5,height_95%_HPD={1.2,1.9},posterior=0.98]:0.5,3[&height=0.0]:2.0)[&height=2.0,posterior=1.0];
+END;
+EOF
+cat > expected <<EOF
+tree	nid	nneigh	name	depth	comments	height	height_95%_HPD	posterior
+0	0	2		1	[]	2.0	N/A	1.0
+0	1	3		1	[]	1.5	{1.2,1.9}	0.98
+0	2	1	A	0	[]	0.0	N/A	N/A
+0	3	1	B	0	[]	0.5	N/A	N/A
+0	4	1	C	0	[]	0.0	N/A	N/A
+EOF
+${GOTREE} stats nodes --format nexus --attributes height,height_95%_HPD,posterior -i input > result
+diff -q -b expected result
+rm -f expected result input
+
+echo "->gotree reformat nexus tree attributes"
+cat > input <<EOF
+#NEXUS
+BEGIN TREES;
+  TREE STATE_0 [&lnP=-10.5] = [&R] ((A:1.5,B:1.0)[&posterior=0.98]:0.5,C:2.0);
+END;
+EOF
+cat > expected <<EOF
+#NEXUS
+BEGIN TAXA;
+ DIMENSIONS NTAX=3;
+ TAXLABELS A B C;
+END;
+BEGIN TREES;
+  TREE tree0 [&lnP=-10.5] = [&R] ((A:1.5,B:1)[&posterior=0.98]:0.5,C:2);
+END;
+EOF
+${GOTREE} reformat nexus --format nexus -i input > result
+diff -q -b expected result
+rm -f expected result input
+
+echo "->gotree compute mcc"
+cat > input <<EOF
+((A:2,C:2):1,(B:2,D:2):1);
+((A:1,B:1):1,(C:1.5,D:1.5):0.5);
+((A:2,B:2):1,(C:1,D:1):2);
+((A:1,B:1):2,(C:2,D:2):1);
+((A:1,C:1):1,(B:1,D:1):1);
+(((A:1,B:1):1,C:2):1,D:3);
+EOF
+${GOTREE} compute mcc -i input --burnin 1 2>/dev/null | ${GOTREE} brlen clear | ${GOTREE} stats nodes --attributes posterior | cut -f 3,7 > result
+cat > expected <<EOF
+nneigh	posterior
+2	1.0
+3	0.8
+1	N/A
+1	N/A
+3	0.6
+1	N/A
+1	N/A
+EOF
+diff -q -b expected result
+rm -f expected result input
+
+echo "->gotree stats burnin/thin/range"
+cat > input <<EOF
+(A,B,(C,D));
+(A,C,(B,D));
+(A,D,(C,B));
+(A,B,(C,D));
+(A,C,(B,D));
+(A,D,(C,B));
+EOF
+cat > expected <<EOF
+1
+3
+5
+1
+2
+3
+EOF
+${GOTREE} stats --burnin 0.3 --thin 2 -i input | cut -f 1 | tail -n +2 > result
+${GOTREE} stats --range 1:4 -i input | cut -f 1 | tail -n +2 >> result
+diff -q -b expected result
+rm -f expected result input
+
+echo "->gotree compute nj/bionj/upgma"
+cat > input <<EOF
+((A:0.1,B:0.2):0.3,(C:0.4,D:0.1):0.2,(E:0.3,(F:0.1,G:0.2):0.5):0.1);
+EOF
+cat > expected <<EOF
+tree	reference	common	compared
+0	0	4	0
+0	0	4	0
+EOF
+${GOTREE} matrix -i input | ${GOTREE} compute nj | ${GOTREE} compare trees -i input -c - > result
+${GOTREE} matrix -i input | ${GOTREE} compute bionj | ${GOTREE} compare trees -i input -c - | tail -n +2 >> result
+diff -q -b expected result
+cat > input <<EOF
+4
+A
+B 2
+C 4 4
+D 6 6 6
+EOF
+cat > expected <<EOF
+(((A:1,B:1):1,C:2):1,D:3);
+EOF
+${GOTREE} compute upgma -i input > result
+diff -q -b expected result
+rm -f expected result input
+
+echo "->gotree spr/tbr"
+cat > expected <<EOF
+(A,(B,E),(C,D));
+(A,(B,(C,D)),E);
+((A,E),B,(C,D));
+((A,(C,D)),B,E);
+(A,B,((E,D),C));
+(A,B,((E,C),D));
+(((C,D),A),B,E);
+(A,((C,D),B),E);
+(A,B,(C,(D,E)));
+(A,B,(D,(C,E)));
+(A,B,((C,E),D));
+(A,B,(C,(E,D)));
+((A,E),B,(C,D));
+(A,(B,E),(C,D));
+(A,B,((C,E),D));
+(A,B,(C,(D,E)));
+EOF
+echo "(A,B,((C,D),E));" | ${GOTREE} spr --radius 1 > result
+diff -q -b expected result
+echo "(A,B,((C,D),E));" | ${GOTREE} tbr --random 3 --nb-trees 10 | ${GOTREE} stats tips | awk 'NR>1{print $1}' | sort -u | wc -l | tr -d ' ' > result
+echo "10" > expected
+diff -q -b expected result
+rm -f expected result
+
+echo "->gotree compare quartets"
+cat > expected <<EOF
+tree	common	conflict	reference	compared	unresolved	distance
+0	18	13	4	0	0	17
+1	0	0	35	0	0	35
+2	35	0	0	0	0	0
+EOF
+cat > input <<EOF
+((A,C),(B,D),(E,F,G));
+(A,B,C,D,E,F,G);
+(((G,F),E),(D,C),(B,A));
+EOF
+${GOTREE} compare quartets -i <(echo "((A,B),(C,D),(E,(F,G)));") -c input > result
+diff -q -b expected result
+rm -f expected result input
+
+echo "->gotree compute concordance"
+cat > genes <<EOF
+((A,B),(C,D),(E,F));
+((A,(C,D)),B,(E,F));
+((A,B),C,E);
+EOF
+cat > expected <<EOF
+((A,B)[&gCF=66.67,gDF1=33.33,gDF2=0.0,gDFP=0.0,gN=3],(C,D)[&gCF=100.0,gDF1=0.0,gDF2=0.0,gDFP=0.0,gN=2],(E,F)[&gCF=100.0,gDF1=0.0,gDF2=0.0,gDFP=0.0,gN=2]);
+EOF
+cat > expected_tsv <<EOF
+brid	length	support	rightname	gN	gCF	gDF1	gDF2	gDFP
+0	N/A	N/A		3	66.666667	33.333333	0.000000	0.000000
+3	N/A	N/A		2	100.000000	0.000000	0.000000	0.000000
+6	N/A	N/A		2	100.000000	0.000000	0.000000	0.000000
+EOF
+echo "((A,B),(C,D),(E,F));" | ${GOTREE} compute concordance -g genes --tsv result_tsv > result
+diff -q -b expected result
+diff -q -b expected_tsv result_tsv
+rm -f expected result genes expected_tsv result_tsv
+
+echo "->gotree acr ml"
+cat > tmp_states.txt <<EOF
+t1,A
+t2,A
+t3,B
+t4,B
+t5,A
+t6,B
+t7,B
+t8,A
+t9,A
+t10,A
+t11,A
+EOF
+cat > tmp_tree.txt <<EOF
+(t1:0.1,(t2:0.1,((t3:0.1,(t4:0.1,t5:0.1):0.1):0.1,(t6:0.1,((t7:0.1,t8:0.1):0.1,((t9:0.1,t10:0.1):0.1,t11:0.1):0.1):0.1):0.1):0.1):0.1);
+EOF
+cat > expected <<EOF
+(t1[A]:0.1,(t2[A]:0.1,((t3[B]:0.1,(t4[B]:0.1,t5[A]:0.1)[A|B]:0.1)[B]:0.1,(t6[B]:0.1,((t7[B]:0.1,t8[A]:0.1)[A|B]:0.1,((t9[A]:0.1,t10[A]:0.1)[A]:0.1,t11[A]:0.1)[A]:0.1)[A|B]:0.1)[A|B]:0.1)[A|B]:0.1)[A]:0.1)[A];
+EOF
+cat > expected_steps <<EOF
+lnl -9.982135
+rate 1.000000
+EOF
+${GOTREE} acr -i tmp_tree.txt --states tmp_states.txt --algo ml --ml-method mppa --model jc --rate 1 --out-steps result_steps -o result
+diff -q -b expected result
+diff -q -b expected_steps result_steps
+rm -f expected result expected_steps result_steps tmp_tree.txt tmp_states.txt
+
+echo "->gotree asr --out-align --out-subs"
+cat > tmp_align.txt <<EOF
+>t1
+AAAT
+>t2
+AAAT
+>t3
+CCCT
+>t4
+CCCT
+>t5
+AAAT
+>t6
+CCCT
+>t7
+CCCT
+>t8
+AAAT
+>t9
+AAAT
+>t10
+AAAT
+>t11
+AAAT
+EOF
+cat > tmp_tree.txt <<EOF
+(t1,(t2,((t3,(t4,t5)),(t6,((t7,t8),((t9,t10),t11))))));
+EOF
+cat > expected <<EOF
+>node0
+AAAT
+>node2
+AAAT
+>node4
+MMMT
+>node5
+MMMT
+>node7
+MMMT
+>node10
+MMMT
+>node12
+MMMT
+>node13
+MMMT
+>node16
+AAAT
+>node17
+AAAT
+EOF
+cat > expected_subs <<EOF
+tree	parent	child	site	parent_state	child_state	ambiguous
+0	node2	node4	1	A	C	false
+0	node2	node4	2	A	C	false
+0	node2	node4	3	A	C	false
+0	node7	t5	1	C	A	false
+0	node7	t5	2	C	A	false
+0	node7	t5	3	C	A	false
+0	node10	node12	1	C	A	false
+0	node10	node12	2	C	A	false
+0	node10	node12	3	C	A	false
+0	node13	t7	1	A	C	false
+0	node13	t7	2	A	C	false
+0	node13	t7	3	A	C	false
+EOF
+${GOTREE} asr -i tmp_tree.txt -a tmp_align.txt --algo downpass --out-align result -o /dev/null --log /dev/null
+diff -q -b expected result
+${GOTREE} asr -i tmp_tree.txt -a tmp_align.txt --algo acctran --out-subs result_subs -o /dev/null --log /dev/null
+diff -q -b expected_subs result_subs
+rm -f expected result expected_subs result_subs tmp_tree.txt tmp_align.txt
+
+echo "->gotree acr sankoff"
+cat > tmp_states.txt <<EOF
+t1,A
+t2,A
+t3,B
+t4,B
+t5,A
+t6,B
+t7,B
+t8,A
+t9,A
+t10,A
+t11,A
+EOF
+cat > tmp_tree.txt <<EOF
+(t1,(t2,((t3,(t4,t5)),(t6,((t7,t8),((t9,t10),t11))))));
+EOF
+cat > tmp_costs.txt <<EOF
+	A	B
+A	0	1
+B	inf	0
+EOF
+cat > expected <<EOF
+cost 4.000000
+(t1[A],(t2[A],((t3[B],(t4[B],t5[A])[A])[A],(t6[B],((t7[B],t8[A])[A],((t9[A],t10[A])[A],t11[A])[A])[A])[A])[A])[A])[A];
+EOF
+${GOTREE} acr -i tmp_tree.txt --states tmp_states.txt --algo sankoff --costs tmp_costs.txt > result
+diff -q -b expected result
+rm -f expected result tmp_tree.txt tmp_states.txt tmp_costs.txt
+
+echo "->gotree asr sankoff"
+cat > tmp_align.txt <<EOF
+>t1
+AAAT
+>t2
+AAAT
+>t3
+CCCT
+>t4
+CCCT
+>t5
+AAAT
+>t6
+CCCT
+>t7
+CCCT
+>t8
+AAAT
+>t9
+AAAT
+>t10
+AAAT
+>t11
+AAAT
+EOF
+cat > tmp_tree.txt <<EOF
+(t1,(t2,((t3,(t4,t5)),(t6,((t7,t8),((t9,t10),t11))))));
+EOF
+cat > tmp_costs.txt <<EOF
+	A	C	G	T
+A	0	1	1	1
+C	1	0	1	1
+G	1	1	0	1
+T	1	1	1	0
+EOF
+cat > expected <<EOF
+cost 4.000000 4.000000 4.000000 0.000000
+(t1[AAAT],(t2[AAAT],((t3[CCCT],(t4[CCCT],t5[AAAT])[{AC}{AC}{AC}T])[{AC}{AC}{AC}T],(t6[CCCT],((t7[CCCT],t8[AAAT])[{AC}{AC}{AC}T],((t9[AAAT],t10[AAAT])[AAAT],t11[AAAT])[AAAT])[{AC}{AC}{AC}T])[{AC}{AC}{AC}T])[{AC}{AC}{AC}T])[AAAT])[AAAT];
+EOF
+${GOTREE} asr -i tmp_tree.txt -a tmp_align.txt --algo sankoff --costs tmp_costs.txt > result
+diff -q -b expected result
+rm -f expected result tmp_tree.txt tmp_align.txt tmp_costs.txt
+
+echo "->gotree acr --traits"
+cat > tmp_traits.txt <<EOF
+tip	c1	c2
+t1	A	X
+t2	A	X
+t3	B	X
+t4	B	Y
+t5	A	Y
+t6	B	Y
+t7	B	X
+t8	A	X
+t9	A	X
+t10	A	Y
+t11	A	X
+EOF
+cat > tmp_tree.txt <<EOF
+(t1,(t2,((t3,(t4,t5)),(t6,((t7,t8),((t9,t10),t11))))));
+EOF
+cat > expected <<EOF
+(t1,(t2,((t3,(t4,t5)node7)node5,(t6,((t7,t8)node13,((t9,t10)node17,t11)node16)node12)node10)node4)node2)node0;
+EOF
+cat > expected_steps <<EOF
+tree	trait	steps
+0	c1	4
+0	c2	3
+EOF
+cat > expected_states <<EOF
+tree	node	c1	c2
+0	node0	A	X
+0	node2	A	X
+0	node4	B	X
+0	node5	B	X
+0	node7	B	Y
+0	node10	B	X
+0	node12	A	X
+0	node13	A	X
+0	node16	A	X
+0	node17	A	X
+EOF
+${GOTREE} acr -i tmp_tree.txt --traits tmp_traits.txt --algo acctran -o result --out-steps result_steps --out-states result_states
+diff -q -b expected result
+diff -q -b expected_steps result_steps
+diff -q -b expected_states result_states
+rm -f expected result expected_steps result_steps expected_states result_states tmp_tree.txt tmp_traits.txt
+
+echo "->gotree acr --out-stats"
+cat > tmp_traits.txt <<EOF
+tip	c1	c2
+t1	A	X
+t2	A	X
+t3	B	X
+t4	B	Y
+t5	A	Y
+t6	B	Y
+t7	B	X
+t8	A	X
+t9	A	X
+t10	A	Y
+t11	A	X
+EOF
+cat > tmp_tree.txt <<EOF
+(t1,(t2,((t3,(t4,t5)),(t6,((t7,t8),((t9,t10),t11))))));
+EOF
+cat > expected <<EOF
+tree	trait	steps	min_steps	max_steps	CI	RI	RC	pvalue
+0	c1	4	1	4	0.250000	0.000000	0.000000	NA
+0	c2	3	1	4	0.333333	0.333333	0.111111	NA
+0	all	7	2	8	0.285714	0.166667	0.047619	NA
+EOF
+${GOTREE} acr -i tmp_tree.txt --traits tmp_traits.txt --out-stats result -o /dev/null --out-steps /dev/null
+diff -q -b expected result
+rm -f expected result tmp_tree.txt tmp_traits.txt
+
+echo "->gotree compute association"
+cat > tmp_tree.txt <<EOF
+((((t1,t2),(t3,t4)),((t5,t6),(t7,t8))),(((t9,t10),(t11,t12)),((t13,t14),(t15,t16))));
+((((t1,t2),(t3,t4)),((t5,t6),(t7,t8))),(((t9,t10),(t11,t12)),((t13,t14),(t15,t16))));
+EOF
+cat > tmp_states.txt <<EOF
+t1,A
+t2,A
+t3,A
+t4,A
+t5,A
+t6,A
+t7,A
+t8,A
+t9,B
+t10,B
+t11,B
+t12,B
+t13,B
+t14,B
+t15,B
+t16,B
+EOF
+cat > expected <<EOF
+statistic	observed	observed_low	observed_high	pvalue
+AI	0.000015	0.000015	0.000015	0.000000
+PS	1.000000	1.000000	1.000000	0.000000
+MC(A)	8.000000	8.000000	8.000000	0.000000
+MC(B)	8.000000	8.000000	8.000000	0.000000
+EOF
+${GOTREE} compute association -i tmp_tree.txt --states tmp_states.txt --replicates 50 --seed 10 | cut -f 1-4,8 > result
+diff -q -b expected result
+rm -f expected result tmp_tree.txt tmp_states.txt
+
+echo "->gotree acr continuous"
+cat > tmp_tree.txt <<EOF
+((A:1,B:1):0.5,(C:1,D:1):0.5);
+EOF
+cat > tmp_states.txt <<EOF
+A	1
+B	3
+C	5
+D	9
+EOF
+cat > expected <<EOF
+((A:1,B:1)node1[&value=3.25,value_95%_CI={-0.036959553089298325,6.536959553089298}]:0.5,(C:1,D:1)node4[&value=5.75,value_95%_CI={2.4630404469107017,9.036959553089298}]:0.5)node0[&value=4.5,value_95%_CI={0.7045460344169636,8.295453965583036}];
+EOF
+cat > expected_states <<EOF
+tree	node	value	variance	ci_low	ci_high
+0	node0	4.500000	3.750000	0.704546	8.295454
+0	node1	3.250000	2.812500	-0.036960	6.536960
+0	node4	5.750000	2.812500	2.463040	9.036960
+EOF
+cat > expected_contrasts <<EOF
+tree	node	contrast	variance
+0	node1	-1.414214	2.000000
+0	node4	-2.828427	2.000000
+0	node0	-3.535534	2.000000
+EOF
+cat > expected_signal <<EOF
+tree	sigma2	lnl	K	K_pvalue	lambda	lambda_lnl	lambda0_lnl	lambda_pvalue
+0	7.500000	-9.823343	1.166667	NA	1.000000	-9.823343	-10.013862	0.537049
+EOF
+${GOTREE} acr continuous -i tmp_tree.txt --states tmp_states.txt -o result --out-states result_states --out-contrasts result_contrasts --out-signal result_signal
+diff -q -b expected result
+diff -q -b expected_states result_states
+diff -q -b expected_contrasts result_contrasts
+diff -q -b expected_signal result_signal
+rm -f expected expected_states expected_contrasts expected_signal result result_states result_contrasts result_signal tmp_tree.txt tmp_states.txt
+
+echo "->gotree generate traits"
+cat > tmp_tree.txt <<EOF
+((A:1,B:1):0.5,(C:1,D:1):0.5);
+EOF
+cat > expected <<EOF
+A	B
+B	B
+C	B
+D	B
+EOF
+cat > expected_states <<EOF
+node0	B
+node1	B
+node4	B
+EOF
+cat > expected_tree <<EOF
+((A:1,B:1)node1:0.5,(C:1,D:1)node4:0.5)node0;
+EOF
+cat > expected_bm <<EOF
+tip	trait1	trait2
+A	2.000000	2.000000
+B	2.000000	2.000000
+C	2.000000	2.000000
+D	2.000000	2.000000
+EOF
+${GOTREE} generate traits -i tmp_tree.txt --model mk --alphabet A,B --rate 0 --root-state B --seed 10 -o result --out-states result_states --out-tree result_tree
+${GOTREE} generate traits -i tmp_tree.txt --model bm --sigma2 0 --root-value 2 -n 2 --seed 10 -o result_bm
+diff -q -b expected result
+diff -q -b expected_states result_states
+diff -q -b expected_tree result_tree
+diff -q -b expected_bm result_bm
+rm -f expected expected_states expected_tree expected_bm result result_states result_tree result_bm tmp_tree.txt
+
+echo "->gotree generate sequences"
+cat > tmp_tree.txt <<EOF
+((A:0,B:0):0,(C:0,D:0):0);
+EOF
+cat > expected <<EOF
+4
+1
+3
+1
+EOF
+${GOTREE} generate sequences -i tmp_tree.txt --model hky --freqs 0.1,0.2,0.3,0.4 --alpha 0.5 --pinv 0.1 --length 50 --seed 10 -o tmp_align.fa --out-ancestral tmp_anc.fa
+grep -c ">" tmp_align.fa > result
+grep -v ">" tmp_align.fa | sort -u | wc -l | tr -d ' ' >> result
+grep -c ">" tmp_anc.fa >> result
+cat tmp_align.fa tmp_anc.fa | grep -v ">" | sort -u | wc -l | tr -d ' ' >> result
+diff -q -b expected result
+rm -f expected result tmp_tree.txt tmp_align.fa tmp_anc.fa
+
+echo "->gotree generate birthdeath"
+cat > expected <<EOF
+tree	tips	rooted
+0	20	rooted
+1	20	rooted
+2	20	rooted
+EOF
+${GOTREE} generate birthdeath -l 20 --birth 1,2 --death 0.5 --shifts 1 --rho 0.5 --seed 10 -n 3 | ${GOTREE} stats | cut -f 1,3,9 > result
+diff -q -b expected result
+rm -f expected result
+
+echo "->gotree generate coalescent"
+cat > tmp_dates.txt <<EOF
+A	2000
+B	2010
+C	2020
+D	2020
+EOF
+cat > expected <<EOF
+tree	tips	rooted
+0	20	rooted
+1	20	rooted
+0	4	rooted
+EOF
+${GOTREE} generate coalescent -l 20 --popsize 10 --growth 0.5 --seed 10 -n 2 | ${GOTREE} stats | cut -f 1,3,9 > result
+${GOTREE} generate coalescent --dates tmp_dates.txt --popsize 10 --seed 10 | ${GOTREE} stats | cut -f 1,3,9 | tail -n 1 >> result
+diff -q -b expected result
+rm -f expected result tmp_dates.txt
+
+echo "->gotree reroot temporal"
+cat > tmp_tree.txt <<EOF
+(A_2007:0.5,B_2005:0.3,(C_2007:0.4,(D_2005:0.1,E_2007:0.3):0.1):0.5);
+EOF
+cat > expected <<EOF
+((A_2007,B_2005),(C_2007,(D_2005,E_2007)));
+tree	rate	tmrca	correlation	r2	rms
+0	0.100000	2000.000000	1.000000	1.000000	0.000000
+EOF
+${GOTREE} reroot temporal -i tmp_tree.txt --date-regexp '_([0-9.]+)$' --out-stats tmp_stats.txt | ${GOTREE} brlen clear > result
+cat tmp_stats.txt >> result
+diff -q -b expected result
+rm -f expected result tmp_tree.txt tmp_stats.txt
+
+echo "->gotree reroot mad"
+cat > tmp_tree.txt <<EOF
+(A:1,B:1,(C:1.5,(D:0.5,E:0.5):1):1.5);
+EOF
+cat > expected <<EOF
+((A,B),(C,(D,E)));
+EOF
+${GOTREE} reroot mad -i tmp_tree.txt --out-scores tmp_scores.txt --out-stats tmp_stats.txt | ${GOTREE} brlen clear > result
+diff -q -b expected result
+if [ $(tail -n +2 tmp_scores.txt | wc -l) -ne 7 ]; then echo "Wrong number of branch scores"; exit 1; fi
+printf "tree\tambiguity\n0\t0.000000\n" > expected
+diff -q -b expected tmp_stats.txt
+rm -f expected result tmp_tree.txt tmp_scores.txt tmp_stats.txt
+
+echo "->gotree reroot minvar"
+cat > tmp_tree.txt <<EOF
+(A:1,B:1,(C:1.5,(D:0.5,E:0.5):1):1.5);
+EOF
+cat > expected <<EOF
+((A:1,B:1):1,(C:1.5,(D:0.5,E:0.5):1):0.5);
+EOF
+${GOTREE} reroot minvar -i tmp_tree.txt > result
+diff -q -b expected result
+rm -f expected result tmp_tree.txt
+
+echo "->gotree compute dating"
+cat > tmp_tree.txt <<EOF
+((A_2007:0.5,B_2005:0.3):0.2,(C_2007:0.4,(D_2005:0.1,E_2007:0.3):0.1):0.3);
+EOF
+cat > tmp_cal.txt <<EOF
+C_2007,D_2005	2003.5	-
+EOF
+cat > expected <<EOF
+tree	rate	root_date	objective
+0	0.100000	2000.000000	0.000000
+tree	rate	root_date	objective
+0	0.113455	2000.798077	0.001018
+EOF
+${GOTREE} compute dating -i tmp_tree.txt --date-regexp '_([0-9.]+)$' --out-stats result > /dev/null
+${GOTREE} compute dating -i tmp_tree.txt --date-regexp '_([0-9.]+)$' --calibrations tmp_cal.txt --out-stats tmp_stats.txt > /dev/null
+cat tmp_stats.txt >> result
+diff -q -b expected result
+rm -f expected result tmp_tree.txt tmp_cal.txt tmp_stats.txt
+
+echo "->gotree compute reconcile"
+cat > tmp_species.txt <<EOF
+((HUMAN,MOUSE),CHICK);
+EOF
+cat > tmp_tree.txt <<EOF
+((h1_HUMAN,m1_MOUSE),(h2_HUMAN,c1_CHICK));
+EOF
+cat > expected <<EOF
+((h1_HUMAN[&species=HUMAN],m1_MOUSE[&species=MOUSE])[&event=speciation],(h2_HUMAN[&species=HUMAN],c1_CHICK[&species=CHICK])[&event=speciation])[&event=duplication];
+tree	duplications	losses
+0	1	2
+EOF
+${GOTREE} compute reconcile -i tmp_tree.txt -s tmp_species.txt --species-regexp '_([^_]+)$' --out-stats tmp_stats.txt > result
+cat tmp_stats.txt >> result
+diff -q -b expected result
+cat > expected <<EOF
+((h2_HUMAN[&species=HUMAN],(h1_HUMAN[&species=HUMAN],m1_MOUSE[&species=MOUSE])[&event=speciation])[&event=duplication],c1_CHICK[&species=CHICK])[&event=speciation];
+tree	duplications	losses
+0	1	1
+EOF
+${GOTREE} compute reconcile -i tmp_tree.txt -s tmp_species.txt --species-regexp '_([^_]+)$' --root --out-stats tmp_stats.txt > result
+cat tmp_stats.txt >> result
+diff -q -b expected result
+rm -f expected result tmp_tree.txt tmp_species.txt tmp_stats.txt
+
+echo "->gotree compare trees distances"
+cat > tmp_ref.txt <<EOF
+((A:1,B:1):1,(C:1,D:1):1,E:1);
+EOF
+cat > tmp_comp.txt <<EOF
+((A:1,C:1):1,(B:1,D:1):1,E:1);
+((A:1,B:2):1,(C:1,D:1):1,E:1);
+(((A:1,B:1):1,E:1):0.5,(C:1,D:1):0.5);
+EOF
+cat > expected <<EOF
+tree	weighted_rf	branch_score	path	patristic	matching_split	generalized_rf
+0	4.000000	2.000000	4.000000	4.000000	4.000000	3.803910
+1	1.000000	1.000000	0.000000	2.000000	0.000000	0.000000
+2	0.000000	0.000000	0.000000	0.000000	0.000000	0.000000
+EOF
+${GOTREE} compare trees -i tmp_ref.txt -c tmp_comp.txt --weighted-rf --branch-score --path --patristic --matching-split --generalized-rf > result
+diff -q -b expected result
+rm -f expected result tmp_ref.txt tmp_comp.txt
+
 echo "->gotree acr acctran"
 cat > tmp_states.txt <<EOF
 1,A
@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/io/phyloxml"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestParseAttribute(t *testing.T) {
+	values := []string{"12", "12.5", "1e-3", "true", "FALSE", "human", "\"Homo sapiens\"", "{10.1,14.2}", "{a,\"b c\",3}"}
+	types := []int{tree.ATTRIBUTE_INT, tree.ATTRIBUTE_FLOAT, tree.ATTRIBUTE_FLOAT, tree.ATTRIBUTE_BOOL, tree.ATTRIBUTE_BOOL,
+		tree.ATTRIBUTE_STRING, tree.ATTRIBUTE_STRING, tree.ATTRIBUTE_LIST, tree.ATTRIBUTE_LIST}
+	for i, v := range values {
+		a := tree.ParseAttribute(v)
+		if a.Type() != types[i] {
+			t.Error(fmt.Sprintf("Attribute %s should have type %d but has type %d", v, types[i], a.Type()))
+		}
+	}
+
+	a := tree.ParseAttribute("{10.1,14}")
+	if l, err := a.FloatList(); err != nil {
+		t.Error(err)
+	} else if len(l) != 2 || l[0] != 10.1 || l[1] != 14.0 {
+		t.Error(fmt.Sprintf("Float list attribute is not valid: %v", l))
+	}
+	if _, err := tree.ParseAttribute("human").Float(); err == nil {
+		t.Error("A string attribute should not be converted to float")
+	}
+	if s := tree.ParseAttribute("\"Homo sapiens\"").String(); s != "Homo sapiens" {
+		t.Error(fmt.Sprintf("String attribute should be Homo sapiens but is %s", s))
+	}
+}
+
+func TestAttributesNewick(t *testing.T) {
+	treeString := "(Tip4[&species=human,rate=0.1]:0.1[&count=2],Tip0:0.1,(Tip3:0.1[c3],(Tip2:0.2,Tip1:0.2)[&name=\"a b\",ok=true]:0.3)0.9:0.4[&hpd={0.1,0.2}]);"
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if tr.Newick() != treeString {
+		t.Error(fmt.Sprintf("Tree with attributes is not written as expected: %s", tr.Newick()))
+	}
+
+	if err = tr.UpdateTipIndex(); err != nil {
+		t.Error(err)
+		return
+	}
+	tip, err := tr.TipNode("Tip4")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if a, ok := tip.Attribute("rate"); !ok {
+		t.Error("Tip4 should have a rate attribute")
+	} else if f, err := a.Float(); err != nil || f != 0.1 {
+		t.Error(fmt.Sprintf("Rate attribute of Tip4 should be 0.1: %s", a.String()))
+	}
+	if a, ok := tip.Edges()[0].Attribute("count"); !ok {
+		t.Error("Edge of Tip4 should have a count attribute")
+	} else if i, err := a.Int(); err != nil || i != 2 {
+		t.Error(fmt.Sprintf("Count attribute of Tip4 edge should be 2: %s", a.String()))
+	}
+
+	tip, _ = tr.TipNode("Tip3")
+	if len(tip.Edges()[0].Comments()) != 1 || tip.Edges()[0].Attributes().Len() != 0 {
+		t.Error("Comment of Tip3 edge should not be parsed as attribute")
+	}
+}
+
+func TestAttributesClone(t *testing.T) {
+	treeString := "(Tip4[&species=human]:0.1,Tip0:0.1,(Tip3:0.1,(Tip2:0.2,Tip1:0.2)[&ok=true]:0.3[&rate=0.1])0.9:0.4);"
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = tr.UpdateTipIndex(); err != nil {
+		t.Error(err)
+		return
+	}
+	clone := tr.Clone()
+	if clone.Newick() != treeString {
+		t.Error(fmt.Sprintf("Cloned tree does not have the same attributes: %s", clone.Newick()))
+	}
+	// Modifying clone attributes should not modify the original tree
+	ctip, _ := clone.TipNode("Tip4")
+	ctip.SetAttribute("species", tree.NewStringAttribute("mouse"))
+	tip, _ := tr.TipNode("Tip4")
+	if a, _ := tip.Attribute("species"); a.String() != "human" {
+		t.Error("Modifying attributes of the clone should not modify the original tree")
+	}
+}
+
+func TestAttributesRemoveTips(t *testing.T) {
+	treeString := "(Tip4:0.1,Tip0:0.1,(Tip3:0.1,(Tip2:0.2[&rate=0.2],Tip1:0.2)[&ok=true]:0.3[&rate=0.1,d=1])0.9:0.4);"
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err = tr.RemoveTips(false, "Tip1"); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := "(Tip4:0.1,Tip0:0.1,(Tip3:0.1,Tip2:0.5[&rate=0.2,d=1])0.9:0.4);"
+	if tr.Newick() != expected {
+		t.Error(fmt.Sprintf("Tree after tip removal does not have the expected attributes: %s", tr.Newick()))
+	}
+}
+
+func TestAttributesNHX(t *testing.T) {
+	treeString := "((Tip1:0.1[&&NHX:S=human:D=N],Tip2:0.2[&&NHX:S=mouse])90:0.3[&&NHX:S=mammals:D=Y:B=90],Tip3:0.4[&&NHX:S=\"Danio rerio\"]);"
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if tr.NewickNHX() != treeString {
+		t.Error(fmt.Sprintf("NHX tree is not written as expected: %s", tr.NewickNHX()))
+	}
+	expected := "((Tip1[&S=human,D=false]:0.1,Tip2[&S=mouse]:0.2)90[&S=mammals,D=true,B=90]:0.3,Tip3[&S=\"Danio rerio\"]:0.4);"
+	if tr.Newick() != expected {
+		t.Error(fmt.Sprintf("NHX tree is not written as expected in newick: %s", tr.Newick()))
+	}
+
+	if err = tr.UpdateTipIndex(); err != nil {
+		t.Error(err)
+		return
+	}
+	tip, _ := tr.TipNode("Tip3")
+	if a, ok := tip.Attribute("S"); !ok || a.String() != "Danio rerio" {
+		t.Error("Tip3 should have species Danio rerio")
+	}
+	n, _ := tip.Parent()
+	if a, ok := n.Neigh()[0].Attribute("D"); !ok {
+		t.Error("Internal node should have a duplication attribute")
+	} else if d, err := a.Bool(); err != nil || !d {
+		t.Error(fmt.Sprintf("Internal node should be a duplication: %s", a.String()))
+	}
+
+	if _, err = tree.ParseNHXAttributes("&&NHX:S=human:D"); err == nil {
+		t.Error("Malformed NHX tag should give an error")
+	}
+}
+
+// Attributes (including lists) are written as PhyloXML properties
+// and are parsed back with the same types
+func TestAttributesPhyloXML(t *testing.T) {
+	treeString := "(Tip4[&species=human,rate=0.1]:0.1[&count=2],Tip0:0.1,(Tip3:0.1,(Tip2:0.2,Tip1:0.2)[&name=\"a b\",ok=true]:0.3)0.9:0.4[&hpd={0.1,0.2}]);"
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	treechan := make(chan tree.Trees, 1)
+	treechan <- tree.Trees{Tree: tr, Id: 0}
+	close(treechan)
+	xml, err := phyloxml.WritePhyloXML(treechan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	px, err := phyloxml.NewParser(strings.NewReader(xml)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tr2 *tree.Tree
+	px.IterateTrees(func(pt *tree.Tree, perr error) {
+		tr2, err = pt, perr
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr2.Newick() != treeString {
+		t.Errorf("Tree with attributes is not the same after PhyloXML round trip: %s", tr2.Newick())
+	}
+	for _, e := range tr2.Edges() {
+		if a, ok := e.Attribute("hpd"); ok {
+			if l, err := a.FloatList(); err != nil || len(l) != 2 || l[0] != 0.1 || l[1] != 0.2 {
+				t.Errorf("hpd attribute should be a list of 2 floats after PhyloXML round trip: %s", a.String())
+			}
+		}
+	}
+}
+
+// Whitespaces inside comments are kept (they may be part of attribute values),
+// except at the beginning and at the end of the comment
+func TestNewickCommentWhitespace(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader("(A[a b]:1,B[  c  d ]:1,C:1[ e f]);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"A": "a b", "B": "c  d"}
+	for _, tip := range tr.Tips() {
+		if c, ok := expected[tip.Name()]; ok {
+			if comments := tip.Comments(); len(comments) != 1 || comments[0] != c {
+				t.Errorf("Comment of tip %s should be \"%s\" but is %v", tip.Name(), c, comments)
+			}
+		}
+	}
+	for _, e := range tr.Edges() {
+		if e.Right().Name() == "C" {
+			if comments := e.Comments(); len(comments) != 1 || comments[0] != "e f" {
+				t.Errorf("Comment of edge C should be \"e f\" but is %v", comments)
+			}
+		}
+	}
+	if nw := tr.Newick(); nw != "(A[a b]:1,B[c  d]:1,C:1[e f]);" {
+		t.Errorf("Newick output should keep whitespaces inside comments: %s", nw)
+	}
+}
@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Root-to-tip distances of all tips of the tree
+func rootToTipDistances(t *tree.Tree) (dists map[string]float64) {
+	dists = make(map[string]float64)
+	depths := make(map[*tree.Node]float64)
+	t.PreOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) (keep bool) {
+		if prev != nil {
+			depths[cur] = depths[prev] + e.Length()
+		}
+		if cur.Tip() {
+			dists[cur.Name()] = depths[cur]
+		}
+		return true
+	})
+	return
+}
+
+func TestBirthDeathFixedTips(t *testing.T) {
+	rand.Seed(10)
+	m := tree.BirthDeathModel{Birth: []float64{1}, Death: []float64{0.5}, Rho: 0.5}
+	for i := 0; i < 20; i++ {
+		tr, err := tree.RandomBirthDeathTree(m, 30, -1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tr.Tips()) != 30 {
+			t.Errorf("Tree should have %d tips, but has %d", 30, len(tr.Tips()))
+		}
+		if !tr.Rooted() {
+			t.Errorf("Birth-death tree should be rooted")
+		}
+		// Reconstructed tree without serial sampling is ultrametric
+		var depth float64 = -1
+		for name, d := range rootToTipDistances(tr) {
+			if depth >= 0 && math.Abs(d-depth) > 1e-9 {
+				t.Errorf("Tree should be ultrametric, but tip %s is at distance %f (vs %f)", name, d, depth)
+				break
+			}
+			depth = d
+		}
+	}
+}
+
+// With a fixed number of tips, trees are sampled with the general sampling approach
+// (Hartmann et al., 2010), i.e. with a uniform prior on the time of the present
+func TestBirthDeathFixedTipsGSA(t *testing.T) {
+	rand.Seed(10)
+	ntrees := 2000
+
+	// Yule process: the process stays an exponential time of rate k*birth with k lineages,
+	// and so does the time between the last speciation and the present
+	m := tree.BirthDeathModel{Birth: []float64{1}, Rho: 1}
+	sum := 0.0
+	for i := 0; i < ntrees; i++ {
+		tr, err := tree.RandomBirthDeathTree(m, 3, -1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += rootToTipDistances(tr)["Tip0"]
+	}
+	if mean, exp := sum/float64(ntrees), 1.0/2+1.0/3; math.Abs(mean-exp)/exp > 0.05 {
+		t.Errorf("Mean root age of Yule trees with 3 tips is %f and should be close to %f", mean, exp)
+	}
+
+	// Birth-death process with 2 tips: the density of the time a of the present is proportional
+	// to the probability to have 2 lineages after time a, starting from 2 lineages:
+	// p1(a)^2+2*p0(a)*p2(a), pk(a) being the probability that a lineage has k descendants
+	m = tree.BirthDeathModel{Birth: []float64{1}, Death: []float64{0.5}, Rho: 1}
+	sum = 0.0
+	for i := 0; i < ntrees; i++ {
+		tr, err := tree.RandomBirthDeathTree(m, 2, -1, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dists := rootToTipDistances(tr)
+		for _, tip := range tr.Tips() {
+			if a, _ := tip.Attribute("status"); a.String() == "extant" {
+				sum += dists[tip.Name()]
+				break
+			}
+		}
+	}
+	var norm, exp float64
+	for a := 0.0; a < 100; a += 0.001 {
+		e := math.Exp(0.5 * a)
+		alpha, beta := 0.5*(e-1)/(e-0.5), (e-1)/(e-0.5)
+		p0, p1 := alpha, (1-alpha)*(1-beta)
+		p := p1*p1 + 2*p0*p1*beta
+		norm += p
+		exp += a * p
+	}
+	exp /= norm
+	if mean := sum / float64(ntrees); math.Abs(mean-exp)/exp > 0.05 {
+		t.Errorf("Mean age of birth-death trees with 2 tips is %f and should be close to %f", mean, exp)
+	}
+}
+
+func TestBirthDeathFixedAge(t *testing.T) {
+	rand.Seed(10)
+	// Yule process: expected number of tips after time 2 is 2*exp(2)
+	m := tree.BirthDeathModel{Birth: []float64{1}, Rho: 1}
+	ntrees, sum := 300, 0
+	for i := 0; i < ntrees; i++ {
+		tr, err := tree.RandomBirthDeathTree(m, -1, 2, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum += len(tr.Tips())
+		for name, d := range rootToTipDistances(tr) {
+			if math.Abs(d-2) > 1e-9 {
+				t.Errorf("Tip %s is at distance %f from the root, and should be at distance %f", name, d, 2.0)
+				break
+			}
+		}
+	}
+	mean, exp := float64(sum)/float64(ntrees), 2*math.Exp(2)
+	if math.Abs(mean-exp)/exp > 0.1 {
+		t.Errorf("Mean number of tips is %f and should be close to %f", mean, exp)
+	}
+}
+
+func TestBirthDeathComplete(t *testing.T) {
+	rand.Seed(10)
+	// Rate shift and serial sampling
+	m := tree.BirthDeathModel{
+		Birth:    []float64{2, 1},
+		Death:    []float64{0.5, 1},
+		Sampling: []float64{0, 0.5},
+		Shifts:   []float64{1},
+		Rho:      0.5,
+	}
+	tr, err := tree.RandomBirthDeathTree(m, -1, 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tip := range tr.Tips() {
+		a, ok := tip.Attribute("status")
+		if !ok {
+			t.Fatalf("Tip %s has no status", tip.Name())
+		}
+		d := rootToTipDistances(tr)[tip.Name()]
+		switch a.String() {
+		case "extant", "unsampled":
+			if math.Abs(d-3) > 1e-9 {
+				t.Errorf("Tip %s alive at present should be at distance %f from the root, not %f", tip.Name(), 3.0, d)
+			}
+		case "sampled":
+			if d < 1 || d > 3 {
+				t.Errorf("Tip %s sampled at time %f, but sampling rate is 0 before time 1", tip.Name(), d)
+			}
+		case "extinct":
+			if d > 3 {
+				t.Errorf("Tip %s extinct after present", tip.Name())
+			}
+		default:
+			t.Errorf("Unknown status %s", a.String())
+		}
+	}
+
+	if _, err = tree.RandomBirthDeathTree(tree.BirthDeathModel{Birth: []float64{1, 2}, Rho: 1}, 10, -1, false); err == nil {
+		t.Errorf("Rates for 2 intervals without rate shift should return an error")
+	}
+}
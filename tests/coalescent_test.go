@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Height of the root: maximum root-to-tip distance
+func rootHeight(t *tree.Tree) (height float64) {
+	for _, d := range rootToTipDistances(t) {
+		height = math.Max(height, d)
+	}
+	return
+}
+
+func TestCoalescent(t *testing.T) {
+	rand.Seed(10)
+	ntrees, ntips := 2000, 10
+	m := tree.CoalescentModel{PopSize: 1}
+	sum := 0.0
+	for i := 0; i < ntrees; i++ {
+		tr, err := tree.RandomCoalescentTree(m, ntips)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tr.Tips()) != ntips || !tr.Rooted() {
+			t.Fatalf("Coalescent tree should be rooted and have %d tips", ntips)
+		}
+		h := rootHeight(tr)
+		for name, d := range rootToTipDistances(tr) {
+			if math.Abs(d-h) > 1e-9 {
+				t.Fatalf("Coalescent tree should be ultrametric, but tip %s is at distance %f (vs %f)", name, d, h)
+			}
+		}
+		sum += h
+	}
+	// Expected TMRCA: 2N(1-1/n)
+	exp := 2 * (1 - 1/float64(ntips))
+	if mean := sum / float64(ntrees); math.Abs(mean-exp) > 0.1 {
+		t.Errorf("Mean TMRCA is %f and should be close to %f", mean, exp)
+	}
+
+	// Exponential growth: coalescences are more recent
+	growthsum := 0.0
+	for i := 0; i < ntrees; i++ {
+		tr, err := tree.RandomCoalescentTree(tree.CoalescentModel{PopSize: 1, Growth: 2}, ntips)
+		if err != nil {
+			t.Fatal(err)
+		}
+		growthsum += rootHeight(tr)
+	}
+	if growthsum >= sum {
+		t.Errorf("Mean TMRCA with exponential growth (%f) should be lower than with constant size (%f)", growthsum/float64(ntrees), sum/float64(ntrees))
+	}
+}
+
+func TestSerialCoalescent(t *testing.T) {
+	rand.Seed(10)
+	dates := map[string]float64{"A": 2000, "B": 2010, "C": 2020, "D": 2020, "E": 1990}
+	tr, err := tree.RandomSerialCoalescentTree(tree.CoalescentModel{PopSize: 5}, dates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dists := rootToTipDistances(tr)
+	// root date + root-to-tip distance = sampling date
+	rootdate := dates["C"] - dists["C"]
+	for name, d := range dists {
+		if math.Abs(rootdate+d-dates[name]) > 1e-9 {
+			t.Errorf("Tip %s: root date + distance is %f and should be %f", name, rootdate+d, dates[name])
+		}
+	}
+	if rootdate > 1990 {
+		t.Errorf("Root date %f should be before the first sampling date", rootdate)
+	}
+
+	if _, err = tree.RandomSerialCoalescentTree(tree.CoalescentModel{PopSize: 0.001, Growth: -1000}, dates); err == nil {
+		t.Errorf("Lineages that cannot coalesce should return an error")
+	}
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestGeneConcordance(t *testing.T) {
+	species, err := newick.NewParser(strings.NewReader("((A,B),(C,D),(E,F));")).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	genes := []string{
+		"((A,B),(C,D),(E,F));",
+		"((A,(C,D)),B,(E,F));",
+		"((A,B),C,E);",
+	}
+	genetrees := make(chan tree.Trees, len(genes))
+	for i, g := range genes {
+		gt, err := newick.NewParser(strings.NewReader(g)).Parse()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		genetrees <- tree.Trees{Tree: gt, Id: i}
+	}
+	close(genetrees)
+
+	conc, err := tree.GeneConcordance(species, genetrees)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(conc) != 3 {
+		t.Error(fmt.Errorf("There should be 3 internal edges and there are %d", len(conc)))
+		return
+	}
+	// Expected: decisive, concordant, discordant1, discordant2
+	expected := map[string][]int{
+		"A": {3, 2, 1, 0},
+		"C": {2, 2, 0, 0},
+		"E": {2, 2, 0, 0},
+	}
+	for _, ec := range conc {
+		tip := ec.Edge.Right().Neigh()[1].Name()
+		exp := expected[tip]
+		if ec.Decisive != exp[0] || ec.Concordant != exp[1] || ec.Discordant1 != exp[2] || ec.Discordant2 != exp[3] {
+			t.Error(fmt.Errorf("Concordance of edge with tip %s should be %v and is [%d %d %d %d]", tip, exp, ec.Decisive, ec.Concordant, ec.Discordant1, ec.Discordant2))
+		}
+		if a, ok := ec.Edge.Attribute("gN"); !ok || a.String() != fmt.Sprintf("%d", exp[0]) {
+			t.Error(fmt.Errorf("Edge with tip %s should have attribute gN=%d", tip, exp[0]))
+		}
+	}
+
+	// Gene tree with unknown tips
+	genetrees2 := make(chan tree.Trees, 1)
+	gt, _ := newick.NewParser(strings.NewReader("((A,B),(C,G),E);")).Parse()
+	genetrees2 <- tree.Trees{Tree: gt, Id: 0}
+	close(genetrees2)
+	if _, err = tree.GeneConcordance(species, genetrees2); err == nil {
+		t.Error("Gene tree with unknown tips should give an error")
+	}
+}
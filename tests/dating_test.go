@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func nodeDate(t *testing.T, n *tree.Node) float64 {
+	a, ok := n.Attribute("date")
+	if !ok {
+		t.Fatalf("Node %s should have a date attribute", n.Name())
+	}
+	d, err := a.Float()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestLeastSquaresDatingClock(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader(clocktree)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := tr.LeastSquaresDating(clockdates, tree.DatingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(res.Rate-0.1) > 1e-6 || math.Abs(res.RootDate-2000) > 1e-6 || res.Objective > 1e-9 {
+		t.Errorf("Rate, root date and objective should be 0.1, 2000 and 0, but are %f, %f and %f", res.Rate, res.RootDate, res.Objective)
+	}
+	for _, e := range tr.Edges() {
+		if d := nodeDate(t, e.Right()) - nodeDate(t, e.Left()); math.Abs(d-e.Length()) > 1e-9 {
+			t.Errorf("Branch length %f should be the difference of node dates %f", e.Length(), d)
+		}
+	}
+	if dates := rootToTipDistances(tr); math.Abs(dates["A"]-7) > 1e-6 || math.Abs(dates["B"]-5) > 1e-6 {
+		t.Errorf("Root-to-tip durations should be 7 and 5, but are %f and %f", dates["A"], dates["B"])
+	}
+}
+
+// Compares the dates of a tree with 2 internal nodes (fixed rate)
+// with the minimum objective found by a grid search
+func TestLeastSquaresDatingGrid(t *testing.T) {
+	dates := map[string]float64{"A": 2010, "B": 2008, "C": 2009}
+	inf := math.Inf(1)
+	for _, c := range []struct{ nlow, nup, rlow, rup float64 }{
+		{-inf, inf, -inf, inf},
+		{2007, inf, -inf, inf},
+		{-inf, 2004, -inf, 2001},
+		{-inf, inf, 2005, inf},
+	} {
+		tr, err := newick.NewParser(strings.NewReader("((A:0.05,B:0.6):0.4,C:0.3);")).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := tree.DatingOptions{Rate: 0.1, Calibrations: []tree.DatingCalibration{
+			{Tips: []string{"A", "B"}, Lower: c.nlow, Upper: c.nup},
+			{Tips: []string{"A", "C"}, Lower: c.rlow, Upper: c.rup},
+		}}
+		res, err := tr.LeastSquaresDating(dates, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sq := func(x float64) float64 { return x * x }
+		min := math.Inf(1)
+		for i := 0; i <= 2600; i++ {
+			n := 1995 + float64(i)/200
+			if n < c.nlow || n > c.nup {
+				continue
+			}
+			for j := 0; j <= i; j++ {
+				r := 1995 + float64(j)/200
+				if r < c.rlow || r > c.rup {
+					continue
+				}
+				obj := sq(0.05-0.1*(2010-n)) + sq(0.6-0.1*(2008-n)) + sq(0.4-0.1*(n-r)) + sq(0.3-0.1*(2009-r))
+				min = math.Min(min, obj)
+			}
+		}
+		if math.Abs(res.Objective-min) > 1e-5 {
+			t.Errorf("Calibrations %v: objective should be %f and is %f", c, min, res.Objective)
+		}
+		for _, e := range tr.Edges() {
+			if e.Length() < 0 {
+				t.Errorf("Calibrations %v: branch lengths should be >= 0", c)
+			}
+		}
+	}
+}
+
+func TestLeastSquaresDatingRandom(t *testing.T) {
+	rand.Seed(10)
+	tr, err := tree.RandomYuleBinaryTree(50, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates := make(map[string]float64)
+	for _, tip := range tr.Tips() {
+		dates[tip.Name()] = 2000 + rand.Float64()*20
+	}
+	tips := tr.Tips()
+	calibration := tree.DatingCalibration{Tips: []string{tips[0].Name(), tips[1].Name(), tips[2].Name()}, Lower: 1995, Upper: 1999}
+	res, err := tr.LeastSquaresDating(dates, tree.DatingOptions{SeqLen: 1000, Calibrations: []tree.DatingCalibration{calibration}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Rate <= 0 {
+		t.Errorf("Rate should be > 0: %f", res.Rate)
+	}
+	for _, e := range tr.Edges() {
+		if e.Length() < 0 {
+			t.Errorf("Branch lengths should be >= 0")
+		}
+	}
+	for name, d := range rootToTipDistances(tr) {
+		if math.Abs(res.RootDate+d-dates[name]) > 1e-6 {
+			t.Errorf("Date of tip %s should be %f and is %f", name, dates[name], res.RootDate+d)
+		}
+	}
+	lca, _, _, err := tr.LeastCommonAncestorRooted(nil, calibration.Tips...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := nodeDate(t, lca); d < 1995-1e-9 || d > 1999+1e-9 {
+		t.Errorf("Date of the calibrated node should be in [1995,1999] and is %f", d)
+	}
+
+	if _, err = tr.LeastSquaresDating(map[string]float64{"Tip1": 2000}, tree.DatingOptions{}); err == nil {
+		t.Errorf("Tips without date should return an error")
+	}
+}
+
+func TestLeastSquaresDatingUnrooted(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader("(A:0.5,B:0.3,(C:0.2,D:0.6):0.1);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates := map[string]float64{"A": 2000, "B": 2001, "C": 2002, "D": 2003}
+	if _, err = tr.LeastSquaresDating(dates, tree.DatingOptions{}); err == nil {
+		t.Errorf("Unrooted tree should return an error")
+	}
+}
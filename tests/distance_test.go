@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"bufio"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/io/utils"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Infers trees from the distance matrix of the input tree, and checks
+// that the topologies and the distances are the same
+func TestDistanceMethods(t *testing.T) {
+	additive := "((A:0.1,B:0.2):0.3,(C:0.4,D:0.1):0.2,(E:0.3,(F:0.1,G:0.2):0.5):0.1);"
+	ultrametric := "(((A:1,B:1):2,C:3):1,((D:1.5,E:1.5):1,(F:0.5,G:0.5):2):1.5);"
+
+	methods := map[string]func([]string, [][]float64) (*tree.Tree, error){
+		"nj": func(names []string, mat [][]float64) (*tree.Tree, error) {
+			return tree.NeighborJoining(names, mat, tree.NEGATIVE_KEEP)
+		},
+		"bionj": func(names []string, mat [][]float64) (*tree.Tree, error) {
+			return tree.BioNJ(names, mat, tree.NEGATIVE_KEEP)
+		},
+		"upgma": tree.UPGMA,
+	}
+
+	for name, method := range methods {
+		s := additive
+		if name == "upgma" {
+			s = ultrametric
+		}
+		tr, err := newick.NewParser(strings.NewReader(s)).Parse()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		tr.ReinitIndexes()
+		names := make([]string, 0)
+		for _, tip := range tr.Tips() {
+			names = append(names, tip.Name())
+		}
+		mat := tr.ToDistanceMatrix()
+
+		inferred, err := method(names, mat)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = inferred.CompareTipIndexes(tr); err != nil {
+			t.Error(err)
+			return
+		}
+		if name != "upgma" {
+			// NJ and BIONJ give unrooted trees
+			tr.UnRoot()
+			tr.ReinitIndexes()
+		}
+		if diff, _, err := tr.CommonEdges(inferred, false); err != nil {
+			t.Error(err)
+		} else if diff != 0 {
+			t.Errorf("%s: inferred tree %s is different from %s", name, inferred.Newick(), s)
+		}
+
+		// Distances between tips must be the same
+		inferredtips := inferred.Tips()
+		inferredmat := inferred.ToDistanceMatrix()
+		for i, t1 := range tr.Tips() {
+			for j, t2 := range tr.Tips() {
+				for k, t3 := range inferredtips {
+					for l, t4 := range inferredtips {
+						if t1.Name() == t3.Name() && t2.Name() == t4.Name() && math.Abs(mat[i][j]-inferredmat[k][l]) > 1e-9 {
+							t.Errorf("%s: distance between %s and %s should be %f and is %f", name, t1.Name(), t2.Name(), mat[i][j], inferredmat[k][l])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestNegativeLengths(t *testing.T) {
+	names := []string{"A", "B", "C", "D"}
+	mat := [][]float64{
+		{0, 10, 1, 5},
+		{10, 0, 1, 5},
+		{1, 1, 0, 5},
+		{5, 5, 5, 0},
+	}
+	for _, negative := range []int{tree.NEGATIVE_ZERO, tree.NEGATIVE_TRANSFER} {
+		tr, err := tree.NeighborJoining(names, mat, negative)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		for _, e := range tr.Edges() {
+			if e.Length() < 0 {
+				t.Errorf("Branch lengths should not be negative: %s", tr.Newick())
+			}
+		}
+	}
+	tr, err := tree.NeighborJoining(names, mat, tree.NEGATIVE_KEEP)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	negative := false
+	for _, e := range tr.Edges() {
+		negative = negative || e.Length() < 0
+	}
+	if !negative {
+		t.Errorf("NJ tree should have negative branch lengths: %s", tr.Newick())
+	}
+}
+
+func TestReadDistanceMatrix(t *testing.T) {
+	square := "3\nA 0 1 2\nB 1 0 3\nC 2 3 0\n"
+	lower := "3\nA\nB 1\nC 2 3\n"
+	lowerdiag := "3\nA 0\nB 1 0\nC\t2\t3\t0\n"
+	expected := [][]float64{{0, 1, 2}, {1, 0, 3}, {2, 3, 0}}
+
+	for _, s := range []string{square, lower, lowerdiag} {
+		names, mat, err := utils.ReadDistanceMatrixReader(bufio.NewReader(strings.NewReader(s)))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if strings.Join(names, ",") != "A,B,C" {
+			t.Errorf("Names should be A,B,C and are %v", names)
+		}
+		for i := range expected {
+			for j := range expected[i] {
+				if mat[i][j] != expected[i][j] {
+					t.Errorf("Distance (%d,%d) should be %f and is %f", i, j, expected[i][j], mat[i][j])
+				}
+			}
+		}
+	}
+
+	for _, s := range []string{"3\nA 0 1 2\nB 1 0 3\nC 2 4 0\n", "3\nA 0 1\nB 1 0\nC 2 4 0\n", "A 0 1 2"} {
+		if _, _, err := utils.ReadDistanceMatrixReader(bufio.NewReader(strings.NewReader(s))); err == nil {
+			t.Errorf("Matrix should not be valid: %s", s)
+		}
+	}
+}
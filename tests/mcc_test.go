@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestMaxCladeCredibility(t *testing.T) {
+	treeStrings := []string{
+		"((A:2,C:2):1,(B:2,D:2):1);",
+		"((A:1,B:1):1,(C:1.5,D:1.5):0.5);",
+		"((A:2,B:2):1,(C:1,D:1):2);",
+		"((A:1,B:1):2,(C:2,D:2):1);",
+		"((A:1,C:1):1,(B:1,D:1):1);",
+		"(((A:1,B:1):1,C:2):1,D:3);",
+	}
+	trees := make(chan tree.Trees, len(treeStrings))
+	// The first tree is discarded (burn-in)
+	for i, s := range treeStrings[1:] {
+		tr, err := newick.NewParser(strings.NewReader(s)).Parse()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		trees <- tree.Trees{Tree: tr, Id: i + 1}
+	}
+	close(trees)
+
+	mcc, id, _, err := tree.MaxCladeCredibility(trees)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if id != 1 {
+		t.Error(fmt.Sprintf("MCC tree should be tree 1 and is tree %d", id))
+	}
+	for _, n := range mcc.Nodes() {
+		if n.Tip() || n == mcc.Root() {
+			continue
+		}
+		a, ok := n.Attribute("posterior")
+		if !ok {
+			t.Error("Internal nodes of the MCC tree should have a posterior attribute")
+			continue
+		}
+		p, _ := a.Float()
+		h, _ := n.Attribute("height_mean")
+		hpd, _ := n.Attribute("height_95%_HPD")
+		switch n.Neigh()[1].Name() {
+		case "A":
+			if p != 0.8 || h.String() != "1.25" || hpd.String() != "{1.0,2.0}" {
+				t.Error(fmt.Sprintf("Clade (A,B) should have posterior 0.8 and mean height 1.25: %s %s %s", a.String(), h.String(), hpd.String()))
+			}
+		case "C":
+			if p != 0.6 || h.String() != "1.5" {
+				t.Error(fmt.Sprintf("Clade (C,D) should have posterior 0.6 and mean height 1.5: %s %s", a.String(), h.String()))
+			}
+		}
+	}
+}
+
+func TestMaxCladeCredibilityNoTree(t *testing.T) {
+	trees := make(chan tree.Trees)
+	close(trees)
+	if _, _, _, err := tree.MaxCladeCredibility(trees); err == nil {
+		t.Error("An empty set of trees should give an error")
+	}
+}
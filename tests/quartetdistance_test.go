@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Topology of quartet a,b,c,d given the distance matrix of a tree
+// with unit branch lengths: 0: ab|cd, 1: ac|bd, 2: ad|bc, -1: unresolved
+func quartetTopology(d [][]float64, a, b, c, e int) int {
+	x := d[a][b] + d[c][e]
+	y := d[a][c] + d[b][e]
+	z := d[a][e] + d[b][c]
+	switch {
+	case x < y && x < z:
+		return 0
+	case y < x && y < z:
+		return 1
+	case z < x && z < y:
+		return 2
+	}
+	return -1
+}
+
+// Computes quartet statistics by enumerating all the quartets
+func bruteForceQuartets(t1, t2 *tree.Tree) (stats tree.QuartetStats) {
+	for _, e := range t1.Edges() {
+		e.SetLength(1)
+	}
+	for _, e := range t2.Edges() {
+		e.SetLength(1)
+	}
+	tips1 := t1.Tips()
+	d1 := t1.ToDistanceMatrix()
+	d2tmp := t2.ToDistanceMatrix()
+	// Reorder t2 distance matrix in the t1 tip order
+	index2 := make(map[string]int)
+	for i, tip := range t2.Tips() {
+		index2[tip.Name()] = i
+	}
+	n := len(tips1)
+	d2 := make([][]float64, n)
+	for i := range d2 {
+		d2[i] = make([]float64, n)
+		for j := range d2[i] {
+			d2[i][j] = d2tmp[index2[tips1[i].Name()]][index2[tips1[j].Name()]]
+		}
+	}
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			for c := b + 1; c < n; c++ {
+				for e := c + 1; e < n; e++ {
+					q1 := quartetTopology(d1, a, b, c, e)
+					q2 := quartetTopology(d2, a, b, c, e)
+					switch {
+					case q1 == -1 && q2 == -1:
+						stats.Unresolved++
+					case q1 == -1:
+						stats.CompResolved++
+					case q2 == -1:
+						stats.RefResolved++
+					case q1 == q2:
+						stats.Common++
+					default:
+						stats.Conflict++
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+func TestQuartetDistance(t *testing.T) {
+	trees := []string{
+		"((A,B),(C,D),(E,(F,G)));",
+		"((A,C),(B,D),(E,F,G));",
+		"(A,B,C,D,E,F,G);",
+		"(((A,B,C),D),(E,F),G);",
+	}
+	for i := range trees {
+		for j := range trees {
+			t1, err := newick.NewParser(strings.NewReader(trees[i])).Parse()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			t2, err := newick.NewParser(strings.NewReader(trees[j])).Parse()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			stats, err := tree.QuartetDistance(t1, t2)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			expected := bruteForceQuartets(t1, t2)
+			if stats != expected {
+				t.Error(fmt.Errorf("Quartet comparison of %s and %s should be %v and is %v", trees[i], trees[j], expected, stats))
+			}
+		}
+	}
+
+	// Random trees
+	for i := 0; i < 10; i++ {
+		t1, err := tree.RandomYuleBinaryTree(15, i%2 == 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		t2, err := tree.RandomYuleBinaryTree(15, false)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		// Some multifurcations
+		t2.CollapseShortBranches(0.05, false, false)
+		stats, err := tree.QuartetDistance(t1, t2)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := bruteForceQuartets(t1, t2)
+		if stats != expected {
+			t.Error(fmt.Errorf("Quartet comparison of %s and %s should be %v and is %v", t1.Newick(), t2.Newick(), expected, stats))
+		}
+		if stats.Total() != 1365 {
+			t.Error(fmt.Errorf("Total number of quartets should be 1365 and is %d", stats.Total()))
+		}
+	}
+
+	t1, _ := newick.NewParser(strings.NewReader("((A,B),(C,D),E);")).Parse()
+	t2, _ := newick.NewParser(strings.NewReader("((A,B),(C,F),E);")).Parse()
+	if _, err := tree.QuartetDistance(t1, t2); err == nil {
+		t.Error("Quartet comparison of trees with different tips should give an error")
+	}
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Applies all the rearrangements of r to a random tree, checks
+// that Undo gives back the initial tree, and returns the number
+// of different resulting topologies
+func rearrangedTopologies(t *testing.T, r tree.Rearranger, ntips int) (ndiff int) {
+	var tr *tree.Tree
+	var err error
+	var common int
+
+	if tr, err = tree.RandomYuleBinaryTree(ntips, false); err != nil {
+		t.Error(err)
+		return
+	}
+	tr.ReinitIndexes()
+	initial := tr.Newick()
+	trees := make([]*tree.Tree, 0)
+
+	r.Rearrange(tr, func(re tree.Rearrangement) bool {
+		if err = re.Apply(); err != nil {
+			t.Error(err)
+			return false
+		}
+		if err = tr.CheckTreePostOrder(); err != nil {
+			t.Error(err)
+			return false
+		}
+		c := tr.Clone()
+		c.ReinitIndexes()
+		if len(c.Tips()) != ntips {
+			t.Error(fmt.Errorf("Tree after rearrangement does not have %d tips: %s", ntips, c.Newick()))
+			return false
+		}
+		found := false
+		for _, t2 := range trees {
+			if _, common, err = t2.CommonEdges(c, false); err != nil {
+				t.Error(err)
+				return false
+			}
+			if common == ntips-3 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			trees = append(trees, c)
+		}
+		if err = re.Undo(); err != nil {
+			t.Error(err)
+			return false
+		}
+		if tr.Newick() != initial {
+			t.Error(fmt.Errorf("Tree after undoing rearrangement does not correspond to the initial tree: %s / %s", initial, tr.Newick()))
+			return false
+		}
+		return true
+	})
+	ndiff = len(trees)
+	return
+}
+
+func TestSPR(t *testing.T) {
+	ntips := 10
+	// Number of SPR neighbors of an unrooted binary tree (Allen & Steel, 2001)
+	expected := 2 * (ntips - 3) * (2*ntips - 7)
+	if n := rearrangedTopologies(t, &tree.SPRRearranger{Radius: 0}, ntips); n != expected {
+		t.Error(fmt.Errorf("Number of different SPR trees should be %d and is %d", expected, n))
+	}
+	// SPR with radius 1 are NNIs
+	expected = 2 * (ntips - 3)
+	if n := rearrangedTopologies(t, &tree.SPRRearranger{Radius: 1}, ntips); n != expected {
+		t.Error(fmt.Errorf("Number of different SPR trees with radius 1 should be %d and is %d", expected, n))
+	}
+}
+
+func TestTBR(t *testing.T) {
+	ntips := 10
+	spr := 2 * (ntips - 3) * (2*ntips - 7)
+	if n := rearrangedTopologies(t, &tree.TBRRearranger{Radius: 0}, ntips); n < spr {
+		t.Error(fmt.Errorf("Number of different TBR trees should be >= %d and is %d", spr, n))
+	}
+	expected := 2 * (ntips - 3)
+	if n := rearrangedTopologies(t, &tree.TBRRearranger{Radius: 1}, ntips); n < expected {
+		t.Error(fmt.Errorf("Number of different TBR trees with radius 1 should be >= %d and is %d", expected, n))
+	}
+}
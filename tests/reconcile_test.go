@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var reconcilespecies string = "((HUMAN,MOUSE),CHICK);"
+var reconcilegenes = map[string]string{"h1": "HUMAN", "h2": "HUMAN", "m1": "MOUSE", "c1": "CHICK"}
+
+func TestReconcile(t *testing.T) {
+	st, err := newick.NewParser(strings.NewReader(reconcilespecies)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gt, err := newick.NewParser(strings.NewReader("((h1,m1)n1,(h2,c1)n2)n0;")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := gt.Reconcile(st, reconcilegenes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Duplications != 1 || rec.Losses != 2 {
+		t.Errorf("There should be 1 duplication and 2 losses, not %d and %d", rec.Duplications, rec.Losses)
+	}
+	expected := map[string]string{"n0": tree.RECONCILE_DUPLICATION, "n1": tree.RECONCILE_SPECIATION, "n2": tree.RECONCILE_SPECIATION}
+	for _, n := range gt.Nodes() {
+		if n.Tip() {
+			continue
+		}
+		a, ok := n.Attribute("event")
+		if !ok || a.String() != expected[n.Name()] {
+			t.Errorf("Node %s should be a %s", n.Name(), expected[n.Name()])
+		}
+	}
+	// Branches in pre-order: root, (HUMAN,MOUSE), HUMAN, MOUSE, CHICK
+	dups, losses := []int{1, 0, 0, 0, 0}, []int{0, 0, 0, 1, 1}
+	for i, b := range rec.Branches {
+		if b.Duplications != dups[i] || b.Losses != losses[i] {
+			t.Errorf("Species branch %d should have %d duplications and %d losses, not %d and %d", i, dups[i], losses[i], b.Duplications, b.Losses)
+		}
+	}
+
+	if _, err = gt.Reconcile(st, map[string]string{"h1": "HUMAN"}); err == nil {
+		t.Errorf("Genes without species should return an error")
+	}
+}
+
+func TestRootDuplications(t *testing.T) {
+	rand.Seed(10)
+	st, err := tree.RandomYuleBinaryTree(8, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gt, err := tree.RandomYuleBinaryTree(30, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	species := make(map[string]string)
+	for _, tip := range gt.Tips() {
+		species[tip.Name()] = st.Tips()[rand.Intn(8)].Name()
+	}
+
+	rooted := gt.Clone()
+	if err = rooted.RootDuplications(st, species); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := rooted.Reconcile(st, species)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No root placed on another branch does better
+	found := false
+	for _, e := range gt.Edges() {
+		var outgroup []string
+		var under func(cur, prev *tree.Node)
+		under = func(cur, prev *tree.Node) {
+			if cur.Tip() {
+				outgroup = append(outgroup, cur.Name())
+			}
+			for _, n := range cur.Neigh() {
+				if n != prev {
+					under(n, cur)
+				}
+			}
+		}
+		under(e.Right(), e.Left())
+		other := gt.Clone()
+		if err = other.RerootOutGroup(false, true, outgroup...); err != nil {
+			t.Fatal(err)
+		}
+		rec2, err := other.Reconcile(st, species)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec2.Duplications < rec.Duplications || (rec2.Duplications == rec.Duplications && rec2.Losses < rec.Losses) {
+			t.Errorf("Root with outgroup %v gives %d duplications and %d losses, better than %d and %d", outgroup, rec2.Duplications, rec2.Losses, rec.Duplications, rec.Losses)
+		}
+		found = found || (rec2.Duplications == rec.Duplications && rec2.Losses == rec.Losses)
+	}
+	if !found {
+		t.Errorf("No root gives %d duplications and %d losses", rec.Duplications, rec.Losses)
+	}
+}
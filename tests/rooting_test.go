@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Ultrametric tree: all tips at distance 2 from the root
+var ultrametrictree string = "((A:1,B:1):1,(C:1.5,(D:0.5,E:0.5):1):0.5);"
+
+// Unrooted tree far from a clock
+var nonclocktree string = "((A:1,B:3):0.5,(C:0.2,(D:2,E:0.7):0.4):1.1,(F:0.9,G:0.1):0.3);"
+
+// Distances from the node n to all the nodes of its side of the
+// branch going to prev (all the nodes if prev is nil)
+func sideDistances(n, prev *tree.Node, d float64, dists map[*tree.Node]float64) {
+	dists[n] = d
+	for i, c := range n.Neigh() {
+		if c != prev {
+			sideDistances(c, n, d+n.Edges()[i].Length(), dists)
+		}
+	}
+}
+
+// Brute force MAD score of the root placed on the branch e, at distance x
+// from its right node: root mean square of the ancestor deviations
+// of all pairs of tips, whose LCA is searched on the path between them
+func bruteForceMAD(e *tree.Edge, x float64) float64 {
+	right, left := make(map[*tree.Node]float64), make(map[*tree.Node]float64)
+	sideDistances(e.Right(), e.Left(), x, right)
+	sideDistances(e.Left(), e.Right(), e.Length()-x, left)
+	// Distance from the root, and from each tip to all nodes
+	root := make(map[*tree.Node]float64)
+	tips := make([]*tree.Node, 0)
+	for _, side := range []map[*tree.Node]float64{right, left} {
+		for n, d := range side {
+			root[n] = d
+			if n.Tip() {
+				tips = append(tips, n)
+			}
+		}
+	}
+	tipdists := make(map[*tree.Node]map[*tree.Node]float64)
+	for _, b := range tips {
+		tipdists[b] = make(map[*tree.Node]float64)
+		sideDistances(b, nil, 0, tipdists[b])
+	}
+	sum, npairs := 0.0, 0
+	for i, b := range tips {
+		for _, c := range tips[i+1:] {
+			d := tipdists[b][c]
+			if d <= 0 {
+				continue
+			}
+			// LCA: the root if b and c are on both sides of e, otherwise
+			// the node of the path between b and c closest to the root
+			_, bright := right[b]
+			_, cright := right[c]
+			lca := 0.0
+			if bright == cright {
+				lca = math.Inf(1)
+				for n, db := range tipdists[b] {
+					if _, nright := right[n]; nright == bright && math.Abs(db+tipdists[c][n]-d) < 1e-9 {
+						lca = math.Min(lca, root[n])
+					}
+				}
+			}
+			dev := 2*(root[b]-lca)/d - 1
+			sum += dev * dev
+			npairs++
+		}
+	}
+	return math.Sqrt(sum / float64(npairs))
+}
+
+func checkUltrametricRoot(t *testing.T, method string, tr *tree.Tree, scores []tree.RootScore) {
+	if len(scores) != 7 {
+		t.Errorf("%s: there should be 7 branch scores, not %d", method, len(scores))
+	}
+	for name, d := range rootToTipDistances(tr) {
+		if math.Abs(d-2) > 1e-9 {
+			t.Errorf("%s: distance from the root to tip %s should be 2 and is %f", method, name, d)
+		}
+	}
+}
+
+func TestRerootMAD(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader(ultrametrictree)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores, ambiguity, err := tr.RerootMAD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkUltrametricRoot(t, "MAD", tr, scores)
+	if ambiguity > 1e-9 {
+		t.Errorf("MAD: root ambiguity index should be 0 and is %f", ambiguity)
+	}
+
+	// Clock-like tree: the root is not at the midpoint
+	tr, err = newick.NewParser(strings.NewReader(clocktree)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores, ambiguity, err = tr.RerootMAD(); err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 7 || ambiguity < 0 || ambiguity > 1 {
+		t.Errorf("MAD: there should be 7 scores (%d) and the ambiguity index should be in [0,1] (%f)", len(scores), ambiguity)
+	}
+	if !tr.Rooted() || len(tr.Tips()) != 5 {
+		t.Errorf("MAD: rerooted tree should be rooted and have 5 tips")
+	}
+
+	// Non clock-like tree: scores are compared to brute force scores
+	// at several positions on each branch
+	tr, err = newick.NewParser(strings.NewReader(nonclocktree)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := tr.Edges()
+	if scores, _, err = tr.Clone().RerootMAD(); err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != len(edges) {
+		t.Fatalf("MAD: there should be %d scores and there are %d", len(edges), len(scores))
+	}
+	best := math.Inf(1)
+	for _, s := range scores {
+		e := edges[s.Id]
+		if s.Length != e.Length() || s.Position < 0 || s.Position > e.Length() {
+			t.Errorf("MAD: branch %d should have length %f and a root position in [0,%f]: %f, %f", s.Id, e.Length(), e.Length(), s.Length, s.Position)
+			continue
+		}
+		if bf := bruteForceMAD(e, s.Position); math.Abs(bf-s.Score) > 1e-9 {
+			t.Errorf("MAD: score of branch %d at position %f should be %f and is %f", s.Id, s.Position, bf, s.Score)
+		}
+		for k := 0; k <= 10; k++ {
+			x := e.Length() * float64(k) / 10
+			if bf := bruteForceMAD(e, x); bf < s.Score-1e-9 {
+				t.Errorf("MAD: score of branch %d at position %f (%f) is better than the best score %f", s.Id, x, bf, s.Score)
+			}
+		}
+		best = math.Min(best, s.Score)
+	}
+	if _, _, err = tr.RerootMAD(); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range tr.Root().Edges() {
+		if bf := bruteForceMAD(e, e.Length()); math.Abs(bf-best) > 1e-9 {
+			t.Errorf("MAD: score of the rerooted tree should be %f and is %f", best, bf)
+		}
+	}
+}
+
+func TestRerootMinVar(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader(ultrametrictree)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores, err := tr.RerootMinVar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkUltrametricRoot(t, "MinVar", tr, scores)
+	min := math.Inf(1)
+	for _, s := range scores {
+		min = math.Min(min, s.Score)
+	}
+	if min > 1e-9 {
+		t.Errorf("MinVar: best variance should be 0 and is %f", min)
+	}
+
+	if tr, err = newick.NewParser(strings.NewReader("(A,B,C);")).Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tr.RerootMinVar(); err == nil {
+		t.Errorf("MinVar: trees without branch lengths should return an error")
+	}
+}
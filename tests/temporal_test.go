@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Tree with a strict clock of rate 0.1, and a root at date 2000
+var clocktree string = "((A:0.5,B:0.3):0.2,(C:0.4,(D:0.1,E:0.3):0.1):0.3);"
+var clockdates = map[string]float64{"A": 2007, "B": 2005, "C": 2007, "D": 2005, "E": 2007}
+
+func TestRerootTemporalClock(t *testing.T) {
+	for _, criterion := range []int{tree.TEMPORAL_CORRELATION, tree.TEMPORAL_R2, tree.TEMPORAL_RMS} {
+		tr, err := newick.NewParser(strings.NewReader(clocktree)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.UnRoot()
+		res, err := tr.RerootTemporal(clockdates, criterion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(res.Rate-0.1) > 1e-9 || math.Abs(res.TMRCA-2000) > 1e-6 || math.Abs(res.R2-1) > 1e-9 {
+			t.Errorf("Criterion %d: rate, tmrca and R2 should be 0.1, 2000 and 1, but are %f, %f and %f", criterion, res.Rate, res.TMRCA, res.R2)
+		}
+		for name, r := range res.Residuals {
+			if math.Abs(r) > 1e-9 {
+				t.Errorf("Criterion %d: residual of tip %s should be 0 and is %f", criterion, name, r)
+			}
+		}
+		for _, e := range tr.Root().Edges() {
+			if math.Abs(e.Length()-0.2) > 1e-9 && math.Abs(e.Length()-0.3) > 1e-9 {
+				t.Errorf("Criterion %d: root branch lengths should be 0.2 and 0.3, not %f", criterion, e.Length())
+			}
+		}
+	}
+}
+
+func TestRerootTemporalOptimal(t *testing.T) {
+	rand.Seed(10)
+	tr, err := tree.RandomYuleBinaryTree(20, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates := make(map[string]float64)
+	for _, tip := range tr.Tips() {
+		dates[tip.Name()] = 2000 + rand.Float64()*10
+	}
+
+	score := func(res *tree.TemporalSignal, criterion int) float64 {
+		switch criterion {
+		case tree.TEMPORAL_R2:
+			return res.R2
+		case tree.TEMPORAL_RMS:
+			return -res.RMS
+		}
+		return res.Correlation
+	}
+
+	for _, criterion := range []int{tree.TEMPORAL_CORRELATION, tree.TEMPORAL_R2, tree.TEMPORAL_RMS} {
+		rerooted := tr.Clone()
+		res, err := rerooted.RerootTemporal(dates, criterion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rerooted.Tips()) != 20 || !rerooted.Rooted() {
+			t.Fatalf("Rerooted tree should be rooted and have 20 tips")
+		}
+		// No root placed on a node does better
+		for i, n := range tr.Nodes() {
+			if n.Tip() {
+				continue
+			}
+			other := tr.Clone()
+			if err = other.Reroot(other.Nodes()[i]); err != nil {
+				t.Fatal(err)
+			}
+			res2, err := other.RootToTipRegression(dates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if score(res2, criterion) > score(res, criterion)+1e-9 {
+				t.Errorf("Criterion %d: root on node %d gives a better score (%f) than the temporal root (%f)", criterion, i, score(res2, criterion), score(res, criterion))
+			}
+		}
+	}
+}
+
+func TestRerootTemporalErrors(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader(clocktree)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tr.RerootTemporal(map[string]float64{"A": 2000, "B": 2001}, tree.TEMPORAL_CORRELATION); err == nil {
+		t.Errorf("Tips without date should return an error")
+	}
+	same := map[string]float64{"A": 2000, "B": 2000, "C": 2000, "D": 2000, "E": 2000}
+	if _, err = tr.RootToTipRegression(same); err == nil {
+		t.Errorf("Identical dates should return an error")
+	}
+}
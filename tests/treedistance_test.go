@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var alltreedistances = []int{tree.DISTANCE_WEIGHTED_RF, tree.DISTANCE_BRANCH_SCORE, tree.DISTANCE_PATH,
+	tree.DISTANCE_PATRISTIC, tree.DISTANCE_MATCHING_SPLIT, tree.DISTANCE_GENERALIZED_RF}
+
+func TestTreeDistances(t *testing.T) {
+	for _, c := range []struct {
+		t1, t2   string
+		expected []float64
+	}{
+		// Different topologies
+		{"((A:1,B:1):1,(C:1,D:1):1,E:1);", "((A:1,C:1):1,(B:1,D:1):1,E:1);", []float64{4, 2, 4, 4, 4, 3.803910}},
+		// Same topology, different lengths
+		{"((A:1,B:1):1,(C:1,D:1):1,E:1);", "((A:1,B:2):1,(C:1,D:1):1,E:1);", []float64{1, 1, 0, 2, 0, 0}},
+		// Same tree, rooted
+		{"((A:1,B:1):1,(C:1,D:1):1,E:1);", "(((A:1,B:1):1,E:1):0.5,(C:1,D:1):0.5);", []float64{0, 0, 0, 0, 0, 0}},
+	} {
+		t1, err := newick.NewParser(strings.NewReader(c.t1)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t2, err := newick.NewParser(strings.NewReader(c.t2)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := tree.TreeDistances(t1, t2, alltreedistances)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range d {
+			if math.Abs(d[i]-c.expected[i]) > 1e-6 {
+				t.Errorf("Distance %d between %s and %s should be %f and is %f", alltreedistances[i], c.t1, c.t2, c.expected[i], d[i])
+			}
+		}
+	}
+}
+
+func TestTreeDistancesErrors(t *testing.T) {
+	t1, err := newick.NewParser(strings.NewReader("((A,B),(C,D),E);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t2, err := newick.NewParser(strings.NewReader("((A,B),(C,F),E);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tree.TreeDistances(t1, t2, []int{tree.DISTANCE_MATCHING_SPLIT}); err == nil {
+		t.Errorf("Trees with different tips should return an error")
+	}
+	if _, err = tree.TreeDistances(t1, t1, []int{tree.DISTANCE_BRANCH_SCORE}); err == nil {
+		t.Errorf("Trees without branch lengths should return an error")
+	}
+	if d, err := tree.TreeDistances(t1, t1, []int{tree.DISTANCE_PATH, tree.DISTANCE_MATCHING_SPLIT}); err != nil || d[0] != 0 || d[1] != 0 {
+		t.Errorf("Topological distances of identical trees without branch lengths should be 0")
+	}
+}
+
+func TestCompareDistances(t *testing.T) {
+	ref, err := newick.NewParser(strings.NewReader("((A:1,B:1):1,(C:1,D:1):1,E:1);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp := []string{"((A:1,C:1):1,(B:1,D:1):1,E:1);", "((A:1,B:2):1,(C:1,D:1):1,E:1);", "((A:1,B:1):1,(C:1,D:1):1,E:1);"}
+	expected := []float64{4, 1, 0}
+	treechan := make(chan tree.Trees)
+	go func() {
+		for i, nw := range comp {
+			tr, err := newick.NewParser(strings.NewReader(nw)).Parse()
+			treechan <- tree.Trees{Tree: tr, Id: i, Err: err}
+		}
+		close(treechan)
+	}()
+	stats, err := tree.CompareDistances(ref, treechan, []int{tree.DISTANCE_WEIGHTED_RF}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for st := range stats {
+		if st.Err != nil {
+			t.Fatal(st.Err)
+		}
+		if math.Abs(st.Distances[0]-expected[st.Id]) > 1e-9 {
+			t.Errorf("Weighted RF of tree %d should be %f and is %f", st.Id, expected[st.Id], st.Distances[0])
+		}
+		n++
+	}
+	if n != len(comp) {
+		t.Errorf("There should be %d compared trees, not %d", len(comp), n)
+	}
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/utils"
+)
+
+func TestTreeSelection(t *testing.T) {
+	var input string
+	for i := 0; i < 20; i++ {
+		input += fmt.Sprintf("(T%d,A,(B,C));\n", i)
+	}
+
+	tests := []struct {
+		burnin   float64
+		thin     int
+		trange   string
+		expected []int
+	}{
+		{0, 1, "", []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}},
+		{15, 1, "", []int{15, 16, 17, 18, 19}},
+		{0.75, 1, "", []int{15, 16, 17, 18, 19}},
+		{10, 3, "", []int{10, 13, 16, 19}},
+		{0.5, 3, ":17", []int{10, 13, 16}},
+		{0, 1, "3:6", []int{3, 4, 5}},
+		{4, 2, "1:", []int{4, 6, 8, 10, 12, 14, 16, 18}},
+	}
+
+	for _, test := range tests {
+		selection, err := utils.ParseTreeSelection(test.burnin, test.thin, test.trange)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ids := make([]int, 0)
+		for tr := range utils.ReadMultiTreesSelection(bufio.NewReader(strings.NewReader(input)), utils.FORMAT_NEWICK, selection) {
+			if tr.Err != nil {
+				t.Error(tr.Err)
+				return
+			}
+			if tr.Tree.Tips()[0].Name() != fmt.Sprintf("T%d", tr.Id) {
+				t.Error(fmt.Sprintf("Tree %d does not correspond to the tree at index %d in the input", tr.Id, tr.Id))
+			}
+			ids = append(ids, tr.Id)
+		}
+		if fmt.Sprintf("%v", ids) != fmt.Sprintf("%v", test.expected) {
+			t.Error(fmt.Sprintf("Selected trees with burnin=%f, thin=%d, range=%s should be %v and are %v", test.burnin, test.thin, test.trange, test.expected, ids))
+		}
+	}
+
+	for _, r := range []string{"a:3", "5:2", "1:2:3"} {
+		if _, err := utils.ParseTreeSelection(0, 1, r); err == nil {
+			t.Error(fmt.Sprintf("Malformed range %s should give an error", r))
+		}
+	}
+}
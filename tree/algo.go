@@ -236,6 +236,7 @@ func (t *Tree) AddBipartition(n *Node, edges []*Edge, length, support float64) (
 		etmp.SetLength(len)
 		etmp.SetSupport(boot)
 		etmp.SetPValue(pv)
+		etmp.attributes = e.attributes
 	}
 
 	var e *Edge
@@ -464,6 +465,8 @@ func (t *Tree) RerootOutGroup(removeoutgroup, strict bool, tips ...string) error
 			ne.SetSupport(support)
 			ne2.SetSupport(support)
 		}
+		ne.attributes = cloneAttributes(rootedge.attributes)
+		ne2.attributes = cloneAttributes(rootedge.attributes)
 	}
 	if err = t.reroot_nocheck(root); err != nil {
 		return err
@@ -552,6 +555,8 @@ func (t *Tree) RerootMidPoint() error {
 	e2.SetLength(cut)
 	e.SetSupport(b)
 	e2.SetSupport(b)
+	e.attributes = cloneAttributes(potentialedges[i-1].attributes)
+	e2.attributes = cloneAttributes(potentialedges[i-1].attributes)
 
 	t.Reroot(newroot)
 	t.ReinitInternalIndexes()
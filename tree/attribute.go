@@ -0,0 +1,542 @@
+package tree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Types of value that can be stored in an Attribute
+const (
+	ATTRIBUTE_STRING = iota
+	ATTRIBUTE_FLOAT
+	ATTRIBUTE_INT
+	ATTRIBUTE_BOOL
+	ATTRIBUTE_LIST
+)
+
+// Attribute is a typed value associated to a key on a Node
+// or on an Edge (see Node.SetAttribute and Edge.SetAttribute).
+//
+// It may be a string, a float, an int, a bool or a list of
+// attributes.
+type Attribute struct {
+	kind int         // Type of the value: ATTRIBUTE_STRING, ATTRIBUTE_FLOAT, etc.
+	s    string      // String value
+	q    rune        // Quote character of the string value when parsed, 0 if not quoted
+	f    float64     // Float value
+	i    int         // Int value
+	b    bool        // Bool value
+	l    []Attribute // List value
+}
+
+// Attributes is an ordered set of key/value attributes.
+//
+// The order of the keys is the order in which they have
+// been first set, so that attributes are written back in
+// the order they were parsed.
+type Attributes struct {
+	keys   []string             // keys in insertion order
+	values map[string]Attribute // values associated to keys
+}
+
+// NewStringAttribute returns an attribute holding a string
+func NewStringAttribute(s string) Attribute {
+	return Attribute{kind: ATTRIBUTE_STRING, s: s}
+}
+
+// NewFloatAttribute returns an attribute holding a float
+func NewFloatAttribute(f float64) Attribute {
+	return Attribute{kind: ATTRIBUTE_FLOAT, f: f}
+}
+
+// NewIntAttribute returns an attribute holding an int
+func NewIntAttribute(i int) Attribute {
+	return Attribute{kind: ATTRIBUTE_INT, i: i}
+}
+
+// NewBoolAttribute returns an attribute holding a bool
+func NewBoolAttribute(b bool) Attribute {
+	return Attribute{kind: ATTRIBUTE_BOOL, b: b}
+}
+
+// NewListAttribute returns an attribute holding a list of attributes
+func NewListAttribute(values ...Attribute) Attribute {
+	l := make([]Attribute, len(values))
+	copy(l, values)
+	return Attribute{kind: ATTRIBUTE_LIST, l: l}
+}
+
+// NewFloatListAttribute returns an attribute holding a list of floats
+func NewFloatListAttribute(values ...float64) Attribute {
+	l := make([]Attribute, len(values))
+	for i, v := range values {
+		l[i] = NewFloatAttribute(v)
+	}
+	return Attribute{kind: ATTRIBUTE_LIST, l: l}
+}
+
+// Type returns the type of the value held by the attribute
+// (ATTRIBUTE_STRING, ATTRIBUTE_FLOAT, ATTRIBUTE_INT,
+// ATTRIBUTE_BOOL or ATTRIBUTE_LIST)
+func (a Attribute) Type() int {
+	return a.kind
+}
+
+// Float returns the value of the attribute as a float.
+//
+// Int attributes are converted to float. Returns an error
+// for other types.
+func (a Attribute) Float() (float64, error) {
+	switch a.kind {
+	case ATTRIBUTE_FLOAT:
+		return a.f, nil
+	case ATTRIBUTE_INT:
+		return float64(a.i), nil
+	default:
+		return math.NaN(), fmt.Errorf("Attribute %s is not a numeric value", a.String())
+	}
+}
+
+// Int returns the value of the attribute as an int.
+//
+// Returns an error if the attribute is not an int.
+func (a Attribute) Int() (int, error) {
+	if a.kind != ATTRIBUTE_INT {
+		return 0, fmt.Errorf("Attribute %s is not an integer value", a.String())
+	}
+	return a.i, nil
+}
+
+// Bool returns the value of the attribute as a bool.
+//
+// Returns an error if the attribute is not a bool.
+func (a Attribute) Bool() (bool, error) {
+	if a.kind != ATTRIBUTE_BOOL {
+		return false, fmt.Errorf("Attribute %s is not a boolean value", a.String())
+	}
+	return a.b, nil
+}
+
+// List returns the values of a list attribute.
+//
+// Returns an error if the attribute is not a list.
+func (a Attribute) List() ([]Attribute, error) {
+	if a.kind != ATTRIBUTE_LIST {
+		return nil, fmt.Errorf("Attribute %s is not a list", a.String())
+	}
+	return a.l, nil
+}
+
+// FloatList returns the values of a list attribute as floats.
+//
+// Returns an error if the attribute is not a list or if one
+// of its values is not numeric.
+func (a Attribute) FloatList() (values []float64, err error) {
+	var l []Attribute
+	if l, err = a.List(); err != nil {
+		return
+	}
+	values = make([]float64, len(l))
+	for i, v := range l {
+		if values[i], err = v.Float(); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// String returns the value of the attribute as a string,
+// whatever its type. Lists are written as {v1,v2,...}.
+//
+// String values are returned as is (not quoted).
+func (a Attribute) String() string {
+	switch a.kind {
+	case ATTRIBUTE_FLOAT:
+		return formatFloatAttribute(a.f)
+	case ATTRIBUTE_INT:
+		return strconv.Itoa(a.i)
+	case ATTRIBUTE_BOOL:
+		return strconv.FormatBool(a.b)
+	case ATTRIBUTE_LIST:
+		var buf bytes.Buffer
+		buf.WriteRune('{')
+		for i, v := range a.l {
+			if i > 0 {
+				buf.WriteRune(',')
+			}
+			buf.WriteString(v.format())
+		}
+		buf.WriteRune('}')
+		return buf.String()
+	default:
+		return a.s
+	}
+}
+
+// Equal returns true if both attributes have the same type
+// and the same value
+func (a Attribute) Equal(a2 Attribute) bool {
+	if a.kind != a2.kind {
+		return false
+	}
+	switch a.kind {
+	case ATTRIBUTE_FLOAT:
+		return a.f == a2.f
+	case ATTRIBUTE_INT:
+		return a.i == a2.i
+	case ATTRIBUTE_BOOL:
+		return a.b == a2.b
+	case ATTRIBUTE_LIST:
+		if len(a.l) != len(a2.l) {
+			return false
+		}
+		for i, v := range a.l {
+			if !v.Equal(a2.l[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.s == a2.s
+	}
+}
+
+// Copy returns a deep copy of the attribute
+func (a Attribute) Copy() Attribute {
+	if a.kind == ATTRIBUTE_LIST {
+		return NewListAttribute(a.l...)
+	}
+	return a
+}
+
+// format returns the string representation of the attribute such that
+// ParseAttribute(a.format()) gives back the same attribute:
+// strings are quoted if needed, or if they were quoted when parsed.
+func (a Attribute) format() string {
+	if a.kind != ATTRIBUTE_STRING {
+		return a.String()
+	}
+	if a.q != 0 && !strings.ContainsRune(a.s, a.q) {
+		return string(a.q) + a.s + string(a.q)
+	}
+	if ParseAttribute(a.s).kind == ATTRIBUTE_STRING && !strings.ContainsAny(a.s, " \t\n\r,=[](){}:;\"'&") && a.s != "" {
+		return a.s
+	}
+	if strings.ContainsRune(a.s, '"') {
+		return "'" + a.s + "'"
+	}
+	return "\"" + a.s + "\""
+}
+
+// Floats are always written with a decimal point (or an exponent)
+// so that they are not parsed back as ints.
+func formatFloatAttribute(f float64) string {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.ContainsAny(s, ".eEIN") {
+		s += ".0"
+	}
+	return s
+}
+
+// ParseAttribute infers the type of the given string value and returns
+// the corresponding attribute:
+//	* {v1,v2,...}: List (each element is parsed with ParseAttribute)
+//	* "value" or 'value': String (without quotes)
+//	* Integer value: Int
+//	* Float value: Float
+//	* true/false (case insensitive): Bool
+//	* Otherwise: String
+func ParseAttribute(value string) Attribute {
+	v := strings.TrimSpace(value)
+	if len(v) >= 2 && v[0] == '{' && v[len(v)-1] == '}' {
+		items, err := splitAttributeList(v[1:len(v)-1], ',')
+		if err == nil {
+			l := make([]Attribute, 0, len(items))
+			for _, it := range items {
+				l = append(l, ParseAttribute(it))
+			}
+			return Attribute{kind: ATTRIBUTE_LIST, l: l}
+		}
+	}
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return Attribute{kind: ATTRIBUTE_STRING, s: v[1 : len(v)-1], q: rune(v[0])}
+	}
+	if i, err := strconv.Atoi(v); err == nil {
+		return NewIntAttribute(i)
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return NewFloatAttribute(f)
+	}
+	switch strings.ToLower(v) {
+	case "true":
+		return NewBoolAttribute(true)
+	case "false":
+		return NewBoolAttribute(false)
+	}
+	return NewStringAttribute(v)
+}
+
+// Splits the given string according to the separator, only at top level:
+// separators located inside {} or inside quotes are ignored.
+//
+// Returns an error if braces or quotes are not balanced.
+func splitAttributeList(s string, sep rune) (items []string, err error) {
+	var buf bytes.Buffer
+	level := 0
+	var quote rune = 0
+	items = make([]string, 0)
+	if strings.TrimSpace(s) == "" {
+		return
+	}
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			level++
+		case c == '}':
+			level--
+			if level < 0 {
+				return nil, errors.New("Mismatched } in attribute list")
+			}
+		case c == sep && level == 0:
+			items = append(items, buf.String())
+			buf.Reset()
+			continue
+		}
+		buf.WriteRune(c)
+	}
+	if level != 0 || quote != 0 {
+		return nil, errors.New("Mismatched { or quote in attribute list")
+	}
+	items = append(items, buf.String())
+	return
+}
+
+// NewAttributes returns an empty set of attributes
+func NewAttributes() *Attributes {
+	return &Attributes{
+		keys:   make([]string, 0),
+		values: make(map[string]Attribute),
+	}
+}
+
+// ParseAttributes parses a comment of the form &key1=value1,key2=value2,...
+// (without the surrounding brackets) and returns the corresponding set of
+// attributes. Types of values are inferred with ParseAttribute.
+//
+// Returns an error if the comment does not start with & or if one
+// of the elements is not of the form key=value.
+func ParseAttributes(comment string) (attrs *Attributes, err error) {
+	var items []string
+	c := strings.TrimSpace(comment)
+	if !strings.HasPrefix(c, "&") || strings.HasPrefix(c, "&&") {
+		err = fmt.Errorf("Attribute comment should start with a single &: %s", comment)
+		return
+	}
+	if items, err = splitAttributeList(c[1:], ','); err != nil {
+		return
+	}
+	attrs = NewAttributes()
+	for _, it := range items {
+		kv := strings.SplitN(it, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || key == "" {
+			return nil, fmt.Errorf("Attribute should be of the form key=value: %s", it)
+		}
+		attrs.Set(key, ParseAttribute(kv[1]))
+	}
+	return
+}
+
+// ParseNHXAttributes parses a comment in the NHX format (New Hampshire
+// eXtended): &&NHX:key1=value1:key2=value2:... (without the surrounding
+// brackets) and returns the corresponding set of attributes.
+//
+// Types of values are inferred with ParseAttribute, except for the
+// duplication tag (D), whose values Y/T and N/F are parsed as booleans.
+//
+// Returns an error if the comment does not start with &&NHX or if one
+// of the elements is not of the form key=value.
+func ParseNHXAttributes(comment string) (attrs *Attributes, err error) {
+	var items []string
+	c := strings.TrimSpace(comment)
+	if !strings.HasPrefix(c, "&&NHX") {
+		err = fmt.Errorf("NHX comment should start with &&NHX: %s", comment)
+		return
+	}
+	if items, err = splitAttributeList(c[5:], ':'); err != nil {
+		return
+	}
+	attrs = NewAttributes()
+	for _, it := range items {
+		if strings.TrimSpace(it) == "" {
+			continue
+		}
+		kv := strings.SplitN(it, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || key == "" {
+			return nil, fmt.Errorf("NHX tag should be of the form key=value: %s", it)
+		}
+		if key == "D" {
+			switch strings.ToUpper(strings.TrimSpace(kv[1])) {
+			case "Y", "T":
+				attrs.Set(key, NewBoolAttribute(true))
+				continue
+			case "N", "F":
+				attrs.Set(key, NewBoolAttribute(false))
+				continue
+			}
+		}
+		attrs.Set(key, ParseAttribute(kv[1]))
+	}
+	return
+}
+
+// Set associates the value to the key. If the key already exists,
+// the value is replaced and the key keeps its position.
+func (as *Attributes) Set(key string, value Attribute) {
+	if _, ok := as.values[key]; !ok {
+		as.keys = append(as.keys, key)
+	}
+	as.values[key] = value
+}
+
+// Get returns the value associated to the key, and false if the
+// key does not exist
+func (as *Attributes) Get(key string) (a Attribute, ok bool) {
+	if as == nil {
+		return
+	}
+	a, ok = as.values[key]
+	return
+}
+
+// Delete removes the given key and its value. Does nothing if the key
+// does not exist.
+func (as *Attributes) Delete(key string) {
+	if as == nil {
+		return
+	}
+	if _, ok := as.values[key]; !ok {
+		return
+	}
+	delete(as.values, key)
+	for i, k := range as.keys {
+		if k == key {
+			as.keys = append(as.keys[:i], as.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the list of keys, in insertion order
+func (as *Attributes) Keys() []string {
+	if as == nil {
+		return nil
+	}
+	return as.keys
+}
+
+// Len returns the number of attributes
+func (as *Attributes) Len() int {
+	if as == nil {
+		return 0
+	}
+	return len(as.keys)
+}
+
+// Clone returns a deep copy of the attributes
+func (as *Attributes) Clone() *Attributes {
+	out := NewAttributes()
+	for _, k := range as.Keys() {
+		out.Set(k, as.values[k].Copy())
+	}
+	return out
+}
+
+// Merge sets all attributes of as2 into as. Already existing
+// keys are replaced.
+func (as *Attributes) Merge(as2 *Attributes) {
+	for _, k := range as2.Keys() {
+		as.Set(k, as2.values[k].Copy())
+	}
+}
+
+// String returns the attributes as a string of the form
+// &key1=value1,key2=value2,... that can be parsed by ParseAttributes.
+func (as *Attributes) String() string {
+	var buf bytes.Buffer
+	buf.WriteRune('&')
+	for i, k := range as.Keys() {
+		if i > 0 {
+			buf.WriteRune(',')
+		}
+		buf.WriteString(k)
+		buf.WriteRune('=')
+		buf.WriteString(as.values[k].format())
+	}
+	return buf.String()
+}
+
+// NHXString returns the attributes as a string of the form
+// &&NHX:key1=value1:key2=value2:... that can be parsed by ParseNHXAttributes.
+//
+// A boolean duplication tag (D) is written Y or N.
+func (as *Attributes) NHXString() string {
+	var buf bytes.Buffer
+	buf.WriteString("&&NHX")
+	for _, k := range as.Keys() {
+		v := as.values[k]
+		buf.WriteRune(':')
+		buf.WriteString(k)
+		buf.WriteRune('=')
+		if k == "D" && v.kind == ATTRIBUTE_BOOL {
+			if v.b {
+				buf.WriteRune('Y')
+			} else {
+				buf.WriteRune('N')
+			}
+		} else {
+			buf.WriteString(v.format())
+		}
+	}
+	return buf.String()
+}
+
+// Returns a deep copy of the given attributes, or nil if
+// there is no attribute.
+func cloneAttributes(as *Attributes) *Attributes {
+	if as.Len() == 0 {
+		return nil
+	}
+	return as.Clone()
+}
+
+// Returns the attributes resulting from the merge of two
+// edges: attributes of e2 take precedence over those of e1.
+// Returns nil if none of the edges has attributes.
+func mergeEdgeAttributes(e1, e2 *Edge) *Attributes {
+	return mergeAttributes(e1.attributes, e2.attributes)
+}
+
+// Returns the merge of the two given sets of attributes:
+// attributes of as2 take precedence over those of as1.
+// Returns nil if both are empty.
+func mergeAttributes(as1, as2 *Attributes) *Attributes {
+	if as1.Len() == 0 && as2.Len() == 0 {
+		return nil
+	}
+	out := NewAttributes()
+	out.Merge(as1)
+	out.Merge(as2)
+	return out
+}
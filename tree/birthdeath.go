@@ -0,0 +1,381 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+// Maximum number of simulations of a birth-death process
+// before giving up (e.g. if the process always goes extinct)
+const maxBirthDeathAttempts = 10000
+
+// Number of processes simulated to draw one tree with a
+// fixed number of tips (general sampling approach)
+const bdGSASimulations = 100
+
+// Status of the tips of a complete birth-death tree
+const (
+	bdExtant    = iota // Lineage alive and sampled at present
+	bdUnsampled        // Lineage alive at present but not sampled
+	bdExtinct          // Lineage that died before present
+	bdSampled          // Lineage sampled (and removed) before present
+)
+
+// Parameters of a birth-death process with piecewise constant rates.
+//
+// Rates are given for each time interval, from the root to the present: interval 0
+// starts at the root, and interval i (i > 0) starts at Shifts[i-1] (time since the root).
+// A list of rates with a single value gives the same rate for all intervals.
+type BirthDeathModel struct {
+	Birth    []float64 // Birth (speciation/transmission) rates
+	Death    []float64 // Death (extinction/recovery) rates
+	Sampling []float64 // Serial sampling rates: sampled lineages are removed, nil for no serial sampling
+	Shifts   []float64 // Increasing times (since the root) at which rates change
+	Rho      float64   // Probability of sampling each lineage alive at present
+}
+
+// Node of a simulated birth-death tree
+type bdNode struct {
+	children []*bdNode
+	time     float64 // Time of the event since the root
+	status   int     // Status of tips
+}
+
+// Simulates a time-calibrated tree under a birth-death process with piecewise constant
+// birth, death and serial sampling rates, starting with two lineages at the root.
+//
+//   - If nbtips > 0, the present is drawn uniformly among the times at which nbtips
+//     lineages would be sampled, following the general sampling approach of
+//     Hartmann, Wong & Stadler (2010) (see simulateTips). Exactly nbtips of the
+//     lineages alive at present are then sampled;
+//   - Otherwise, the process is simulated during the given age (time from the root
+//     to the present), and each lineage alive at present is sampled with probability Rho.
+//
+// Simulations are repeated until the tree has at least 2 sampled tips.
+// If complete is false, the returned tree is the reconstructed tree: lineages that are not
+// sampled are removed. Otherwise, all lineages are kept, and each tip has a "status" attribute
+// among "extant" (sampled at present), "unsampled" (alive at present, not sampled), "extinct",
+// and "sampled" (sampled before present). Without serial sampling and with Rho = 1, the
+// reconstructed tree is ultrametric. The tree is rooted, and tips are named Tip<i>.
+func RandomBirthDeathTree(m BirthDeathModel, nbtips int, age float64, complete bool) (t *Tree, err error) {
+	var root *bdNode
+	var ok bool
+
+	if err = m.check(); err != nil {
+		return
+	}
+	if nbtips <= 0 && age <= 0 {
+		err = errors.New("Either a number of tips or an age must be given")
+		return
+	}
+	if nbtips > 0 && nbtips < 2 {
+		err = errors.New("Cannot create a birth-death tree with less than 2 tips")
+		return
+	}
+
+	for attempt := 0; attempt < maxBirthDeathAttempts; attempt++ {
+		if nbtips > 0 {
+			root, ok = m.simulateTips(nbtips)
+		} else {
+			root, ok = m.simulateAge(age)
+		}
+		if !ok {
+			continue
+		}
+		t = NewTree()
+		tipid, nsampled := 0, 0
+		if rootnode, _, kept := bdBuildTree(t, root, complete, &tipid, &nsampled); kept && nsampled >= 2 {
+			t.SetRoot(rootnode)
+			t.ReinitIndexes()
+			return
+		}
+	}
+	t = nil
+	err = fmt.Errorf("Could not simulate a birth-death tree with at least 2 sampled tips after %d attempts", maxBirthDeathAttempts)
+	return
+}
+
+// Checks the consistency of the model parameters
+func (m BirthDeathModel) check() error {
+	nintervals := len(m.Shifts) + 1
+	for _, rates := range [][]float64{m.Birth, m.Death, m.Sampling} {
+		if rates != nil && len(rates) != 1 && len(rates) != nintervals {
+			return fmt.Errorf("Rates must be given for each of the %d time intervals", nintervals)
+		}
+		for _, r := range rates {
+			if r < 0 {
+				return errors.New("Rates must be >= 0")
+			}
+		}
+	}
+	if len(m.Birth) == 0 {
+		return errors.New("Birth rate must be given")
+	}
+	for i := 1; i < len(m.Shifts); i++ {
+		if m.Shifts[i] <= m.Shifts[i-1] {
+			return errors.New("Rate shift times must be increasing")
+		}
+	}
+	if m.Rho <= 0 || m.Rho > 1 {
+		return errors.New("Sampling probability at present must be in ]0,1]")
+	}
+	return nil
+}
+
+// Rate of the given interval, 0 if not given
+func bdRate(rates []float64, interval int) float64 {
+	switch len(rates) {
+	case 0:
+		return 0
+	case 1:
+		return rates[0]
+	}
+	return rates[interval]
+}
+
+// Starts the process with two lineages at the root
+func bdStart() (root *bdNode, alive []*bdNode) {
+	root = &bdNode{}
+	alive = []*bdNode{{time: 0}, {time: 0}}
+	root.children = append(root.children, alive...)
+	return
+}
+
+// Waiting time before the next event in the given interval, with
+// nalive lineages alive, or -1 if no event can happen
+func (m BirthDeathModel) wait(nalive, interval int) float64 {
+	total := float64(nalive) * (bdRate(m.Birth, interval) + bdRate(m.Death, interval) + bdRate(m.Sampling, interval))
+	if total > 0 {
+		return rand.ExpFloat64() / total
+	}
+	return -1.0
+}
+
+// Birth, death or serial sampling event at the given time on a random lineage.
+// Returns the updated list of alive lineages.
+func (m BirthDeathModel) event(alive []*bdNode, time float64, interval int) []*bdNode {
+	birth, death, sampling := bdRate(m.Birth, interval), bdRate(m.Death, interval), bdRate(m.Sampling, interval)
+	idx := rand.Intn(len(alive))
+	lineage := alive[idx]
+	lineage.time = time
+	r := rand.Float64() * (birth + death + sampling)
+	switch {
+	case r < birth:
+		c1, c2 := &bdNode{time: time}, &bdNode{time: time}
+		lineage.children = []*bdNode{c1, c2}
+		alive[idx] = c1
+		alive = append(alive, c2)
+	default:
+		if r < birth+death {
+			lineage.status = bdExtinct
+		} else {
+			lineage.status = bdSampled
+		}
+		alive[idx] = alive[len(alive)-1]
+		alive = alive[:len(alive)-1]
+	}
+	return alive
+}
+
+// Simulates the process forward in time during the given age. Returns false
+// if the process went extinct before the present.
+func (m BirthDeathModel) simulateAge(age float64) (root *bdNode, ok bool) {
+	var time float64
+	var interval int
+	var alive []*bdNode
+
+	root, alive = bdStart()
+	for {
+		if len(alive) == 0 {
+			return
+		}
+		wait := m.wait(len(alive), interval)
+		// Next rate shift or end of the process, whichever comes first
+		if interval < len(m.Shifts) && (wait < 0 || time+wait > m.Shifts[interval]) && m.Shifts[interval] < age {
+			time = m.Shifts[interval]
+			interval++
+			continue
+		}
+		if wait < 0 || time+wait > age {
+			time = age
+			break
+		}
+		time += wait
+		alive = m.event(alive, time, interval)
+	}
+
+	// Sampling at present
+	for _, l := range alive {
+		l.time = time
+		l.status = bdUnsampled
+		if rand.Float64() < m.Rho {
+			l.status = bdExtant
+		}
+	}
+	ok = true
+	return
+}
+
+// Simulates the process conditioned on nbtips sampled tips, with the general
+// sampling approach (Hartmann, Wong & Stadler, 2010, Sampling trees from
+// evolutionary models): the present is a time drawn uniformly among all the
+// times at which nbtips lineages would be sampled.
+//
+// To do so, bdGSASimulations processes are simulated until they have
+// m.gsaMaxLineages(nbtips) lineages or until they go extinct. Each period of
+// time with a constant number N of lineages is given a weight equal to its
+// length times the probability to sample nbtips lineages out of N. One period
+// is drawn among all simulations according to these weights, and the present
+// is drawn uniformly in this period. Exactly nbtips lineages alive at this
+// time are then sampled.
+//
+// Returns false if no period with enough lineages was found.
+func (m BirthDeathModel) simulateTips(nbtips int) (root *bdNode, ok bool) {
+	r := &bdReservoir{nbtips: nbtips, rho: m.Rho}
+	maxalive := m.gsaMaxLineages(nbtips)
+
+	for sim := 0; sim < bdGSASimulations; sim++ {
+		var time float64
+		var interval int
+		simroot, alive := bdStart()
+		for len(alive) > 0 && len(alive) < maxalive {
+			wait := m.wait(len(alive), interval)
+			if interval < len(m.Shifts) && (wait < 0 || time+wait > m.Shifts[interval]) {
+				r.add(simroot, alive, time, m.Shifts[interval]-time)
+				time = m.Shifts[interval]
+				interval++
+				continue
+			}
+			if wait < 0 {
+				// No event can happen anymore
+				break
+			}
+			r.add(simroot, alive, time, wait)
+			time += wait
+			alive = m.event(alive, time, interval)
+		}
+	}
+	if r.root == nil {
+		return
+	}
+
+	// Lineages alive at the chosen time become tips, and
+	// everything that happened after is discarded
+	for _, l := range r.alive {
+		l.children = nil
+		l.time = r.time
+		l.status = bdUnsampled
+	}
+	for _, i := range rand.Perm(len(r.alive))[:nbtips] {
+		r.alive[i].status = bdExtant
+	}
+	return r.root, true
+}
+
+// Number of lineages at which the simulations of the general sampling approach
+// are stopped: the number of lineages n above which the probability to sample
+// nbtips lineages is below 1e-6, plus the number of additional lineages after
+// which the probability to go back to n is below 1e-6 (with the rates of the
+// last interval).
+func (m BirthDeathModel) gsaMaxLineages(nbtips int) int {
+	last := len(m.Shifts)
+	n := int(math.Ceil(float64(nbtips) / m.Rho))
+	for bdSampleProba(n, nbtips, m.Rho) > 1e-6 {
+		n++
+	}
+	gap := float64(n)
+	ratio := (bdRate(m.Death, last) + bdRate(m.Sampling, last)) / bdRate(m.Birth, last)
+	if ratio < 1 {
+		// Probability to go back from n+gap lineages to n lineages is about ratio^gap
+		gap = math.Log(1e-6) / math.Log(ratio)
+	}
+	return n + int(math.Ceil(gap))
+}
+
+// Weighted reservoir of periods of time with a constant number of lineages:
+// each added period replaces the chosen one with probability weight/(total weight)
+type bdReservoir struct {
+	nbtips int
+	rho    float64
+	weight float64   // Total weight of the added periods
+	root   *bdNode   // Root of the simulation of the chosen period
+	alive  []*bdNode // Lineages alive during the chosen period
+	time   float64   // Time drawn uniformly in the chosen period
+}
+
+// Adds a period starting at the given time, with the given alive lineages
+func (r *bdReservoir) add(root *bdNode, alive []*bdNode, start, length float64) {
+	w := length * bdSampleProba(len(alive), r.nbtips, r.rho)
+	if w <= 0 {
+		return
+	}
+	r.weight += w
+	if rand.Float64()*r.weight < w {
+		r.root = root
+		r.alive = append([]*bdNode(nil), alive...)
+		r.time = start + rand.Float64()*length
+	}
+}
+
+// Probability to sample exactly k lineages out of n, each
+// being sampled with probability rho
+func bdSampleProba(n, k int, rho float64) float64 {
+	if n < k {
+		return 0
+	}
+	if rho == 1 {
+		if n == k {
+			return 1
+		}
+		return 0
+	}
+	lgn, _ := math.Lgamma(float64(n + 1))
+	lgk, _ := math.Lgamma(float64(k + 1))
+	lgnk, _ := math.Lgamma(float64(n - k + 1))
+	return math.Exp(lgn - lgk - lgnk + float64(k)*math.Log(rho) + float64(n-k)*math.Log(1-rho))
+}
+
+// Builds the tree under the given simulated node, and returns its root node and its time.
+// If complete is false, removes subtrees without sampled tips, and nodes with a single child.
+// Returns false if no tip is kept. nsampled is incremented for each sampled tip.
+func bdBuildTree(t *Tree, n *bdNode, complete bool, tipid, nsampled *int) (node *Node, time float64, kept bool) {
+	if len(n.children) == 0 {
+		sampled := n.status == bdExtant || n.status == bdSampled
+		if sampled {
+			(*nsampled)++
+		} else if !complete {
+			return
+		}
+		node = t.NewNode()
+		node.SetName("Tip" + strconv.Itoa(*tipid))
+		(*tipid)++
+		if complete {
+			node.SetAttribute("status", NewStringAttribute([]string{"extant", "unsampled", "extinct", "sampled"}[n.status]))
+		}
+		return node, n.time, true
+	}
+
+	children := make([]*Node, 0, len(n.children))
+	times := make([]float64, 0, len(n.children))
+	for _, c := range n.children {
+		if cnode, ctime, ckept := bdBuildTree(t, c, complete, tipid, nsampled); ckept {
+			children = append(children, cnode)
+			times = append(times, ctime)
+		}
+	}
+	switch len(children) {
+	case 0:
+		return
+	case 1:
+		return children[0], times[0], true
+	}
+	node = t.NewNode()
+	for i, c := range children {
+		e := t.ConnectNodes(node, c)
+		e.SetLength(times[i] - n.time)
+	}
+	return node, n.time, true
+}
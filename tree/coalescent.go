@@ -0,0 +1,145 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Parameters of the coalescent
+type CoalescentModel struct {
+	PopSize float64 // Effective population size at present, in time units (e.g. Ne * generation time)
+	Growth  float64 // Exponential growth rate: N(t) = PopSize * exp(-Growth * t), t being the time before present
+}
+
+// Simulates a genealogy of nbtips tips, all sampled at present, under Kingman's coalescent,
+// with a constant (Growth = 0) or exponentially growing population size.
+//
+// The tree is rooted, ultrametric, its branch lengths are in time units,
+// and tips are named Tip<i>.
+func RandomCoalescentTree(m CoalescentModel, nbtips int) (*Tree, error) {
+	if nbtips < 2 {
+		return nil, errors.New("Cannot create a coalescent tree with less than 2 tips")
+	}
+	names := make([]string, nbtips)
+	heights := make([]float64, nbtips)
+	for i := range names {
+		names[i] = "Tip" + strconv.Itoa(i)
+	}
+	return randomCoalescentTree(m, names, heights)
+}
+
+// Simulates a genealogy of serially sampled tips under Kingman's coalescent, with a
+// constant (Growth = 0) or exponentially growing population size.
+//
+// dates: mapping between tip names and sampling dates (forward in time, e.g. in years).
+// The present is the most recent sampling date.
+//
+// The tree is rooted, and its branch lengths are in the time units of the dates.
+func RandomSerialCoalescentTree(m CoalescentModel, dates map[string]float64) (*Tree, error) {
+	if len(dates) < 2 {
+		return nil, errors.New("Cannot create a coalescent tree with less than 2 tips")
+	}
+	names := make([]string, 0, len(dates))
+	for name := range dates {
+		names = append(names, name)
+	}
+	// Deterministic order of the tips, given the seed
+	sort.Strings(names)
+	last := math.Inf(-1)
+	for _, d := range dates {
+		last = math.Max(last, d)
+	}
+	heights := make([]float64, len(names))
+	for i, name := range names {
+		heights[i] = last - dates[name]
+	}
+	return randomCoalescentTree(m, names, heights)
+}
+
+// Simulates the coalescent backward in time, given tip names
+// and sampling heights (time before present)
+func randomCoalescentTree(m CoalescentModel, names []string, heights []float64) (t *Tree, err error) {
+	var time float64
+
+	if m.PopSize <= 0 {
+		err = errors.New("Population size must be > 0")
+		return
+	}
+
+	// Tips, sorted by sampling height
+	order := make([]int, len(names))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return heights[order[i]] < heights[order[j]] })
+
+	t = NewTree()
+	lineages := make([]*Node, 0, len(names))
+	lineageheights := make([]float64, 0, len(names))
+	next := 0
+	for next < len(order) || len(lineages) > 1 {
+		// Adds all lineages sampled before the current time
+		for next < len(order) && (heights[order[next]] <= time || len(lineages) < 2) {
+			n := t.NewNode()
+			n.SetName(names[order[next]])
+			lineages = append(lineages, n)
+			lineageheights = append(lineageheights, heights[order[next]])
+			time = math.Max(time, heights[order[next]])
+			next++
+		}
+		if len(lineages) < 2 {
+			continue
+		}
+		wait, ok := m.waitingTime(time, len(lineages))
+		if next < len(order) && (!ok || time+wait > heights[order[next]]) {
+			// Next sampling event happens before the next coalescence
+			time = heights[order[next]]
+			continue
+		}
+		if !ok {
+			t = nil
+			err = fmt.Errorf("Lineages never coalesce with a population size decreasing backward in time (growth rate %f)", m.Growth)
+			return
+		}
+		time += wait
+
+		// Coalescence of two random lineages
+		i := rand.Intn(len(lineages))
+		j := rand.Intn(len(lineages) - 1)
+		if j >= i {
+			j++
+		}
+		parent := t.NewNode()
+		t.ConnectNodes(parent, lineages[i]).SetLength(time - lineageheights[i])
+		t.ConnectNodes(parent, lineages[j]).SetLength(time - lineageheights[j])
+		if i < j {
+			i, j = j, i
+		}
+		lineages[j], lineageheights[j] = parent, time
+		lineages[i], lineageheights[i] = lineages[len(lineages)-1], lineageheights[len(lineages)-1]
+		lineages, lineageheights = lineages[:len(lineages)-1], lineageheights[:len(lineageheights)-1]
+	}
+	t.SetRoot(lineages[0])
+	t.ReinitIndexes()
+	return
+}
+
+// Draws the waiting time until the next coalescence, starting at the given
+// time before present, with k lineages. Returns false if lineages never coalesce.
+func (m CoalescentModel) waitingTime(time float64, k int) (wait float64, ok bool) {
+	pairs := float64(k*(k-1)) / 2
+	e := rand.ExpFloat64()
+	if m.Growth == 0 {
+		return e * m.PopSize / pairs, true
+	}
+	// Solves: pairs/(PopSize*Growth) * (exp(Growth*(time+wait)) - exp(Growth*time)) = e
+	x := math.Exp(m.Growth*time) + e*m.PopSize*m.Growth/pairs
+	if x <= 0 {
+		return 0, false
+	}
+	return math.Log(x)/m.Growth - time, true
+}
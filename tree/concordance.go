@@ -0,0 +1,261 @@
+package tree
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/fredericlemoine/bitset"
+)
+
+// Gene concordance statistics of an internal edge of a reference (species) tree
+type EdgeConcordance struct {
+	Edge        *Edge // Edge of the reference tree
+	Decisive    int   // Number of decisive gene trees (gN)
+	Concordant  int   // Number of decisive gene trees containing the edge
+	Discordant1 int   // Number of decisive gene trees containing the first NNI alternative of the edge
+	Discordant2 int   // Number of decisive gene trees containing the second NNI alternative of the edge
+}
+
+// Gene concordance factor: percentage of decisive gene trees containing the edge
+func (ec *EdgeConcordance) GCF() float64 {
+	return percentage(ec.Concordant, ec.Decisive)
+}
+
+// Gene discordance factor: percentage of decisive gene trees containing the first NNI alternative
+func (ec *EdgeConcordance) GDF1() float64 {
+	return percentage(ec.Discordant1, ec.Decisive)
+}
+
+// Gene discordance factor: percentage of decisive gene trees containing the second NNI alternative
+func (ec *EdgeConcordance) GDF2() float64 {
+	return percentage(ec.Discordant2, ec.Decisive)
+}
+
+// Gene discordance factor due to paraphyly: percentage of decisive gene trees
+// containing neither the edge nor its NNI alternatives
+func (ec *EdgeConcordance) GDFP() float64 {
+	return percentage(ec.Decisive-ec.Concordant-ec.Discordant1-ec.Discordant2, ec.Decisive)
+}
+
+func percentage(n, total int) float64 {
+	if total == 0 {
+		return 0.0
+	}
+	return 100.0 * float64(n) / float64(total)
+}
+
+// A subtree around an internal edge of the reference tree:
+// the clade under node, or its complement if up is true
+type concordanceSubtree struct {
+	node int
+	up   bool
+}
+
+// Tips of a clade of the reference tree restricted to the tips of a gene tree:
+// bitset indexed by the gene tree tip indexes, sum of the tip hashes (as
+// in edge hashcodes), and number of tips
+type concordanceClade struct {
+	bitset *bitset.BitSet
+	hash   uint64
+	ntax   int
+}
+
+// Computes gene concordance factors (Minh et al., 2020) of all internal edges
+// of the reference (species) tree t, given a set of gene trees. Gene trees may
+// have incomplete sets of tips, but all their tips must be present in the reference
+// tree.
+//
+// Each internal edge of t defines subtrees around it (4 subtrees for binary trees:
+// L1, L2 on its left and R1, R2 on its right). For a given edge, a gene tree is decisive
+// if it contains at least one tip of each subtree. Among the decisive gene trees:
+//	* gCF is the percentage of gene trees containing the edge (restricted to their tips)
+//	* gDF1 is the percentage of gene trees containing the first NNI alternative (L1R1|L2R2)
+//	* gDF2 is the percentage of gene trees containing the second NNI alternative (L1R2|L2R1)
+//	* gDFP is the percentage of gene trees containing none of them
+// Discordance factors are only computed for edges whose extremities have 3 neighbors.
+//
+// The edges of t are annotated with attributes gCF, gDF1, gDF2, gDFP (rounded to 2 decimals)
+// and gN (number of decisive gene trees), and the statistics of each internal edge are returned, in the order
+// of t.Edges().
+//
+// As in Compare, the bipartitions of each gene tree are stored in an EdgeIndex, and
+// the bipartitions of t, restricted to the tips of the gene tree, are looked up in it.
+// Gene tree indexes are (re)initialized (see ReinitIndexes).
+func GeneConcordance(t *Tree, genetrees <-chan Trees) (conc []*EdgeConcordance, err error) {
+	var nodes []*Node
+	var parent []int
+
+	// Reference tree indexing
+	ids := make(map[*Node]int)
+	tipnames := make(map[string]bool)
+	nodes = t.Nodes()
+	parent = make([]int, len(nodes))
+	for i, n := range nodes {
+		ids[n] = i
+		if n.Tip() {
+			if _, ok := tipnames[n.Name()]; ok {
+				err = fmt.Errorf("Tip %s is present several times in the reference tree", n.Name())
+				return
+			}
+			tipnames[n.Name()] = true
+		}
+	}
+	// Nodes in post-order
+	postorder := make([]int, 0, len(nodes))
+	t.PostOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		parent[ids[cur]] = -1
+		if prev != nil {
+			parent[ids[cur]] = ids[prev]
+		}
+		postorder = append(postorder, ids[cur])
+		return true
+	})
+	root := postorder[len(postorder)-1]
+
+	// Subtrees around each internal edge
+	conc = make([]*EdgeConcordance, 0)
+	lefts := make([][]concordanceSubtree, 0)
+	rights := make([][]concordanceSubtree, 0)
+	for _, e := range t.Edges() {
+		if e.Right().Tip() || e.Left().Tip() {
+			continue
+		}
+		u, v := ids[e.Left()], ids[e.Right()]
+		left := make([]concordanceSubtree, 0, 2)
+		right := make([]concordanceSubtree, 0, 2)
+		for _, n := range e.Left().Neigh() {
+			if id := ids[n]; id != v {
+				if id == parent[u] {
+					left = append(left, concordanceSubtree{u, true})
+				} else {
+					left = append(left, concordanceSubtree{id, false})
+				}
+			}
+		}
+		for _, n := range e.Right().Neigh() {
+			if id := ids[n]; id != u {
+				right = append(right, concordanceSubtree{id, false})
+			}
+		}
+		conc = append(conc, &EdgeConcordance{Edge: e})
+		lefts = append(lefts, left)
+		rights = append(rights, right)
+	}
+
+	clades := make([]concordanceClade, len(nodes))
+	for gt := range genetrees {
+		if gt.Err != nil {
+			err = gt.Err
+			break
+		}
+		for _, tip := range gt.Tree.Tips() {
+			if _, ok := tipnames[tip.Name()]; !ok {
+				err = fmt.Errorf("Tip %s of gene tree %d is not present in the reference tree", tip.Name(), gt.Id)
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+		if err = gt.Tree.ReinitIndexes(); err != nil {
+			break
+		}
+		ntips := uint(len(gt.Tree.tipIndex))
+
+		// Bipartitions of the gene tree
+		edges := gt.Tree.Edges()
+		genebips := NewEdgeIndex(uint64(len(edges)*2), 0.75)
+		for _, e := range edges {
+			if !e.Right().Tip() {
+				genebips.PutEdgeValue(e, 1, e.Length())
+			}
+		}
+
+		// Clades of the reference tree restricted to the gene tree tips
+		for id, n := range nodes {
+			clades[id] = concordanceClade{bitset: bitset.New(ntips)}
+			if n.Tip() {
+				if tipid, err2 := gt.Tree.TipIndex(n.Name()); err2 == nil {
+					clades[id].bitset.Set(uint(tipid))
+					clades[id].hash = tax_hash(n.Name())
+					clades[id].ntax = 1
+				}
+			}
+		}
+		for _, id := range postorder {
+			if p := parent[id]; p != -1 {
+				clades[p].bitset.InPlaceUnion(clades[id].bitset)
+				clades[p].hash += clades[id].hash
+				clades[p].ntax += clades[id].ntax
+			}
+		}
+
+		subtree := func(s concordanceSubtree) concordanceClade {
+			if s.up {
+				c := clades[s.node]
+				return concordanceClade{c.bitset.Complement(), clades[root].hash - c.hash, clades[root].ntax - c.ntax}
+			}
+			return clades[s.node]
+		}
+		// Looks up the bipartition separating the given subtrees
+		// from the others in the gene tree bipartitions
+		inGeneTree := func(subtrees ...concordanceClade) bool {
+			bip := &Edge{bitset: bitset.New(ntips)}
+			for _, c := range subtrees {
+				bip.bitset.InPlaceUnion(c.bitset)
+				bip.hashcoderight += c.hash
+				bip.ntaxright += c.ntax
+			}
+			bip.hashcodeleft = clades[root].hash - bip.hashcoderight
+			bip.ntaxleft = clades[root].ntax - bip.ntaxright
+			_, ok := genebips.Value(bip)
+			return ok
+		}
+
+		for i, ec := range conc {
+			decisive := true
+			right := make([]concordanceClade, 0, len(rights[i]))
+			for _, s := range lefts[i] {
+				if subtree(s).ntax == 0 {
+					decisive = false
+				}
+			}
+			for _, s := range rights[i] {
+				c := subtree(s)
+				if c.ntax == 0 {
+					decisive = false
+				}
+				right = append(right, c)
+			}
+			if !decisive {
+				continue
+			}
+			ec.Decisive++
+			if inGeneTree(right...) {
+				ec.Concordant++
+			} else if len(lefts[i]) == 2 && len(rights[i]) == 2 {
+				l1 := subtree(lefts[i][0])
+				if inGeneTree(l1, right[0]) {
+					ec.Discordant1++
+				} else if inGeneTree(l1, right[1]) {
+					ec.Discordant2++
+				}
+			}
+		}
+	}
+	if err != nil {
+		// We empty the channel if needed
+		for range genetrees {
+		}
+		return
+	}
+
+	for _, ec := range conc {
+		ec.Edge.SetAttribute("gCF", NewFloatAttribute(math.Round(ec.GCF()*100)/100))
+		ec.Edge.SetAttribute("gDF1", NewFloatAttribute(math.Round(ec.GDF1()*100)/100))
+		ec.Edge.SetAttribute("gDF2", NewFloatAttribute(math.Round(ec.GDF2()*100)/100))
+		ec.Edge.SetAttribute("gDFP", NewFloatAttribute(math.Round(ec.GDFP()*100)/100))
+		ec.Edge.SetAttribute("gN", NewIntAttribute(ec.Decisive))
+	}
+	return
+}
@@ -0,0 +1,463 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Maximum number of iterations of the alternate
+// optimization of the rate and of the node dates
+const maxDatingIterations = 1000
+
+// Calibration of the date of the LCA (rooted) of a set of tips
+type DatingCalibration struct {
+	Tips  []string
+	Lower float64 // Minimum date of the LCA, math.Inf(-1) if none
+	Upper float64 // Maximum date of the LCA, math.Inf(1) if none
+}
+
+// Options of least-squares dating
+type DatingOptions struct {
+	Rate         float64 // Substitution rate, estimated if <= 0
+	SeqLen       int     // Length of the alignment, used to weight branches; unweighted if <= 0
+	Calibrations []DatingCalibration
+}
+
+// Result of least-squares dating
+type DatingResult struct {
+	Rate      float64 // Substitution rate per unit of time
+	RootDate  float64 // Date of the root
+	Objective float64 // Weighted sum of squares at the optimum
+}
+
+// Least-squares dating problem, nodes being indexed in pre-order
+type datingProblem struct {
+	nodes    []*Node
+	edges    []*Edge   // Edge from the parent of each node
+	parent   []int     // Parent of each node, -1 for the root
+	children [][]int   // Children of each node
+	b        []float64 // Length of the branch from the parent, in substitutions per site
+	w        []float64 // Variance of the branch from the parent
+	tip      []bool
+	lower    []float64 // Bounds of the dates
+	upper    []float64
+	x        []float64 // Current (feasible) dates
+	rigid    []bool    // Working set: date of the node equals the date of its parent
+	bound    []int     // Working set: date of the node fixed at its lower (-1) or upper (+1) bound
+}
+
+// Converts the branch lengths of the tree, in substitutions per site, into time units, using
+// least-squares dating as LSD (To et al., 2016), given the sampling dates of all the tips and
+// optional calibrations of internal nodes.
+//
+// The root of the tree is considered as the true root. The rate ω and the node dates t
+// minimize sum_i (b_i - ω(t_i - t_parent(i)))² / v_i, under the constraints that dates
+// increase from the root to the tips (branch lengths are >= 0), and that calibrated nodes
+// have dates in their intervals. If SeqLen > 0, v_i = (b_i + 1/SeqLen)/SeqLen, as the
+// variance of the branch lengths, otherwise v_i = 1. For a given rate, dates are optimized
+// with an active set method, and the rate is optimized alternately, starting from the
+// rate of the root-to-tip regression, until convergence. If Rate > 0, it is not estimated.
+//
+// Branch lengths of the tree are replaced by durations, and each node gets a
+// "date" attribute. Returns an error if the tree is not rooted.
+func (t *Tree) LeastSquaresDating(dates map[string]float64, opts DatingOptions) (res *DatingResult, err error) {
+	var p *datingProblem
+	var rate float64
+
+	if !t.Rooted() {
+		err = errors.New("The tree must be rooted")
+		return
+	}
+	if p, err = newDatingProblem(t, dates, opts); err != nil {
+		return
+	}
+	if rate = opts.Rate; rate <= 0 {
+		if rate, err = p.initialRate(t, dates); err != nil {
+			return
+		}
+	}
+	if err = p.initDates(rate); err != nil {
+		return
+	}
+
+	for it := 0; it < maxDatingIterations; it++ {
+		p.optimizeDates(rate)
+		if opts.Rate > 0 {
+			break
+		}
+		newrate := p.optimalRate()
+		if newrate <= 0 || math.IsNaN(newrate) {
+			err = errors.New("Could not estimate a positive substitution rate")
+			return
+		}
+		converged := math.Abs(newrate-rate) <= 1e-10*rate
+		rate = newrate
+		if converged {
+			p.optimizeDates(rate)
+			break
+		}
+	}
+
+	for i, n := range p.nodes {
+		n.SetAttribute("date", NewFloatAttribute(p.x[i]))
+		if i > 0 {
+			p.edges[i].SetLength(math.Max(0, p.x[i]-p.x[p.parent[i]]))
+		}
+	}
+	res = &DatingResult{Rate: rate, RootDate: p.x[0], Objective: p.objective(rate)}
+	return
+}
+
+func newDatingProblem(t *Tree, dates map[string]float64, opts DatingOptions) (p *datingProblem, err error) {
+	var index map[*Node]int = make(map[*Node]int)
+	var nodeindex *nodeIndex
+
+	p = &datingProblem{}
+	t.PreOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		index[cur] = len(p.nodes)
+		p.nodes = append(p.nodes, cur)
+		p.edges = append(p.edges, e)
+		p.children = append(p.children, nil)
+		p.lower = append(p.lower, math.Inf(-1))
+		p.upper = append(p.upper, math.Inf(1))
+		p.tip = append(p.tip, cur.Tip())
+		if prev == nil {
+			p.parent = append(p.parent, -1)
+			p.b = append(p.b, 0)
+		} else {
+			p.parent = append(p.parent, index[prev])
+			p.children[index[prev]] = append(p.children[index[prev]], index[cur])
+			p.b = append(p.b, e.Length())
+			if e.Length() == NIL_LENGTH {
+				err = errors.New("Dating requires branch lengths")
+				return false
+			}
+		}
+		return err == nil
+	})
+	if err != nil {
+		return
+	}
+
+	p.w = make([]float64, len(p.nodes))
+	for i := range p.w {
+		p.w[i] = 1
+		if opts.SeqLen > 0 {
+			s := float64(opts.SeqLen)
+			p.w[i] = (math.Max(0, p.b[i]) + 1/s) / s
+		}
+	}
+
+	p.x = make([]float64, len(p.nodes))
+	for i, n := range p.nodes {
+		if p.tip[i] {
+			d, ok := dates[n.Name()]
+			if !ok {
+				err = fmt.Errorf("Tip %s has no date", n.Name())
+				return
+			}
+			p.lower[i], p.upper[i], p.x[i] = d, d, d
+		}
+	}
+
+	if len(opts.Calibrations) > 0 {
+		if nodeindex, err = NewNodeIndex(t); err != nil {
+			return
+		}
+	}
+	for _, c := range opts.Calibrations {
+		var lca *Node
+		if lca, _, _, err = t.LeastCommonAncestorRooted(nodeindex, c.Tips...); err != nil {
+			return
+		}
+		i := index[lca]
+		if p.tip[i] {
+			err = fmt.Errorf("Calibration of tip %s: tip dates must be given as tip dates", lca.Name())
+			return
+		}
+		p.lower[i] = math.Max(p.lower[i], c.Lower)
+		p.upper[i] = math.Min(p.upper[i], c.Upper)
+		if p.lower[i] > p.upper[i] {
+			err = fmt.Errorf("Calibration interval of the LCA of %v is empty", c.Tips)
+			return
+		}
+	}
+
+	p.rigid = make([]bool, len(p.nodes))
+	p.bound = make([]int, len(p.nodes))
+	return
+}
+
+// Initial rate: slope of the root-to-tip regression, or, if it is not
+// positive, rate given by the first calibrated node
+func (p *datingProblem) initialRate(t *Tree, dates map[string]float64) (rate float64, err error) {
+	if reg, err2 := t.RootToTipRegression(dates); err2 == nil && reg.Rate > 0 {
+		return reg.Rate, nil
+	}
+	for i := range p.nodes {
+		if p.tip[i] || (math.IsInf(p.lower[i], -1) && math.IsInf(p.upper[i], 1)) {
+			continue
+		}
+		date := p.lower[i]
+		if math.IsInf(date, -1) {
+			date = p.upper[i]
+		} else if !math.IsInf(p.upper[i], 1) {
+			date = (p.lower[i] + p.upper[i]) / 2
+		}
+		// Distance and duration from the calibrated node to its tips
+		var dist, time float64
+		var recur func(j int, d float64)
+		recur = func(j int, d float64) {
+			if p.tip[j] {
+				dist += d
+				time += p.x[j] - date
+			}
+			for _, c := range p.children[j] {
+				recur(c, d+p.b[c])
+			}
+		}
+		recur(i, 0)
+		if time > 0 && dist > 0 {
+			return dist / time, nil
+		}
+	}
+	err = errors.New("Cannot estimate an initial substitution rate: the root-to-tip regression has no positive slope, and no internal node is calibrated")
+	return
+}
+
+// Computes initial feasible dates: each internal node is placed before its
+// children, at the distance given by the branch lengths and the rate, and
+// moved if needed so that it is between its parent and its children, and
+// in its calibration interval.
+func (p *datingProblem) initDates(rate float64) error {
+	// Maximum feasible date, and date given by the branch lengths
+	maxdate := make([]float64, len(p.nodes))
+	target := make([]float64, len(p.nodes))
+	for i := len(p.nodes) - 1; i >= 0; i-- {
+		if p.tip[i] {
+			maxdate[i], target[i] = p.x[i], p.x[i]
+			continue
+		}
+		maxdate[i], target[i] = p.upper[i], math.Inf(1)
+		for _, c := range p.children[i] {
+			maxdate[i] = math.Min(maxdate[i], maxdate[c])
+			target[i] = math.Min(target[i], target[c]-math.Max(0, p.b[c])/rate)
+		}
+	}
+	for i := range p.nodes {
+		mindate := p.lower[i]
+		if i > 0 {
+			mindate = math.Max(mindate, p.x[p.parent[i]])
+		}
+		if mindate > maxdate[i] {
+			return errors.New("Calibrations are inconsistent with the tip dates")
+		}
+		if !p.tip[i] {
+			p.x[i] = math.Min(maxdate[i], math.Max(mindate, target[i]))
+		}
+	}
+	return nil
+}
+
+// Weight and target duration of the branch from the parent of node i
+func (p *datingProblem) branch(i int, rate float64) (w, d float64) {
+	return rate * rate / p.w[i], p.b[i] / rate
+}
+
+// Optimal rate given the current dates
+func (p *datingProblem) optimalRate() float64 {
+	var num, den float64
+	for i := 1; i < len(p.nodes); i++ {
+		tau := p.x[i] - p.x[p.parent[i]]
+		num += p.b[i] * tau / p.w[i]
+		den += tau * tau / p.w[i]
+	}
+	return num / den
+}
+
+// Weighted sum of squares with the current dates
+func (p *datingProblem) objective(rate float64) (obj float64) {
+	for i := 1; i < len(p.nodes); i++ {
+		r := p.b[i] - rate*(p.x[i]-p.x[p.parent[i]])
+		obj += r * r / p.w[i]
+	}
+	return
+}
+
+// Is the date of the node fixed by itself (tip or bound in the working set)
+func (p *datingProblem) pinned(i int) bool {
+	return p.tip[i] || p.bound[i] != 0
+}
+
+// Optimizes the node dates for the given rate, using a primal active set method:
+// the working set contains the constraints considered as equalities (branches of
+// length 0, and dates at their bounds). Each iteration solves the problem with
+// the working set in linear time, and either moves toward its solution until a
+// constraint blocks (which is added to the working set), or removes the constraint
+// with the most negative Lagrange multiplier.
+func (p *datingProblem) optimizeDates(rate float64) {
+	n := len(p.nodes)
+	xs := make([]float64, n)
+	g := make([]float64, n)
+
+	for it := 0; it < 10*n+100; it++ {
+		p.solveWorkingSet(rate, xs)
+
+		// Step toward the solution, until a constraint blocks
+		alpha, block, blockbound := 1.0, -1, 0
+		for i := 1; i < n; i++ {
+			if p.rigid[i] {
+				continue
+			}
+			if ds := (xs[i] - p.x[i]) - (xs[p.parent[i]] - p.x[p.parent[i]]); ds < 0 {
+				if a := math.Max(0, p.x[i]-p.x[p.parent[i]]) / -ds; a < alpha {
+					alpha, block, blockbound = a, i, 0
+				}
+			}
+		}
+		for i := 0; i < n; i++ {
+			if p.tip[i] || p.bound[i] != 0 {
+				continue
+			}
+			dx := xs[i] - p.x[i]
+			if dx < 0 && !math.IsInf(p.lower[i], -1) {
+				if a := math.Max(0, p.x[i]-p.lower[i]) / -dx; a < alpha {
+					alpha, block, blockbound = a, i, -1
+				}
+			} else if dx > 0 && !math.IsInf(p.upper[i], 1) {
+				if a := math.Max(0, p.upper[i]-p.x[i]) / dx; a < alpha {
+					alpha, block, blockbound = a, i, 1
+				}
+			}
+		}
+		if block >= 0 {
+			for i := range p.x {
+				p.x[i] += alpha * (xs[i] - p.x[i])
+			}
+			if blockbound == 0 {
+				p.rigid[block] = true
+			} else {
+				p.bound[block] = blockbound
+			}
+			continue
+		}
+		copy(p.x, xs)
+
+		// Lagrange multipliers of the working set
+		gmax := 0.0
+		for i := range g {
+			g[i] = 0
+		}
+		for i := 1; i < n; i++ {
+			w, d := p.branch(i, rate)
+			r := 2 * w * (p.x[i] - p.x[p.parent[i]] - d)
+			g[i] += r
+			g[p.parent[i]] -= r
+			gmax = math.Max(gmax, math.Abs(r))
+		}
+		// Sums of gradients and pinned nodes in the part of the
+		// cluster (nodes linked by rigid branches) under each node
+		sub := make([]float64, n)
+		haspinned := make([]bool, n)
+		for i := n - 1; i >= 0; i-- {
+			sub[i] += g[i]
+			haspinned[i] = haspinned[i] || p.pinned(i)
+			if i > 0 && p.rigid[i] {
+				sub[p.parent[i]] += sub[i]
+				haspinned[p.parent[i]] = haspinned[p.parent[i]] || haspinned[i]
+			}
+		}
+		croot := make([]int, n)
+		minlambda, release := -1e-9*math.Max(gmax, 1e-12), -1
+		for i := 0; i < n; i++ {
+			croot[i] = i
+			if i > 0 && p.rigid[i] {
+				croot[i] = croot[p.parent[i]]
+			}
+			var lambda float64
+			switch {
+			case i > 0 && p.rigid[i] && !haspinned[i]:
+				lambda = sub[i]
+			case i > 0 && p.rigid[i]:
+				lambda = -(sub[croot[i]] - sub[i])
+			case p.bound[i] != 0:
+				lambda = -float64(p.bound[i]) * sub[croot[i]]
+			default:
+				continue
+			}
+			if lambda < minlambda {
+				minlambda, release = lambda, i
+			}
+		}
+		if release < 0 {
+			return
+		}
+		if p.rigid[release] {
+			p.rigid[release] = false
+		} else {
+			p.bound[release] = 0
+		}
+	}
+}
+
+// Solves the least-squares problem where the constraints of the working set
+// are equalities, and stores the dates in xs. Going up the tree, the cost of
+// the subtree of each free node is a quadratic function a*(t-m)² of its date t.
+func (p *datingProblem) solveWorkingSet(rate float64, xs []float64) {
+	n := len(p.nodes)
+	a := make([]float64, n)
+	m := make([]float64, n)
+	fixed := make([]bool, n)
+
+	for i := n - 1; i >= 0; i-- {
+		switch {
+		case p.tip[i]:
+			fixed[i], m[i] = true, p.x[i]
+		case p.bound[i] < 0:
+			fixed[i], m[i] = true, p.lower[i]
+		case p.bound[i] > 0:
+			fixed[i], m[i] = true, p.upper[i]
+		}
+		var suma, summ float64
+		for _, c := range p.children[i] {
+			w, d := p.branch(c, rate)
+			switch {
+			case p.rigid[c] && fixed[c]:
+				fixed[i], m[i] = true, m[c]
+			case p.rigid[c]:
+				suma += a[c]
+				summ += a[c] * m[c]
+			case fixed[c]:
+				suma += w
+				summ += w * (m[c] - d)
+			default:
+				k := w * a[c] / (w + a[c])
+				suma += k
+				summ += k * (m[c] - d)
+			}
+		}
+		if !fixed[i] {
+			a[i] = suma
+			if suma > 0 {
+				m[i] = summ / suma
+			} else {
+				m[i] = p.x[i]
+			}
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		switch {
+		case fixed[i]:
+			xs[i] = m[i]
+		case i == 0:
+			xs[i] = m[i]
+		case p.rigid[i]:
+			xs[i] = xs[p.parent[i]]
+		default:
+			w, d := p.branch(i, rate)
+			xs[i] = (w*(xs[p.parent[i]]+d) + a[i]*m[i]) / (w + a[i])
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Possible handlings of negative branch lengths
+// inferred by NJ and BIONJ
+const (
+	NEGATIVE_KEEP     = iota // Negative branch lengths are kept as is
+	NEGATIVE_ZERO            // Negative branch lengths are set to 0
+	NEGATIVE_TRANSFER        // Negative branch lengths are set to 0, and the difference is transfered to the sister branch
+)
+
+// Infers a tree from a distance matrix using the Neighbor-Joining
+// algorithm (Saitou & Nei, 1987).
+//
+//	* names: names of the taxa (in the order of the matrix)
+//	* matrix: square and symmetric distance matrix
+//	* negative: handling of negative branch lengths: NEGATIVE_KEEP, NEGATIVE_ZERO or NEGATIVE_TRANSFER
+//
+// The returned tree is unrooted (trifurcation at the root).
+func NeighborJoining(names []string, matrix [][]float64, negative int) (*Tree, error) {
+	return neighborJoining(names, matrix, negative, false)
+}
+
+// Infers a tree from a distance matrix using the BIONJ
+// algorithm (Gascuel, 1997).
+//
+//	* names: names of the taxa (in the order of the matrix)
+//	* matrix: square and symmetric distance matrix
+//	* negative: handling of negative branch lengths: NEGATIVE_KEEP, NEGATIVE_ZERO or NEGATIVE_TRANSFER
+//
+// The returned tree is unrooted (trifurcation at the root).
+func BioNJ(names []string, matrix [][]float64, negative int) (*Tree, error) {
+	return neighborJoining(names, matrix, negative, true)
+}
+
+// Infers a rooted ultrametric tree from a distance matrix using the
+// UPGMA algorithm (average linkage).
+//
+//	* names: names of the taxa (in the order of the matrix)
+//	* matrix: square and symmetric distance matrix
+func UPGMA(names []string, matrix [][]float64) (*Tree, error) {
+	var d [][]float64
+	var err error
+
+	if d, err = checkDistanceMatrix(names, matrix); err != nil {
+		return nil, err
+	}
+	t := NewTree()
+	n := len(names)
+	nodes := make([]*Node, n)
+	heights := make([]float64, n)
+	sizes := make([]int, n)
+	active := make([]int, n)
+	for i, name := range names {
+		nodes[i] = t.NewNode()
+		nodes[i].SetName(name)
+		sizes[i] = 1
+		active[i] = i
+	}
+
+	if n == 1 {
+		t.SetRoot(nodes[0])
+		return t, nil
+	}
+
+	for len(active) > 1 {
+		// We search the closest pair of clusters
+		mini, minj := 0, 1
+		for a := 0; a < len(active); a++ {
+			for b := a + 1; b < len(active); b++ {
+				if d[active[a]][active[b]] < d[active[mini]][active[minj]] {
+					mini, minj = a, b
+				}
+			}
+		}
+		i, j := active[mini], active[minj]
+		height := d[i][j] / 2.0
+		u := t.NewNode()
+		e := t.ConnectNodes(u, nodes[i])
+		e.SetLength(height - heights[i])
+		e = t.ConnectNodes(u, nodes[j])
+		e.SetLength(height - heights[j])
+
+		// New cluster replaces i in the matrix
+		for _, k := range active {
+			if k != i && k != j {
+				d[i][k] = (d[i][k]*float64(sizes[i]) + d[j][k]*float64(sizes[j])) / float64(sizes[i]+sizes[j])
+				d[k][i] = d[i][k]
+			}
+		}
+		nodes[i] = u
+		heights[i] = height
+		sizes[i] += sizes[j]
+		active = append(active[:minj], active[minj+1:]...)
+	}
+	t.SetRoot(nodes[active[0]])
+	t.ReinitIndexes()
+	return t, nil
+}
+
+// Neighbor-Joining or BIONJ (if bionj is true).
+func neighborJoining(names []string, matrix [][]float64, negative int, bionj bool) (*Tree, error) {
+	var d, v [][]float64
+	var err error
+
+	if negative != NEGATIVE_KEEP && negative != NEGATIVE_ZERO && negative != NEGATIVE_TRANSFER {
+		return nil, fmt.Errorf("Unknown negative branch length handling: %d", negative)
+	}
+	if d, err = checkDistanceMatrix(names, matrix); err != nil {
+		return nil, err
+	}
+	if bionj {
+		// Variance matrix, initialized to the distances
+		v, _ = checkDistanceMatrix(names, matrix)
+	}
+
+	t := NewTree()
+	n := len(names)
+	nodes := make([]*Node, n)
+	active := make([]int, n)
+	for i, name := range names {
+		nodes[i] = t.NewNode()
+		nodes[i].SetName(name)
+		active[i] = i
+	}
+
+	switch n {
+	case 1:
+		t.SetRoot(nodes[0])
+		return t, nil
+	case 2:
+		e := t.ConnectNodes(nodes[0], nodes[1])
+		e.SetLength(d[0][1])
+		t.SetRoot(nodes[0])
+		t.ReinitIndexes()
+		return t, nil
+	}
+
+	sums := make([]float64, n)
+	for len(active) > 3 {
+		r := float64(len(active))
+		for _, i := range active {
+			sums[i] = 0
+			for _, k := range active {
+				sums[i] += d[i][k]
+			}
+		}
+		// We search the pair minimizing the Q criterion
+		mini, minj := -1, -1
+		minq := math.Inf(1)
+		for a := 0; a < len(active); a++ {
+			for b := a + 1; b < len(active); b++ {
+				i, j := active[a], active[b]
+				q := (r-2)*d[i][j] - sums[i] - sums[j]
+				if q < minq {
+					minq = q
+					mini, minj = a, b
+				}
+			}
+		}
+		i, j := active[mini], active[minj]
+		li := d[i][j]/2.0 + (sums[i]-sums[j])/(2.0*(r-2))
+		lj := d[i][j] - li
+
+		lambda := 0.5
+		if bionj && v[i][j] != 0 {
+			sumv := 0.0
+			for _, k := range active {
+				if k != i && k != j {
+					sumv += v[j][k] - v[i][k]
+				}
+			}
+			lambda = 0.5 + sumv/(2.0*(r-2)*v[i][j])
+			lambda = math.Max(0, math.Min(1, lambda))
+		}
+
+		u := t.NewNode()
+		ei := t.ConnectNodes(u, nodes[i])
+		ej := t.ConnectNodes(u, nodes[j])
+		setNegativeLengths(negative, []*Edge{ei, ej}, []float64{li, lj})
+
+		// New node u replaces i in the matrix
+		for _, k := range active {
+			if k != i && k != j {
+				if bionj {
+					d[i][k] = lambda*(d[i][k]-li) + (1-lambda)*(d[j][k]-lj)
+					v[i][k] = lambda*v[i][k] + (1-lambda)*v[j][k] - lambda*(1-lambda)*v[i][j]
+					v[k][i] = v[i][k]
+				} else {
+					d[i][k] = (d[i][k] + d[j][k] - d[i][j]) / 2.0
+				}
+				d[k][i] = d[i][k]
+			}
+		}
+		nodes[i] = u
+		active = append(active[:minj], active[minj+1:]...)
+	}
+
+	// Last 3 nodes connected to the root
+	i, j, k := active[0], active[1], active[2]
+	root := t.NewNode()
+	ei := t.ConnectNodes(root, nodes[i])
+	ej := t.ConnectNodes(root, nodes[j])
+	ek := t.ConnectNodes(root, nodes[k])
+	setNegativeLengths(negative,
+		[]*Edge{ei, ej, ek},
+		[]float64{
+			(d[i][j] + d[i][k] - d[j][k]) / 2.0,
+			(d[i][j] + d[j][k] - d[i][k]) / 2.0,
+			(d[i][k] + d[j][k] - d[i][j]) / 2.0,
+		})
+	t.SetRoot(root)
+	t.ReinitIndexes()
+	return t, nil
+}
+
+// Sets the lengths of the given sister edges, handling negative
+// lengths according to the given method:
+//	* NEGATIVE_KEEP: Negative lengths are kept as is
+//	* NEGATIVE_ZERO: Negative lengths are set to 0
+//	* NEGATIVE_TRANSFER: Negative lengths are set to 0 and the difference
+//	  is transfered to the first sister edge with a positive length
+//	  (so that the distance between the sister nodes is unchanged)
+func setNegativeLengths(negative int, edges []*Edge, lengths []float64) {
+	for i, l := range lengths {
+		if l < 0 && negative != NEGATIVE_KEEP {
+			if negative == NEGATIVE_TRANSFER {
+				for j := range lengths {
+					if j != i && lengths[j] > 0 {
+						lengths[j] = math.Max(0, lengths[j]+l)
+						break
+					}
+				}
+			}
+			lengths[i] = 0
+		}
+	}
+	for i, e := range edges {
+		e.SetLength(lengths[i])
+	}
+}
+
+// Checks that the matrix is square, symmetric, and has the same size as the
+// list of names, and returns a copy of the matrix.
+func checkDistanceMatrix(names []string, matrix [][]float64) (d [][]float64, err error) {
+	if len(names) == 0 {
+		err = errors.New("Distance matrix should not be empty")
+		return
+	}
+	if len(matrix) != len(names) {
+		err = fmt.Errorf("Distance matrix has %d rows while %d names are given", len(matrix), len(names))
+		return
+	}
+	d = make([][]float64, len(matrix))
+	for i, row := range matrix {
+		if len(row) != len(matrix) {
+			err = fmt.Errorf("Distance matrix is not square: row %d has %d columns", i, len(row))
+			return
+		}
+		d[i] = make([]float64, len(row))
+		copy(d[i], row)
+	}
+	for i := range d {
+		for j := 0; j < i; j++ {
+			if math.Abs(d[i][j]-d[j][i]) > 1e-6 {
+				err = fmt.Errorf("Distance matrix is not symmetric: d(%s,%s)=%f != d(%s,%s)=%f", names[i], names[j], d[i][j], names[j], names[i], d[j][i])
+				return
+			}
+		}
+	}
+	return
+}
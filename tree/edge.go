@@ -14,11 +14,12 @@ import (
 
 // Structure of an edge
 type Edge struct {
-	left, right *Node    // Left and right nodes
-	length      float64  // length of branch
-	comment     []string // Comment if any in the newick file
-	support     float64  // -1 if no support
-	pvalue      float64  // -1 if no pvalue
+	left, right *Node       // Left and right nodes
+	length      float64     // length of branch
+	comment     []string    // Comment if any in the newick file
+	attributes  *Attributes // Typed key/value attributes, nil if none
+	support     float64     // -1 if no support
+	pvalue      float64     // -1 if no pvalue
 	// a Bit at index i in the bitset corresponds to the position of the tip i
 	//left:0/right:1 .
 	// i is the index of the tip in the sorted tip name array
@@ -411,3 +412,34 @@ func (e *Edge) CommentsString() string {
 func (e *Edge) ClearComments() {
 	e.comment = e.comment[:0]
 }
+
+// Associates the given attribute value to the given key.
+// If the key already exists, its value is replaced.
+func (e *Edge) SetAttribute(key string, value Attribute) {
+	if e.attributes == nil {
+		e.attributes = NewAttributes()
+	}
+	e.attributes.Set(key, value)
+}
+
+// Returns the attribute associated to the given key, and false
+// if it does not exist.
+func (e *Edge) Attribute(key string) (Attribute, bool) {
+	return e.attributes.Get(key)
+}
+
+// Removes the attribute associated to the given key
+func (e *Edge) DelAttribute(key string) {
+	e.attributes.Delete(key)
+}
+
+// Returns the attributes of the edge. It may be nil if
+// no attribute has been set.
+func (e *Edge) Attributes() *Attributes {
+	return e.attributes
+}
+
+// Removes all attributes associated to the edge
+func (e *Edge) ClearAttributes() {
+	e.attributes = nil
+}
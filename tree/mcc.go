@@ -0,0 +1,203 @@
+package tree
+
+import (
+	"errors"
+	"math"
+
+	"github.com/evolbioinfo/gotree/hashmap"
+	"github.com/evolbioinfo/gotree/mutils"
+)
+
+// Key of a rooted clade in a hashmap: the clade is the set of
+// tips under the given edge (right side of the edge).
+//
+// Contrary to edges in an EdgeIndex, a clade and its complement
+// are different keys.
+type cladeKey struct {
+	e *Edge
+}
+
+// Number of occurences, heights and lengths of all the occurences
+// of a clade in the input trees
+type cladeStats struct {
+	count   int
+	heights []float64
+	lengths []float64
+}
+
+func (c *cladeKey) HashCode() uint64 {
+	return c.e.hashcoderight
+}
+
+func (c *cladeKey) HashEquals(h hashmap.Hasher) bool {
+	return c.e.bitset.Equal(h.(*cladeKey).e.bitset)
+}
+
+// Computes the Maximum Clade Credibility (MCC) tree from the trees given
+// in the input channel (e.g. a posterior sample of a Bayesian analysis).
+// Burn-in trees must already be removed from the input channel
+// (see utils.ReadMultiTreesSelection).
+//
+// Each clade (set of tips under a node, i.e. the bipartition defined by the
+// edge leading to the node, oriented from the root) has a posterior probability
+// equal to the proportion of the input trees in which it is present. The MCC tree
+// is the sampled tree maximizing the product of the posterior probabilities of its
+// clades. Its nodes are then annotated with the following attributes:
+//	* posterior: posterior probability of the clade (internal nodes only)
+//	* height, height_mean, height_median, height_95%_HPD: height of the node in the MCC tree,
+//	  and mean, median and 95% HPD interval of the heights of the clade over all trees
+//	  in which it is present. The height of a node is the distance between the
+//	  node and the farthest tip from the root, minus the distance between the root and the node
+//	* length, length_mean, length_median, length_95%_HPD: the same for the branch leading to
+//	  the node (if the trees have branch lengths)
+//
+// It returns the MCC tree, its index in the input channel, and its log clade credibility.
+//
+// There can be errors if:
+//	* There is no input tree
+//	* The tip names are different in the different trees
+func MaxCladeCredibility(trees <-chan Trees) (mcc *Tree, mccid int, lcc float64, err error) {
+	var alltrees []Trees
+	var nbtips int
+
+	// We store all the trees, since two passes are needed
+	alltrees = make([]Trees, 0, 100)
+	for curtree := range trees {
+		if curtree.Err != nil {
+			/* We empty the channel if needed */
+			for _ = range trees {
+			}
+			err = curtree.Err
+			return
+		}
+		alltrees = append(alltrees, curtree)
+	}
+
+	if len(alltrees) == 0 {
+		err = errors.New("No input tree")
+		return
+	}
+
+	// We fill the clade index with all the clades, their count,
+	// their heights and their lengths
+	clades := hashmap.NewHashMap(128, .75)
+	rootheights := make([]float64, 0, len(alltrees))
+	for i, curtree := range alltrees {
+		if err = curtree.Tree.ReinitIndexes(); err != nil {
+			return
+		}
+		if i == 0 {
+			nbtips = len(curtree.Tree.Tips())
+		} else {
+			// Compare tip names between first tree and current tree
+			names := curtree.Tree.AllTipNames()
+			if len(names) != nbtips {
+				err = errors.New("Trees do not have the same set of tips")
+				return
+			}
+			for _, name := range names {
+				if ok, err3 := alltrees[0].Tree.ExistsTip(name); err3 != nil {
+					err = err3
+					return
+				} else if !ok {
+					err = errors.New("Trees do not have the same set of tips")
+					return
+				}
+			}
+		}
+		heights := nodeHeights(curtree.Tree)
+		rootheights = append(rootheights, heights[curtree.Tree.Root()])
+		for _, e := range curtree.Tree.Edges() {
+			cs := cladeValue(clades, e)
+			cs.count++
+			cs.heights = append(cs.heights, heights[e.Right()])
+			if e.Length() != NIL_LENGTH {
+				cs.lengths = append(cs.lengths, e.Length())
+			}
+		}
+	}
+
+	// We search for the tree maximizing the product of clade posteriors
+	nbtrees := float64(len(alltrees))
+	lcc = math.Inf(-1)
+	for _, curtree := range alltrees {
+		score := 0.0
+		for _, e := range curtree.Tree.Edges() {
+			if !e.Right().Tip() {
+				score += math.Log(float64(cladeValue(clades, e).count) / nbtrees)
+			}
+		}
+		if score > lcc {
+			lcc = score
+			mcc = curtree.Tree
+			mccid = curtree.Id
+		}
+	}
+
+	// We annotate the MCC tree
+	heights := nodeHeights(mcc)
+	root := mcc.Root()
+	root.SetAttribute("posterior", NewFloatAttribute(1.0))
+	setSummaryAttributes(root, "height", heights[root], rootheights)
+	for _, e := range mcc.Edges() {
+		n := e.Right()
+		cs := cladeValue(clades, e)
+		if !n.Tip() {
+			n.SetAttribute("posterior", NewFloatAttribute(float64(cs.count)/nbtrees))
+		}
+		setSummaryAttributes(n, "height", heights[n], cs.heights)
+		if e.Length() != NIL_LENGTH && len(cs.lengths) > 0 {
+			setSummaryAttributes(n, "length", e.Length(), cs.lengths)
+		}
+	}
+	return
+}
+
+// Returns the statistics associated to the clade under the given
+// edge. If the clade is not in the index yet, it is added.
+func cladeValue(clades *hashmap.HashMap, e *Edge) (cs *cladeStats) {
+	key := &cladeKey{e}
+	if v, ok := clades.Value(key); ok {
+		cs = v.(*cladeStats)
+	} else {
+		cs = &cladeStats{0, make([]float64, 0), make([]float64, 0)}
+		clades.PutValue(key, cs)
+	}
+	return
+}
+
+// Sets the attributes <prefix>, <prefix>_mean, <prefix>_median and
+// <prefix>_95%_HPD to the given node
+func setSummaryAttributes(n *Node, prefix string, value float64, values []float64) {
+	lower, upper := mutils.HPD(values, 0.95)
+	n.SetAttribute(prefix, NewFloatAttribute(value))
+	n.SetAttribute(prefix+"_mean", NewFloatAttribute(mutils.Mean(values)))
+	n.SetAttribute(prefix+"_median", NewFloatAttribute(mutils.Median(values)))
+	n.SetAttribute(prefix+"_95%_HPD", NewFloatListAttribute(lower, upper))
+}
+
+// Returns the height of all the nodes of the tree: the distance
+// from the root to the farthest tip minus the distance from the
+// root to the node. Undefined branch lengths count as 0.
+func nodeHeights(t *Tree) (heights map[*Node]float64) {
+	heights = make(map[*Node]float64)
+	maxdist := 0.0
+	t.PreOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		dist := 0.0
+		if prev != nil {
+			dist = heights[prev]
+			if e.Length() != NIL_LENGTH {
+				dist += e.Length()
+			}
+		}
+		heights[cur] = dist
+		if dist > maxdist {
+			maxdist = dist
+		}
+		return true
+	})
+	for n, dist := range heights {
+		heights[n] = maxdist - dist
+	}
+	return
+}
@@ -10,13 +10,14 @@ import (
 
 // Node structure
 type Node struct {
-	name    string   // Name of the node
-	comment []string // Comment if any in the newick file
-	neigh   []*Node  // neighbors array
-	br      []*Edge  // Branches array (same order than neigh)
-	depth   int      // Depth of the node
-	id      int      // This field is used at discretion of the user to store information
-	tipid   int      // This is used by TipIndex to match tip names of different trees
+	name       string      // Name of the node
+	comment    []string    // Comment if any in the newick file
+	attributes *Attributes // Typed key/value attributes, nil if none
+	neigh      []*Node     // neighbors array
+	br         []*Edge     // Branches array (same order than neigh)
+	depth      int         // Depth of the node
+	id         int         // This field is used at discretion of the user to store information
+	tipid      int         // This is used by TipIndex to match tip names of different trees
 }
 
 // Uninitialized depth is coded as -1
@@ -67,6 +68,37 @@ func (n *Node) ClearComments() {
 	n.comment = n.comment[:0]
 }
 
+// Associates the given attribute value to the given key.
+// If the key already exists, its value is replaced.
+func (n *Node) SetAttribute(key string, value Attribute) {
+	if n.attributes == nil {
+		n.attributes = NewAttributes()
+	}
+	n.attributes.Set(key, value)
+}
+
+// Returns the attribute associated to the given key, and false
+// if it does not exist.
+func (n *Node) Attribute(key string) (Attribute, bool) {
+	return n.attributes.Get(key)
+}
+
+// Removes the attribute associated to the given key
+func (n *Node) DelAttribute(key string) {
+	n.attributes.Delete(key)
+}
+
+// Returns the attributes of the node. It may be nil if
+// no attribute has been set.
+func (n *Node) Attributes() *Attributes {
+	return n.attributes
+}
+
+// Removes all attributes associated to the node
+func (n *Node) ClearAttributes() {
+	n.attributes = nil
+}
+
 // Sets the depth of the node
 func (n *Node) SetDepth(depth int) {
 	n.depth = depth
@@ -229,6 +261,18 @@ func (n *Node) RotateNeighbors() {
 // Recursive function that outputs newick representation
 // from the current node
 func (n *Node) Newick(parent *Node, newick *bytes.Buffer) {
+	n.newick(parent, newick, false)
+}
+
+// Recursive function that outputs newick representation
+// from the current node, with attributes in the NHX format:
+// attributes of a node and of the edge leading to it are written
+// in a single [&&NHX:...] comment after the branch length.
+func (n *Node) NewickNHX(parent *Node, newick *bytes.Buffer) {
+	n.newick(parent, newick, true)
+}
+
+func (n *Node) newick(parent *Node, newick *bytes.Buffer, nhx bool) {
 	if len(n.neigh) > 0 {
 		if len(n.neigh) > 1 {
 			newick.WriteString("(")
@@ -239,13 +283,18 @@ func (n *Node) Newick(parent *Node, newick *bytes.Buffer) {
 				if nbchild > 0 {
 					newick.WriteString(",")
 				}
-				child.Newick(n, newick)
+				child.newick(n, newick, nhx)
 				if n.br[i].support != NIL_SUPPORT && child.Name() == "" {
 					newick.WriteString(strconv.FormatFloat(n.br[i].support, 'f', -1, 64))
 					if n.br[i].pvalue != NIL_PVALUE {
 						newick.WriteString(fmt.Sprintf("/%s", strconv.FormatFloat(n.br[i].pvalue, 'f', -1, 64)))
 					}
 				}
+				if !nhx && child.attributes.Len() != 0 {
+					newick.WriteString("[")
+					newick.WriteString(child.attributes.String())
+					newick.WriteString("]")
+				}
 				if len(child.comment) != 0 {
 					for _, c := range child.comment {
 						newick.WriteString("[")
@@ -257,6 +306,17 @@ func (n *Node) Newick(parent *Node, newick *bytes.Buffer) {
 					newick.WriteString(":")
 					newick.WriteString(strconv.FormatFloat(n.br[i].length, 'f', -1, 64))
 				}
+				if nhx {
+					if attrs := mergeAttributes(child.attributes, n.br[i].attributes); attrs != nil {
+						newick.WriteString("[")
+						newick.WriteString(attrs.NHXString())
+						newick.WriteString("]")
+					}
+				} else if n.br[i].attributes.Len() != 0 {
+					newick.WriteString("[")
+					newick.WriteString(n.br[i].attributes.String())
+					newick.WriteString("]")
+				}
 				if len(n.br[i].comment) != 0 {
 					for _, c := range n.br[i].comment {
 						newick.WriteString("[")
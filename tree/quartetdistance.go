@@ -0,0 +1,386 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Type for channel of quartet comparison statistics.
+//
+// Each quartet of tips {a,b,c,d} is either resolved (e.g. ab|cd)
+// or unresolved (star) in each tree.
+type QuartetStats struct {
+	Id           int   // Identifier of the compared tree
+	Common       int   // Number of quartets resolved the same way in both trees
+	Conflict     int   // Number of quartets resolved differently in both trees
+	RefResolved  int   // Number of quartets resolved in the reference tree and unresolved in the compared tree
+	CompResolved int   // Number of quartets resolved in the compared tree and unresolved in the reference tree
+	Unresolved   int   // Number of quartets unresolved in both trees
+	Err          error // Wether an error occured or not in the computation
+}
+
+// Quartet distance: number of quartets that are not resolved the same
+// way in both trees (Conflict + RefResolved + CompResolved)
+func (qs QuartetStats) Distance() int {
+	return qs.Conflict + qs.RefResolved + qs.CompResolved
+}
+
+// Total number of quartets
+func (qs QuartetStats) Total() int {
+	return qs.Common + qs.Conflict + qs.RefResolved + qs.CompResolved + qs.Unresolved
+}
+
+// This function compares the quartets of a reference tree with the quartets of a set of trees
+// given in the input channel, using QuartetDistance.
+//
+// This function returns almost immediately because computation is done in several go routines
+// in background. The returned channel is closed at the end of the computations.
+func CompareQuartets(refTree *Tree, compTrees <-chan Trees, cpus int) (<-chan QuartetStats, error) {
+	stats := make(chan QuartetStats)
+
+	if refTree == nil {
+		return nil, errors.New("Tree 1 in comparison is null")
+	}
+	if cpus < 1 {
+		cpus = 1
+	}
+
+	var wg sync.WaitGroup
+	for cpu := 0; cpu < cpus; cpu++ {
+		wg.Add(1)
+		go func(cpu int) {
+			for treeV := range compTrees {
+				var st QuartetStats
+				var inerr error = treeV.Err
+				if inerr == nil {
+					st, inerr = QuartetDistance(refTree, treeV.Tree)
+				}
+				st.Id = treeV.Id
+				st.Err = inerr
+				stats <- st
+			}
+			wg.Done()
+		}(cpu)
+	}
+
+	go func() {
+		wg.Wait()
+		close(stats)
+	}()
+
+	return stats, nil
+}
+
+// Compares the quartets of two trees having the same set of tips. Trees may be multifurcated.
+//
+// Instead of enumerating the O(n^4) quartets, it counts quartets
+// "claimed" by pairs of internal nodes (one in each tree), following the
+// approach of Christiansen et al., 2006 (Computing the quartet distance
+// between trees of arbitrary degree). A resolved quartet ab|cd is claimed
+// by the node of the tree where the path from a to b meets the path to c and d
+// (and conversely). The number of claims shared by two internal nodes is computed from
+// the number of tips shared by the subtrees around these two nodes.
+// For trees of bounded degree, the time complexity is O(n^2) and the memory is O(n log(n)).
+//
+// Returns an error if the trees do not have the same set of tips.
+func QuartetDistance(t1, t2 *Tree) (stats QuartetStats, err error) {
+	var qt1, qt2 *quartetTree
+	tipindex := make(map[string]int)
+
+	for i, tip := range t1.Tips() {
+		if _, ok := tipindex[tip.Name()]; ok {
+			err = fmt.Errorf("Tip %s is present several times in the tree", tip.Name())
+			return
+		}
+		tipindex[tip.Name()] = i
+	}
+	if qt1, err = newQuartetTree(t1, tipindex); err != nil {
+		return
+	}
+	if qt2, err = newQuartetTree(t2, tipindex); err != nil {
+		return
+	}
+
+	n := len(tipindex)
+	resolved1 := qt1.resolvedQuartets()
+	resolved2 := qt2.resolvedQuartets()
+
+	common2, conflict4 := 0, 0
+	qt1.claims(qt1.root, qt2, &common2, &conflict4)
+
+	stats.Common = common2 / 2
+	stats.Conflict = conflict4 / 4
+	stats.RefResolved = resolved1 - stats.Common - stats.Conflict
+	stats.CompResolved = resolved2 - stats.Common - stats.Conflict
+	stats.Unresolved = choose2(n) * choose2(n-2) / 6
+	stats.Unresolved -= stats.Common + stats.Conflict + stats.RefResolved + stats.CompResolved
+	return
+}
+
+// Rooted and indexed view of a tree used to compare quartets
+type quartetTree struct {
+	n        int     // Number of tips
+	root     int     // Index of the root node
+	parent   []int   // Index of the parent of each node (-1 for the root)
+	children [][]int // Children of each node, sorted by decreasing size
+	size     []int   // Number of tips under each node
+	tipnode  []int   // Node index of each tip (tips being indexed by tipindex)
+	tip      []int   // Tip index of each node (-1 for internal nodes)
+}
+
+func newQuartetTree(t *Tree, tipindex map[string]int) (qt *quartetTree, err error) {
+	var nodes []*Node
+	var ntips int
+
+	root := t.Root()
+	if root == nil {
+		err = errors.New("The tree has no root")
+		return
+	}
+	// The tree must be rooted on an internal node
+	if root.Tip() && root.Nneigh() > 0 {
+		root = root.Neigh()[0]
+	}
+	ids := make(map[*Node]int)
+	nodes = t.Nodes()
+	for i, n := range nodes {
+		ids[n] = i
+	}
+	qt = &quartetTree{
+		n:        len(tipindex),
+		root:     ids[root],
+		parent:   make([]int, len(nodes)),
+		children: make([][]int, len(nodes)),
+		size:     make([]int, len(nodes)),
+		tipnode:  make([]int, len(tipindex)),
+		tip:      make([]int, len(nodes)),
+	}
+	for i := range qt.tipnode {
+		qt.tipnode[i] = -1
+	}
+	if err = qt.fill(root, nil, ids, tipindex, &ntips); err != nil {
+		return
+	}
+	if ntips != len(tipindex) {
+		err = errors.New("Trees do not have the same number of tips")
+	}
+	return
+}
+
+// Recursively fills parents, children, sizes and tip indexes of the subtree
+// rooted at cur
+func (qt *quartetTree) fill(cur, prev *Node, ids map[*Node]int, tipindex map[string]int, ntips *int) (err error) {
+	id := ids[cur]
+	qt.parent[id] = -1
+	qt.tip[id] = -1
+	if prev != nil {
+		qt.parent[id] = ids[prev]
+	}
+	if cur.Tip() {
+		*ntips++
+		if tip, ok := tipindex[cur.Name()]; !ok || qt.tipnode[tip] != -1 {
+			return fmt.Errorf("Tip %s is not present in the reference tree or is present several times", cur.Name())
+		} else {
+			qt.tipnode[tip] = id
+			qt.tip[id] = tip
+			qt.size[id] = 1
+		}
+		return
+	}
+	qt.children[id] = make([]int, 0, len(cur.neigh))
+	for _, child := range cur.neigh {
+		if child != prev {
+			if err = qt.fill(child, cur, ids, tipindex, ntips); err != nil {
+				return
+			}
+			qt.children[id] = append(qt.children[id], ids[child])
+			qt.size[id] += qt.size[ids[child]]
+		}
+	}
+	sort.Slice(qt.children[id], func(i, j int) bool {
+		return qt.size[qt.children[id][i]] > qt.size[qt.children[id][j]]
+	})
+	return
+}
+
+// Number of resolved quartets of the tree
+func (qt *quartetTree) resolvedQuartets() int {
+	total := 0
+	for u := range qt.children {
+		sizes := qt.subtreeSizes(u)
+		if len(sizes) < 3 {
+			continue
+		}
+		samepairs := 0
+		for _, s := range sizes {
+			samepairs += choose2(s)
+		}
+		for _, s := range sizes {
+			// Pairs of tips in the subtree times pairs of tips in different other subtrees
+			total += choose2(s) * (choose2(qt.n-s) - (samepairs - choose2(s)))
+		}
+	}
+	// Each resolved quartet is claimed by 2 nodes
+	return total / 2
+}
+
+// Sizes of the subtrees around node u: children first, then
+// the parent side if u is not the root
+func (qt *quartetTree) subtreeSizes(u int) (sizes []int) {
+	sizes = make([]int, 0, len(qt.children[u])+1)
+	for _, c := range qt.children[u] {
+		sizes = append(sizes, qt.size[c])
+	}
+	if qt.parent[u] != -1 {
+		sizes = append(sizes, qt.n-qt.size[u])
+	}
+	return
+}
+
+// Post-order traversal of qt, returning for node u the number of tips
+// shared between the clade of u and the clade of each node of qt2.
+//
+// For each pair of internal nodes (u of qt, v of qt2), it adds to
+// common2 twice the number of common quartets claimed by u and v,
+// and to conflict4 four times the number of conflicting quartets
+// claimed by u and v.
+//
+// Children are visited by decreasing size, and the row of u is only
+// allocated once all its children are visited: rows are kept only for
+// the already visited children of the ancestors of the current node
+// whose current child is not the largest. For trees of bounded
+// degree, at most O(log(n)) rows are stored at the same time.
+func (qt *quartetTree) claims(u int, qt2 *quartetTree, common2, conflict4 *int) (row []int) {
+	if qt.tip[u] != -1 {
+		row = make([]int, len(qt2.parent))
+		for v := qt2.tipnode[qt.tip[u]]; v != -1; v = qt2.parent[v] {
+			row[v]++
+		}
+		return
+	}
+
+	childrows := make([][]int, len(qt.children[u]))
+	for i, c := range qt.children[u] {
+		childrows[i] = qt.claims(c, qt2, common2, conflict4)
+	}
+	row = make([]int, len(qt2.parent))
+	for _, childrow := range childrows {
+		for v, count := range childrow {
+			row[v] += count
+		}
+	}
+
+	sizes1 := qt.subtreeSizes(u)
+	if len(sizes1) < 3 {
+		return
+	}
+	for v := range qt2.children {
+		sizes2 := qt2.subtreeSizes(v)
+		if len(sizes2) < 3 {
+			continue
+		}
+		// Intersection matrix between subtrees around u and subtrees around v
+		m := make([][]int, len(sizes1))
+		for i := range m {
+			m[i] = make([]int, len(sizes2))
+			for j := range m[i] {
+				switch {
+				case i < len(childrows) && j < len(qt2.children[v]):
+					m[i][j] = childrows[i][qt2.children[v][j]]
+				case i < len(childrows):
+					m[i][j] = sizes1[i] - childrows[i][v]
+				case j < len(qt2.children[v]):
+					m[i][j] = sizes2[j] - row[qt2.children[v][j]]
+				default:
+					m[i][j] = qt.n - qt.size[u] - qt2.size[v] + row[v]
+				}
+			}
+		}
+		c2, d4 := quartetClaims(qt.n, sizes1, sizes2, m)
+		*common2 += c2
+		*conflict4 += d4
+	}
+	return
+}
+
+// Given the number of tips shared by the subtrees around two nodes (one in
+// each tree), returns:
+//	* common2: the number of pairs of identical claims ab|cd, with {a,b} in
+//	  different subtrees of both nodes, and {c,d} in the same subtree of both nodes
+//	* conflict4: the number of pairs of conflicting claims ab|cd (first tree)
+//	  and ac|bd (second tree)
+func quartetClaims(n int, sizes1, sizes2 []int, m [][]int) (common2, conflict4 int) {
+	d1, d2 := len(sizes1), len(sizes2)
+
+	// Pairs of tips in the same subtree of both nodes
+	total := 0
+	rowpairs := make([]int, d1)
+	colpairs := make([]int, d2)
+	for i := 0; i < d1; i++ {
+		for j := 0; j < d2; j++ {
+			p := choose2(m[i][j])
+			total += p
+			rowpairs[i] += p
+			colpairs[j] += p
+		}
+	}
+	// Pairs of tips in the same subtree of the node of tree 1 (resp. 2),
+	// outside subtree j (resp. i) of the node of tree 2 (resp. 1)
+	same1 := make([]int, d2)
+	same2 := make([]int, d1)
+	for j := 0; j < d2; j++ {
+		for i := 0; i < d1; i++ {
+			same1[j] += choose2(sizes1[i] - m[i][j])
+		}
+	}
+	for i := 0; i < d1; i++ {
+		for j := 0; j < d2; j++ {
+			same2[i] += choose2(sizes2[j] - m[i][j])
+		}
+	}
+
+	for i0 := 0; i0 < d1; i0++ {
+		for j0 := 0; j0 < d2; j0++ {
+			if m[i0][j0] == 0 {
+				continue
+			}
+			// Common claims: {c,d} in subtrees i0 and j0,
+			// {a,b} outside and in different subtrees
+			if m[i0][j0] > 1 {
+				x := n - sizes1[i0] - sizes2[j0] + m[i0][j0]
+				pairs := choose2(x)
+				pairs -= same1[j0] - choose2(sizes1[i0]-m[i0][j0])
+				pairs -= same2[i0] - choose2(sizes2[j0]-m[i0][j0])
+				pairs += total - rowpairs[i0] - colpairs[j0] + choose2(m[i0][j0])
+				common2 += choose2(m[i0][j0]) * pairs
+			}
+			// Conflicting claims: d in subtrees i0 and j0, a outside both,
+			// b in subtree j0 but not i0, c in subtree i0 but not j0
+			alpha := sizes2[j0] - m[i0][j0]
+			beta := sizes1[i0] - m[i0][j0]
+			sum := 0
+			for a1 := 0; a1 < d1; a1++ {
+				if a1 == i0 {
+					continue
+				}
+				for a2 := 0; a2 < d2; a2++ {
+					if a2 == j0 || m[a1][a2] == 0 {
+						continue
+					}
+					sum += m[a1][a2] * (alpha - m[a1][j0]) * (beta - m[i0][a2])
+				}
+			}
+			conflict4 += m[i0][j0] * sum
+		}
+	}
+	return
+}
+
+func choose2(n int) int {
+	if n < 2 {
+		return 0
+	}
+	return n * (n - 1) / 2
+}
@@ -232,3 +232,293 @@ func (n *nni) Undo() (err error) {
 
 	return
 }
+
+// SPRRearranger lists all Subtree Prune and Regraft (SPR) moves of a tree.
+//
+// For each edge, the subtree on each side of the edge is pruned and
+// regrafted on every other edge of the tree, at a distance of at most
+// Radius edges from the pruning point (Radius<=0: no limit).
+// With Radius=1, the moves correspond to NNIs.
+//
+// Several moves may give the same tree.
+// Applies only to binary trees: the pruning node must have 3 neighbors.
+type SPRRearranger struct {
+	Radius int
+}
+
+func (sprr *SPRRearranger) Rearrange(t *Tree, f func(r Rearrangement) bool) {
+	for _, e := range t.Edges() {
+		for _, p := range []*Node{e.Left(), e.Right()} {
+			if p.Nneigh() != 3 {
+				continue
+			}
+			for _, target := range regraftEdges(p, e, sprr.Radius) {
+				if !f(newSPR(t, p, e, target)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// TBRRearranger lists all Tree Bisection and Reconnection (TBR) moves of a tree.
+//
+// For each edge, the tree is bisected by removing the edge, and the two subtrees
+// are reconnected by a new edge between any edge of the first subtree and any
+// edge of the second subtree, at a distance of at most Radius edges from the
+// initial edge on each side (Radius<=0: no limit).
+//
+// TBR moves include SPR moves, and several moves may give the same tree.
+// Applies only to binary trees.
+type TBRRearranger struct {
+	Radius int
+}
+
+func (tbrr *TBRRearranger) Rearrange(t *Tree, f func(r Rearrangement) bool) {
+	for _, e := range t.Edges() {
+		var ltargets, rtargets []*Edge
+		// nil target: the side stays attached at the same place
+		if e.Left().Nneigh() == 3 {
+			ltargets = append([]*Edge{nil}, regraftEdges(e.Left(), e, tbrr.Radius)...)
+		} else {
+			ltargets = []*Edge{nil}
+		}
+		if e.Right().Nneigh() == 3 {
+			rtargets = append([]*Edge{nil}, regraftEdges(e.Right(), e, tbrr.Radius)...)
+		} else {
+			rtargets = []*Edge{nil}
+		}
+		for _, lt := range ltargets {
+			for _, rt := range rtargets {
+				if lt == nil && rt == nil {
+					continue
+				}
+				r := &tbr{}
+				if lt != nil {
+					r.spr1 = newSPR(t, e.Left(), e, lt)
+				}
+				if rt != nil {
+					r.spr2 = newSPR(t, e.Right(), e, rt)
+				}
+				if !f(r) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Returns all the edges on which the subtree connected to p by edge e
+// may be regrafted, at a distance of at most radius edges from p
+// (radius <= 0: no limit). The two other edges of p are not returned,
+// since regrafting on them would give the same tree.
+func regraftEdges(p *Node, e *Edge, radius int) (edges []*Edge) {
+	edges = make([]*Edge, 0)
+	for i, n := range p.neigh {
+		if p.br[i] != e {
+			regraftEdgesRecur(n, p, 1, radius, &edges)
+		}
+	}
+	return
+}
+
+func regraftEdgesRecur(cur, prev *Node, depth, radius int, edges *[]*Edge) {
+	if radius > 0 && depth > radius {
+		return
+	}
+	for i, n := range cur.neigh {
+		if n != prev {
+			*edges = append(*edges, cur.br[i])
+			regraftEdgesRecur(n, cur, depth+1, radius, edges)
+		}
+	}
+}
+
+// Applies only to binary trees
+type spr struct {
+	t *Tree
+	//          s                           s
+	//          |                           |
+	//          |ep                         |ep
+	//   e1     |    e2       et          e1|   e2
+	// n1-------p-------n2  x------y  => x--p-----y  n1-------n2
+	//
+	// p: pruning node, connected to the pruned subtree by ep
+	// e1, e2: other edges of p, connected to n1 and n2
+	// et: regraft edge, between x and y
+	p          *Node
+	ep         *Edge
+	n1, n2     *Node
+	e1, e2, et *Edge
+	x, y       *Node
+	// Lengths before applying the SPR
+	l1, l2, lt float64
+	// If the SPR has already been applied
+	applied bool
+}
+
+// Prunes the subtree connected to p by edge ep,
+// and regrafts it on edge et.
+// p must have 3 neighbors, and et must not be in
+// the pruned subtree, nor be connected to p.
+func newSPR(t *Tree, p *Node, ep *Edge, et *Edge) (s *spr) {
+	var n1, n2 *Node
+	var e1, e2 *Edge
+
+	for i, n := range p.neigh {
+		if p.br[i] != ep {
+			if n1 == nil {
+				n1, e1 = n, p.br[i]
+			} else {
+				n2, e2 = n, p.br[i]
+			}
+		}
+	}
+	s = &spr{
+		t: t, p: p, ep: ep,
+		n1: n1, n2: n2,
+		e1: e1, e2: e2, et: et,
+		x: et.Left(), y: et.Right(),
+		applied: false,
+	}
+	return
+}
+
+func (s *spr) Apply() (err error) {
+	if s.applied {
+		return
+	}
+	s.l1, s.l2, s.lt = s.e1.Length(), s.e2.Length(), s.et.Length()
+	if err = moveNode(s.p, s.e1, s.n1, s.e2, s.n2, s.et, s.x, s.y); err != nil {
+		return
+	}
+	if s.l1 != NIL_LENGTH && s.l2 != NIL_LENGTH {
+		s.e1.SetLength(s.l1 + s.l2)
+	}
+	if s.lt != NIL_LENGTH {
+		s.et.SetLength(s.lt / 2.0)
+		s.e2.SetLength(s.lt / 2.0)
+	} else {
+		s.e2.SetLength(NIL_LENGTH)
+	}
+	s.t.reorientEdges()
+	s.applied = true
+	return
+}
+
+func (s *spr) Undo() (err error) {
+	if !s.applied {
+		return
+	}
+	if err = moveNode(s.p, s.et, s.x, s.e2, s.y, s.e1, s.n1, s.n2); err != nil {
+		return
+	}
+	s.e1.SetLength(s.l1)
+	s.e2.SetLength(s.l2)
+	s.et.SetLength(s.lt)
+	s.t.reorientEdges()
+	s.applied = false
+	return
+}
+
+// Applies only to binary trees
+//
+// A TBR move is done by (at most) two SPR moves: the
+// first one moves the left node of the bisected edge,
+// the second one moves its right node.
+type tbr struct {
+	spr1, spr2 *spr
+}
+
+func (r *tbr) Apply() (err error) {
+	if r.spr1 != nil {
+		if err = r.spr1.Apply(); err != nil {
+			return
+		}
+	}
+	if r.spr2 != nil {
+		err = r.spr2.Apply()
+	}
+	return
+}
+
+func (r *tbr) Undo() (err error) {
+	if r.spr2 != nil {
+		if err = r.spr2.Undo(); err != nil {
+			return
+		}
+	}
+	if r.spr1 != nil {
+		err = r.spr1.Undo()
+	}
+	return
+}
+
+// Moves node p from its position between n1 and n2
+// (edges e1 and e2) to edge et, between x and y:
+//	* e1 now connects n1 and n2
+//	* et now connects x and p
+//	* e2 now connects p and y
+// Nodes and edges keep their index in the neighbor lists,
+// so that the move can be undone exactly by
+// moveNode(p, et, x, e2, y, e1, n1, n2).
+// Edge orientations are not updated.
+func moveNode(p *Node, e1 *Edge, n1 *Node, e2 *Edge, n2 *Node, et *Edge, x, y *Node) (err error) {
+	var pi1, pi2, n1i, n2i, xi, yi int
+
+	if pi1, err = p.EdgeIndex(e1); err != nil {
+		return
+	}
+	if pi2, err = p.EdgeIndex(e2); err != nil {
+		return
+	}
+	if n1i, err = n1.EdgeIndex(e1); err != nil {
+		return
+	}
+	if n2i, err = n2.EdgeIndex(e2); err != nil {
+		return
+	}
+	if xi, err = x.EdgeIndex(et); err != nil {
+		return
+	}
+	if yi, err = y.EdgeIndex(et); err != nil {
+		return
+	}
+
+	// Pruning: n1 and n2 are connected by e1
+	n1.neigh[n1i] = n2
+	n2.neigh[n2i] = n1
+	n2.br[n2i] = e1
+	replaceEdgeNode(e1, p, n2)
+
+	// Regrafting: p is inserted between x and y
+	x.neigh[xi] = p
+	y.neigh[yi] = p
+	y.br[yi] = e2
+	replaceEdgeNode(et, y, p)
+	replaceEdgeNode(e2, n2, y)
+	p.neigh[pi1] = x
+	p.br[pi1] = et
+	p.neigh[pi2] = y
+	return
+}
+
+// Replaces node n by node by in the extremities of edge e
+func replaceEdgeNode(e *Edge, n, by *Node) {
+	if e.left == n {
+		e.left = by
+	} else {
+		e.right = by
+	}
+}
+
+// Orients all the edges of the tree from the root:
+// the left node of each edge is the closest to the root.
+func (t *Tree) reorientEdges() {
+	t.PreOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		if e != nil && e.left != prev {
+			e.Inverse()
+		}
+		return true
+	})
+}
@@ -0,0 +1,294 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Events of gene tree internal nodes, set in their "event" attribute
+const (
+	RECONCILE_SPECIATION  = "speciation"
+	RECONCILE_DUPLICATION = "duplication"
+)
+
+// Duplications and losses on the branch of the species tree
+// leading to a species tree node
+type ReconciliationBranch struct {
+	Node         *Node    // Species tree node
+	Species      []string // Species under this node
+	Duplications int
+	Losses       int
+}
+
+// Result of the reconciliation of a gene tree with a species tree
+type Reconciliation struct {
+	Duplications int
+	Losses       int
+	// Branches of the species tree, in pre-order:
+	// Branches[0] is the root (the branch above it)
+	Branches []ReconciliationBranch
+}
+
+// Rooted binary species tree indexed in pre-order
+type speciesTree struct {
+	nodes   []*Node
+	parent  []int
+	depth   []int
+	sibling []int          // Other child of the parent, -1 for the root
+	tips    map[string]int // Index of each species
+}
+
+// Mapping of a (sub)tree of the gene tree on the species tree
+type reconcileInfo struct {
+	species      int // Index of the species tree node
+	duplication  bool
+	duplications int
+	losses       int
+}
+
+func newSpeciesTree(st *Tree) (s *speciesTree, err error) {
+	index := make(map[*Node]int)
+	s = &speciesTree{tips: make(map[string]int)}
+	if !st.Rooted() {
+		err = errors.New("The species tree must be rooted")
+		return
+	}
+	st.PreOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		i := len(s.nodes)
+		index[cur] = i
+		s.nodes = append(s.nodes, cur)
+		s.sibling = append(s.sibling, -1)
+		if prev == nil {
+			s.parent = append(s.parent, -1)
+			s.depth = append(s.depth, 0)
+		} else {
+			s.parent = append(s.parent, index[prev])
+			s.depth = append(s.depth, s.depth[index[prev]]+1)
+		}
+		if cur.Tip() {
+			if _, ok := s.tips[cur.Name()]; ok {
+				err = fmt.Errorf("Species %s is present several times in the species tree", cur.Name())
+				return false
+			}
+			s.tips[cur.Name()] = i
+		} else if (prev == nil && cur.Nneigh() != 2) || (prev != nil && cur.Nneigh() != 3) {
+			err = errors.New("The species tree must be binary")
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return
+	}
+	// Other child of the parent of each node
+	children := make([][]int, len(s.nodes))
+	for i := 1; i < len(s.nodes); i++ {
+		children[s.parent[i]] = append(children[s.parent[i]], i)
+	}
+	for _, c := range children {
+		if len(c) == 2 {
+			s.sibling[c[0]], s.sibling[c[1]] = c[1], c[0]
+		}
+	}
+	return
+}
+
+func (s *speciesTree) lca(a, b int) int {
+	for a != b {
+		if s.depth[a] >= s.depth[b] {
+			a = s.parent[a]
+		} else {
+			b = s.parent[b]
+		}
+	}
+	return a
+}
+
+// Species tree node of a gene tree tip
+func (s *speciesTree) tipSpecies(tip *Node, species map[string]string) (i int, err error) {
+	sp, ok := species[tip.Name()]
+	if !ok {
+		err = fmt.Errorf("Gene %s has no species", tip.Name())
+		return
+	}
+	if i, ok = s.tips[sp]; !ok {
+		err = fmt.Errorf("Species %s of gene %s is not in the species tree", sp, tip.Name())
+	}
+	return
+}
+
+// LCA mapping of a gene tree node, given the mapping of its 2 children:
+// the node is a duplication if it is mapped on the same species
+// tree node as one of its children.
+func (s *speciesTree) combine(c1, c2 reconcileInfo) (r reconcileInfo) {
+	r.species = s.lca(c1.species, c2.species)
+	r.duplication = r.species == c1.species || r.species == c2.species
+	r.duplications = c1.duplications + c2.duplications
+	r.losses = c1.losses + c2.losses
+	for _, c := range []reconcileInfo{c1, c2} {
+		r.losses += s.depth[c.species] - s.depth[r.species] - 1
+		if r.duplication {
+			r.losses++
+		}
+	}
+	if r.duplication {
+		r.duplications++
+	}
+	return
+}
+
+// Maps the subtree of the gene tree rooted at cur (coming from prev) on the species tree.
+// Results are memoized by oriented edge.
+func (s *speciesTree) mapSubtree(cur, prev *Node, species map[string]string, memo map[[2]*Node]reconcileInfo) (r reconcileInfo, err error) {
+	var ok bool
+	var children []reconcileInfo
+
+	if r, ok = memo[[2]*Node{prev, cur}]; ok {
+		return
+	}
+	if cur.Tip() {
+		r.species, err = s.tipSpecies(cur, species)
+	} else {
+		for _, n := range cur.Neigh() {
+			if n == prev {
+				continue
+			}
+			var c reconcileInfo
+			if c, err = s.mapSubtree(n, cur, species, memo); err != nil {
+				return
+			}
+			children = append(children, c)
+		}
+		if len(children) != 2 {
+			err = errors.New("The gene tree must be binary")
+			return
+		}
+		r = s.combine(children[0], children[1])
+	}
+	memo[[2]*Node{prev, cur}] = r
+	return
+}
+
+// Reconciles the (rooted, binary) gene tree with the given (rooted, binary) species tree,
+// using the LCA mapping: each gene tree node is mapped on the LCA in the species tree
+// of the species of its tips. An internal node mapped on the same species tree node as
+// one of its children is a duplication, otherwise it is a speciation.
+//
+// species gives the species of each gene tree tip.
+//
+// Each internal node of the gene tree gets an "event" attribute (RECONCILE_SPECIATION or
+// RECONCILE_DUPLICATION), and a "species" attribute with the name of the species tree node
+// on which it is mapped (if it has a name).
+//
+// Duplications are located on the branch of the species tree leading to the node where they
+// are mapped, and losses on the branches leading to the species that are skipped by gene
+// tree branches.
+func (t *Tree) Reconcile(st *Tree, species map[string]string) (rec *Reconciliation, err error) {
+	var s *speciesTree
+	var info map[*Node]reconcileInfo
+
+	if !t.Rooted() {
+		err = errors.New("The gene tree must be rooted")
+		return
+	}
+	if s, err = newSpeciesTree(st); err != nil {
+		return
+	}
+
+	rec = &Reconciliation{Branches: make([]ReconciliationBranch, len(s.nodes))}
+	for i, n := range s.nodes {
+		rec.Branches[i].Node = n
+		if n.Tip() {
+			for j := i; j >= 0; j = s.parent[j] {
+				rec.Branches[j].Species = append(rec.Branches[j].Species, n.Name())
+			}
+		}
+	}
+
+	info = make(map[*Node]reconcileInfo)
+	memo := make(map[[2]*Node]reconcileInfo)
+	t.PreOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		var r reconcileInfo
+		if r, err = s.mapSubtree(cur, prev, species, memo); err != nil {
+			return false
+		}
+		info[cur] = r
+		return true
+	})
+	if err != nil {
+		return
+	}
+
+	t.PreOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		r := info[cur]
+		if name := s.nodes[r.species].Name(); name != "" {
+			cur.SetAttribute("species", NewStringAttribute(name))
+		}
+		if cur.Tip() {
+			return true
+		}
+		if r.duplication {
+			cur.SetAttribute("event", NewStringAttribute(RECONCILE_DUPLICATION))
+			rec.Branches[r.species].Duplications++
+			rec.Duplications++
+		} else {
+			cur.SetAttribute("event", NewStringAttribute(RECONCILE_SPECIATION))
+		}
+		for _, child := range cur.Neigh() {
+			if child == prev {
+				continue
+			}
+			// Lost lineages: siblings of the species tree nodes
+			// between the child mapping and the node mapping
+			for j := info[child].species; j != r.species; j = s.parent[j] {
+				if s.parent[j] != r.species || r.duplication {
+					rec.Branches[s.sibling[j]].Losses++
+					rec.Losses++
+				}
+			}
+		}
+		return true
+	})
+	return
+}
+
+// Roots the gene tree at the position minimizing the number of duplications
+// (and then the number of losses) of its reconciliation with the given species tree.
+// If the tree is rooted, it is first unrooted. The root is placed at the middle
+// of the best branch.
+//
+// All the rootings are evaluated in linear time.
+func (t *Tree) RootDuplications(st *Tree, species map[string]string) (err error) {
+	var s *speciesTree
+	var best *Edge
+	var bestr reconcileInfo
+
+	if s, err = newSpeciesTree(st); err != nil {
+		return
+	}
+	t.UnRoot()
+	memo := make(map[[2]*Node]reconcileInfo)
+	for _, e := range t.Edges() {
+		var r1, r2 reconcileInfo
+		if r1, err = s.mapSubtree(e.Left(), e.Right(), species, memo); err != nil {
+			return
+		}
+		if r2, err = s.mapSubtree(e.Right(), e.Left(), species, memo); err != nil {
+			return
+		}
+		r := s.combine(r1, r2)
+		if best == nil || r.duplications < bestr.duplications ||
+			(r.duplications == bestr.duplications && r.losses < bestr.losses) {
+			best, bestr = e, r
+		}
+	}
+	if best == nil {
+		return errors.New("The gene tree has no branch")
+	}
+	dist := best.Length()
+	if dist != NIL_LENGTH {
+		dist = math.Max(0, dist) / 2
+	}
+	return t.rerootOnEdge(best, dist)
+}
@@ -0,0 +1,262 @@
+package tree
+
+import (
+	"errors"
+	"math"
+)
+
+// Score of the best root position on a branch of an unrooted tree
+type RootScore struct {
+	Id        int     // Index of the branch in the unrooted tree (order of Tree.Edges())
+	Length    float64 // Length of the branch
+	RightTips int     // Number of tips on the right side of the branch
+	RightName string  // Name of the right node of the branch
+	Position  float64 // Best position of the root: distance from the right node
+	Score     float64 // Score of the root at this position (the lower, the better)
+}
+
+// Unrooted tree indexed in pre-order, used to score root positions
+type rootingTree struct {
+	nodes  []*Node
+	edges  []*Edge   // Edge from the parent of each node
+	parent []int     // Parent of each node, -1 for the root
+	dist   []float64 // Distance from the root
+	level  []int     // Number of branches from the root
+	ntips  []int     // Number of tips under each node
+	tips   []int     // Indices of the tips
+}
+
+func newRootingTree(t *Tree) (r *rootingTree, err error) {
+	index := make(map[*Node]int)
+	r = &rootingTree{}
+	t.PreOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		i := len(r.nodes)
+		index[cur] = i
+		r.nodes = append(r.nodes, cur)
+		r.edges = append(r.edges, e)
+		r.ntips = append(r.ntips, 0)
+		if prev == nil {
+			r.parent = append(r.parent, -1)
+			r.dist = append(r.dist, 0)
+			r.level = append(r.level, 0)
+		} else {
+			if e.Length() == NIL_LENGTH {
+				err = errors.New("Rerooting requires branch lengths")
+				return false
+			}
+			p := index[prev]
+			r.parent = append(r.parent, p)
+			r.dist = append(r.dist, r.dist[p]+e.Length())
+			r.level = append(r.level, r.level[p]+1)
+		}
+		if cur.Tip() {
+			r.tips = append(r.tips, i)
+		}
+		return true
+	})
+	if err != nil {
+		return
+	}
+	if len(r.tips) < 3 {
+		err = errors.New("Rerooting requires at least 3 tips")
+		return
+	}
+	for i := len(r.nodes) - 1; i > 0; i-- {
+		if r.nodes[i].Tip() {
+			r.ntips[i]++
+		}
+		r.ntips[r.parent[i]] += r.ntips[i]
+	}
+	return
+}
+
+// Initializes the score of the branch from the parent of node i
+func (r *rootingTree) rootScore(id, i int, position, score float64) RootScore {
+	return RootScore{
+		Id:        id,
+		Length:    r.edges[i].Length(),
+		RightTips: r.ntips[i],
+		RightName: r.nodes[i].Name(),
+		Position:  position,
+		Score:     score,
+	}
+}
+
+// Reroots the tree using the Minimal Ancestor Deviation method (MAD, Tria et al., 2017).
+//
+// For a root position, the ancestor deviation of a pair of tips b and c, whose LCA is a,
+// is 2*d(b,a)/d(b,c) - 1: it is 0 if the LCA is at equal distance from both tips.
+// The score of a root position is the root mean square of the deviations of all pairs
+// of tips. For each branch, the best root position is computed analytically, and the
+// root is placed at the best position over all branches.
+//
+// Returns the score of all the branches of the unrooted tree, and the root ambiguity index:
+// the ratio of the best score to the second best score. The closer it is to 1, the more
+// ambiguous the root.
+//
+// Runs in O(n² * h), n being the number of tips and h the mean number of branches
+// between pairs of tips.
+func (t *Tree) RerootMAD() (scores []RootScore, ambiguity float64, err error) {
+	var r *rootingTree
+
+	t.UnRoot()
+	if r, err = newRootingTree(t); err != nil {
+		return
+	}
+	n := len(r.nodes)
+
+	// Sum of the squared deviations of pairs of tips
+	// whose path goes through each internal node (total),
+	// through its parent (up), and through the branch
+	// from its parent (child, stored in the child)
+	total := make([]float64, n)
+	up := make([]float64, n)
+	child := make([]float64, n)
+	// Coefficients of the sum of the squared deviations of pairs of tips
+	// whose path goes through the branch from the parent of each node,
+	// as a function of the root position on this branch: a2 + 2*ab*x + b2*x²
+	a2 := make([]float64, n)
+	ab := make([]float64, n)
+	b2 := make([]float64, n)
+	npairs := 0.0
+
+	for k, b := range r.tips {
+		for _, c := range r.tips[k+1:] {
+			lca := r.lca(b, c)
+			d := r.dist[b] + r.dist[c] - 2*r.dist[lca]
+			if d <= 0 {
+				continue
+			}
+			npairs++
+			var lastb, lastc int
+			for s, tip := range []int{b, c} {
+				u := tip
+				for u != lca {
+					// Branch from the parent of u: x is the distance from the tip
+					x := r.dist[tip] - r.dist[u]
+					alpha, beta := (2*x-d)/d, 2/d
+					a2[u] += alpha * alpha
+					ab[u] += alpha * beta
+					b2[u] += beta * beta
+					p := r.parent[u]
+					if p != lca {
+						dev := (2*(r.dist[tip]-r.dist[p]) - d) / d
+						total[p] += dev * dev
+						child[u] += dev * dev
+						up[p] += dev * dev
+					}
+					if s == 0 {
+						lastb = u
+					} else {
+						lastc = u
+					}
+					u = p
+				}
+			}
+			dev := (2*(r.dist[b]-r.dist[lca]) - d) / d
+			total[lca] += dev * dev
+			child[lastb] += dev * dev
+			child[lastc] += dev * dev
+		}
+	}
+	if npairs == 0 {
+		err = errors.New("All tips are at distance 0")
+		return
+	}
+
+	// Sum of the deviations of pairs whose path does not go through the root branch,
+	// for a root on the branch from the parent of each node: sum over all nodes
+	// of the pairs whose path goes through the node but not in the direction of the root
+	var sumup float64
+	for i := 1; i < n; i++ {
+		sumup += total[i] - up[i]
+	}
+	others := make([]float64, n)
+	for i := 1; i < n; i++ {
+		p := r.parent[i]
+		others[i] = others[p] + total[p] - child[i]
+		if p != 0 {
+			others[i] -= total[p] - up[p]
+		}
+	}
+
+	best, second := -1, -1
+	for i := 1; i < n; i++ {
+		l := math.Max(0, r.edges[i].Length())
+		x := 0.0
+		if b2[i] > 0 {
+			x = math.Min(l, math.Max(0, -ab[i]/b2[i]))
+		}
+		score := math.Sqrt(math.Max(0, sumup+others[i]+a2[i]+2*ab[i]*x+b2[i]*x*x) / npairs)
+		scores = append(scores, r.rootScore(i-1, i, x, score))
+		if best < 0 || score < scores[best].Score {
+			best, second = i-1, best
+		} else if second < 0 || score < scores[second].Score {
+			second = i - 1
+		}
+	}
+	if scores[second].Score > 0 {
+		ambiguity = scores[best].Score / scores[second].Score
+	}
+	err = t.rerootOnEdge(r.edges[best+1], scores[best].Position)
+	return
+}
+
+// Reroots the tree using the Minimum Variance method (MinVar, Mai et al., 2017):
+// the root is placed at the position that minimizes the variance of
+// root-to-tip distances. For each branch, the best root position is
+// computed analytically, and all branches are evaluated in linear time.
+//
+// Returns the score (variance of root-to-tip distances) of all the
+// branches of the unrooted tree.
+func (t *Tree) RerootMinVar() (scores []RootScore, err error) {
+	var r *rootingTree
+	var below, above map[*Node]temporalSums
+
+	t.UnRoot()
+	if r, err = newRootingTree(t); err != nil {
+		return
+	}
+
+	// Sums of root-to-tip distances and of their squares,
+	// as in root-to-tip regressions with identical dates
+	below = make(map[*Node]temporalSums)
+	temporalSumsBelow(t.Root(), nil, nil, 0, below)
+	above = make(map[*Node]temporalSums)
+	temporalSumsAbove(t.Root(), nil, nil, 0, below, above)
+
+	best := -1
+	for i := 1; i < len(r.nodes); i++ {
+		lower, upper := below[r.nodes[i]], above[r.nodes[i]]
+		l := math.Max(0, r.edges[i].Length())
+		n := lower.n + upper.n
+		// Distances are d+x under the node, and d-x above the node
+		sc, ss := lower.d+upper.d, lower.n-upper.n
+		scc := lower.dd + upper.dd - sc*sc/n
+		scs := lower.d - upper.d - sc*ss/n
+		sss := n - ss*ss/n
+		x := 0.0
+		if sss > 0 {
+			x = math.Min(l, math.Max(0, -scs/sss))
+		}
+		score := math.Max(0, scc+2*x*scs+x*x*sss) / n
+		scores = append(scores, r.rootScore(i-1, i, x, score))
+		if best < 0 || score < scores[best].Score {
+			best = i - 1
+		}
+	}
+	err = t.rerootOnEdge(r.edges[best+1], scores[best].Position)
+	return
+}
+
+// LCA of two nodes, the tree being rooted at its current root
+func (r *rootingTree) lca(b, c int) int {
+	for b != c {
+		if r.level[b] >= r.level[c] {
+			b = r.parent[b]
+		} else {
+			c = r.parent[c]
+		}
+	}
+	return b
+}
@@ -0,0 +1,330 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Criteria used to choose the root position maximizing the temporal signal
+const (
+	TEMPORAL_CORRELATION = iota // Maximizes the correlation between root-to-tip distances and dates
+	TEMPORAL_R2                 // Maximizes the coefficient of determination (R²)
+	TEMPORAL_RMS                // Minimizes the residual mean square
+)
+
+// Result of the regression of root-to-tip distances against tip dates
+type TemporalSignal struct {
+	Rate        float64            // Slope of the regression: substitution rate per unit of time
+	Intercept   float64            // Root-to-tip distance at date 0
+	TMRCA       float64            // Date of the root: x-intercept of the regression (-Intercept/Rate)
+	Correlation float64            // Pearson correlation coefficient between distances and dates
+	R2          float64            // Coefficient of determination
+	RMS         float64            // Residual mean square: residual sum of squares / (number of tips - 2)
+	Dates       map[string]float64 // Date of each tip
+	Distances   map[string]float64 // Root-to-tip distance of each tip
+	Residuals   map[string]float64 // Residual of each tip: distance - (Intercept + Rate * date)
+}
+
+// Sums over a set of tips, used to compute the regression
+// for any root position in linear time:
+// number of tips, sum of distances d, of d², of d*date, of dates, and of dates²
+type temporalSums struct {
+	n, d, dd, dt, t, tt float64
+}
+
+// Returns the sums with all distances increased by delta
+func (s temporalSums) shift(delta float64) temporalSums {
+	return temporalSums{
+		n:  s.n,
+		d:  s.d + s.n*delta,
+		dd: s.dd + 2*delta*s.d + s.n*delta*delta,
+		dt: s.dt + delta*s.t,
+		t:  s.t,
+		tt: s.tt,
+	}
+}
+
+func (s temporalSums) add(s2 temporalSums) temporalSums {
+	return temporalSums{s.n + s2.n, s.d + s2.d, s.dd + s2.dd, s.dt + s2.dt, s.t + s2.t, s.tt + s2.tt}
+}
+
+// Computes the linear regression of root-to-tip distances against
+// the given tip dates, using the current root of the tree.
+//
+// All the tips of the tree must have a date, and branch lengths.
+func (t *Tree) RootToTipRegression(dates map[string]float64) (*TemporalSignal, error) {
+	var sd, st, sdd, stt, sdt, n float64
+
+	if err := checkTemporalTree(t, dates); err != nil {
+		return nil, err
+	}
+	res := &TemporalSignal{
+		Dates:     make(map[string]float64),
+		Distances: make(map[string]float64),
+		Residuals: make(map[string]float64),
+	}
+	dists := make(map[*Node]float64)
+	t.PreOrder(func(cur *Node, prev *Node, e *Edge) (keep bool) {
+		if prev != nil {
+			dists[cur] = dists[prev] + e.Length()
+		}
+		if cur.Tip() {
+			res.Distances[cur.Name()] = dists[cur]
+			res.Dates[cur.Name()] = dates[cur.Name()]
+		}
+		return true
+	})
+
+	// Dates are centered to avoid numerical issues
+	mean := 0.0
+	for _, d := range res.Dates {
+		mean += d
+	}
+	mean /= float64(len(res.Dates))
+	for name, d := range res.Distances {
+		date := res.Dates[name] - mean
+		n++
+		sd += d
+		st += date
+		sdd += d * d
+		stt += date * date
+		sdt += d * date
+	}
+	stt -= st * st / n
+	sdd -= sd * sd / n
+	sdt -= sd * st / n
+	if stt <= 0 {
+		return nil, errors.New("All tip dates are identical, cannot compute a root-to-tip regression")
+	}
+
+	res.Rate = sdt / stt
+	res.Intercept = sd/n - res.Rate*mean
+	res.TMRCA = -res.Intercept / res.Rate
+	if sdd > 0 {
+		res.Correlation = sdt / math.Sqrt(sdd*stt)
+	}
+	res.R2 = res.Correlation * res.Correlation
+	rss := 0.0
+	for name, d := range res.Distances {
+		r := d - (res.Intercept + res.Rate*res.Dates[name])
+		res.Residuals[name] = r
+		rss += r * r
+	}
+	res.RMS = rss / (n - 2)
+	return res, nil
+}
+
+// Reroots the tree at the position that maximizes the temporal signal, as in TempEst:
+// the root is placed on the branch, and at the position on this branch, that maximizes
+// the correlation (TEMPORAL_CORRELATION) or the R² (TEMPORAL_R2) of the regression of
+// root-to-tip distances against tip dates, or that minimizes its residual mean square
+// (TEMPORAL_RMS).
+//
+// As root-to-tip distances are linear functions of the position of the root on a branch,
+// the optimal position on each branch is computed analytically, and all branches
+// are evaluated in linear time.
+//
+// All the tips of the tree must have a date, and branch lengths. Returns the
+// regression computed on the rerooted tree.
+func (t *Tree) RerootTemporal(dates map[string]float64, criterion int) (*TemporalSignal, error) {
+	var below, above map[*Node]temporalSums
+	var bestedge *Edge
+	var bestpos float64
+	var bestscore float64 = math.Inf(-1)
+
+	if criterion != TEMPORAL_CORRELATION && criterion != TEMPORAL_R2 && criterion != TEMPORAL_RMS {
+		return nil, fmt.Errorf("Unknown temporal rooting criterion %d", criterion)
+	}
+	if err := checkTemporalTree(t, dates); err != nil {
+		return nil, err
+	}
+	t.UnRoot()
+
+	mean := 0.0
+	for _, tip := range t.Tips() {
+		mean += dates[tip.Name()]
+	}
+	mean /= float64(len(t.Tips()))
+
+	// Sums over the tips under each node, with distances from that node
+	below = make(map[*Node]temporalSums)
+	temporalSumsBelow(t.Root(), nil, dates, mean, below)
+	// Sums over the tips outside the subtree of each node, with distances from that node
+	above = make(map[*Node]temporalSums)
+	temporalSumsAbove(t.Root(), nil, dates, mean, below, above)
+
+	for _, e := range t.Edges() {
+		pos, score := temporalBestPosition(below[e.Right()], above[e.Right()], e.Length(), criterion)
+		if score > bestscore {
+			bestscore = score
+			bestedge = e
+			bestpos = pos
+		}
+	}
+	if bestedge == nil || math.IsInf(bestscore, -1) || math.IsNaN(bestscore) {
+		return nil, errors.New("All tip dates are identical, cannot find the root position")
+	}
+	if err := t.rerootOnEdge(bestedge, bestpos); err != nil {
+		return nil, err
+	}
+	return t.RootToTipRegression(dates)
+}
+
+// Checks that all tips have a date, that all branches have a length,
+// and that there are enough tips to compute the regression
+func checkTemporalTree(t *Tree, dates map[string]float64) error {
+	tips := t.Tips()
+	if len(tips) < 3 {
+		return errors.New("Root-to-tip regression requires at least 3 tips")
+	}
+	for _, tip := range tips {
+		if _, ok := dates[tip.Name()]; !ok {
+			return fmt.Errorf("Tip %s has no date", tip.Name())
+		}
+	}
+	for _, e := range t.Edges() {
+		if e.Length() == NIL_LENGTH {
+			return errors.New("Root-to-tip regression requires branch lengths")
+		}
+	}
+	return nil
+}
+
+// Sums over a single tip
+func temporalTipSums(tip *Node, dates map[string]float64, mean float64) temporalSums {
+	d := dates[tip.Name()] - mean
+	return temporalSums{n: 1, t: d, tt: d * d}
+}
+
+func temporalSumsBelow(cur, prev *Node, dates map[string]float64, mean float64, below map[*Node]temporalSums) {
+	var s temporalSums
+	if cur.Tip() {
+		s = temporalTipSums(cur, dates, mean)
+	}
+	for i, n := range cur.neigh {
+		if n != prev {
+			temporalSumsBelow(n, cur, dates, mean, below)
+			s = s.add(below[n].shift(cur.br[i].Length()))
+		}
+	}
+	below[cur] = s
+}
+
+func temporalSumsAbove(cur, prev *Node, dates map[string]float64, mean float64, below, above map[*Node]temporalSums) {
+	for i, n := range cur.neigh {
+		if n == prev {
+			continue
+		}
+		s := above[cur]
+		if cur.Tip() {
+			s = s.add(temporalTipSums(cur, dates, mean))
+		}
+		for j, n2 := range cur.neigh {
+			if n2 != prev && n2 != n {
+				s = s.add(below[n2].shift(cur.br[j].Length()))
+			}
+		}
+		above[n] = s.shift(cur.br[i].Length())
+		temporalSumsAbove(n, cur, dates, mean, below, above)
+	}
+}
+
+// Computes the best position of the root on a branch of length l, given the sums over
+// the tips under its lower node (distances from that node), and over the other tips
+// (distances from the same node). The root at distance x from the lower node gives
+// distances d+x for the former, and d-x for the latter.
+//
+// Returns the distance of the best position from the lower node, and its score
+// (the higher, the better).
+func temporalBestPosition(lower, upper temporalSums, l float64, criterion int) (pos, score float64) {
+	l = math.Max(0, l)
+	n := lower.n + upper.n
+	// Sums of c (distance at x=0), s (+1/-1) and dates, and of their products
+	sc, st, ss := lower.d+upper.d, lower.t+upper.t, lower.n-upper.n
+	scc := lower.dd + upper.dd - sc*sc/n
+	sct := lower.dt + upper.dt - sc*st/n
+	scs := lower.d - upper.d - sc*ss/n
+	sss := n - ss*ss/n
+	sst := lower.t - upper.t - ss*st/n
+	stt := lower.tt + upper.tt - st*st/n
+	if stt <= 0 {
+		return 0, math.NaN()
+	}
+
+	// Score at a given position
+	eval := func(x float64) float64 {
+		sdd := scc + 2*x*scs + x*x*sss
+		sdt := sct + x*sst
+		switch criterion {
+		case TEMPORAL_RMS:
+			return -(sdd - sdt*sdt/stt) / (n - 2)
+		default:
+			if sdd <= 0 {
+				return 0
+			}
+			r := sdt / math.Sqrt(sdd*stt)
+			if criterion == TEMPORAL_R2 {
+				return r * r
+			}
+			return r
+		}
+	}
+
+	candidates := []float64{0, l}
+	switch criterion {
+	case TEMPORAL_RMS:
+		// Residual sum of squares: a*x² + b*x + c
+		a := sss - sst*sst/stt
+		b := 2 * (scs - sct*sst/stt)
+		if a > 0 {
+			candidates = append(candidates, -b/(2*a))
+		}
+	default:
+		// Derivative of the correlation vanishes at a single position
+		den := sst*scs - sct*sss
+		if den != 0 {
+			candidates = append(candidates, (sct*scs-sst*scc)/den)
+		}
+	}
+	score = math.Inf(-1)
+	for _, x := range candidates {
+		if x < 0 || x > l {
+			continue
+		}
+		if s := eval(x); s > score {
+			score, pos = s, x
+		}
+	}
+	return
+}
+
+// Reroots the tree on the given edge, at the given
+// distance from its right node: a new root node is
+// added on the edge.
+func (t *Tree) rerootOnEdge(e *Edge, dist float64) error {
+	newroot := t.NewNode()
+	l := e.Length()
+	b := e.Support()
+	left := e.Left()
+	right := e.Right()
+	left.delNeighbor(right)
+	right.delNeighbor(left)
+	e1 := t.ConnectNodes(newroot, left)
+	e2 := t.ConnectNodes(newroot, right)
+
+	if l != NIL_LENGTH {
+		e1.SetLength(math.Max(0, l-dist))
+		e2.SetLength(dist)
+	}
+	e1.SetSupport(b)
+	e2.SetSupport(b)
+	e1.attributes = cloneAttributes(e.attributes)
+	e2.attributes = cloneAttributes(e.attributes)
+
+	if err := t.Reroot(newroot); err != nil {
+		return err
+	}
+	t.ReinitInternalIndexes()
+	return nil
+}
@@ -22,8 +22,10 @@ import (
 
 // Tree structure having a root and a tip index, that maps tip names to their index
 type Tree struct {
-	root     *Node            // root node: If the tree is unrooted the root node should have 3 children
-	tipIndex map[string]*Node // Map between tip name and Node
+	root       *Node            // root node: If the tree is unrooted the root node should have 3 children
+	tipIndex   map[string]*Node // Map between tip name and Node
+	attributes *Attributes      // Tree-level attributes (e.g. nexus "TREE name [&lnP=-1234.5] = ...")
+	rootFlag   string           // Nexus rooting flag ("R" for [&R], "U" for [&U], "" if none)
 }
 
 // Type for channel of trees
@@ -41,6 +43,39 @@ func NewTree() *Tree {
 	}
 }
 
+// Associates the given tree-level attribute value to the given key.
+// If the key already exists, its value is replaced.
+func (t *Tree) SetAttribute(key string, value Attribute) {
+	if t.attributes == nil {
+		t.attributes = NewAttributes()
+	}
+	t.attributes.Set(key, value)
+}
+
+// Returns the tree-level attribute associated to the given key, and false
+// if it does not exist.
+func (t *Tree) Attribute(key string) (Attribute, bool) {
+	return t.attributes.Get(key)
+}
+
+// Returns the tree-level attributes. It may be nil if
+// no attribute has been set.
+func (t *Tree) Attributes() *Attributes {
+	return t.attributes
+}
+
+// Sets the nexus rooting flag of the tree: "R" ([&R]), "U" ([&U])
+// or "" (no flag)
+func (t *Tree) SetRootFlag(flag string) {
+	t.rootFlag = flag
+}
+
+// Returns the nexus rooting flag of the tree: "R" ([&R]), "U" ([&U])
+// or "" (no flag)
+func (t *Tree) RootFlag() string {
+	return t.rootFlag
+}
+
 // Initialize a new empty Node
 func (t *Tree) NewNode() *Node {
 	return &Node{
@@ -368,6 +403,12 @@ func (t *Tree) removeTip(tip *Node) error {
 		if length1 != NIL_LENGTH || length2 != NIL_LENGTH {
 			e.SetLength(math.Max(0, length1) + math.Max(0, length2))
 		}
+		// Attributes of the lower branch take precedence
+		if e.right == n2 {
+			e.attributes = mergeEdgeAttributes(b1, b2)
+		} else {
+			e.attributes = mergeEdgeAttributes(b2, b1)
+		}
 
 		// We attribute a support to the new branch only if it is not a tip branch
 		if (sup1 != NIL_SUPPORT || sup2 != NIL_SUPPORT) && len(n1.neigh) > 1 && len(n2.neigh) > 1 {
@@ -392,6 +433,33 @@ func (t *Tree) String() string {
 func (t *Tree) Newick() string {
 	var buffer bytes.Buffer
 	t.root.Newick(nil, &buffer)
+	if t.root.attributes.Len() != 0 {
+		buffer.WriteString("[")
+		buffer.WriteString(t.root.attributes.String())
+		buffer.WriteString("]")
+	}
+	if len(t.root.comment) != 0 {
+		for _, c := range t.root.comment {
+			buffer.WriteString("[")
+			buffer.WriteString(c)
+			buffer.WriteString("]")
+		}
+	}
+	buffer.WriteString(";")
+	return buffer.String()
+}
+
+// Returns a Newick string representation of this tree, in which
+// node and edge attributes are written in the NHX format
+// ([&&NHX:key=value:...]).
+func (t *Tree) NewickNHX() string {
+	var buffer bytes.Buffer
+	t.root.NewickNHX(nil, &buffer)
+	if t.root.attributes.Len() != 0 {
+		buffer.WriteString("[")
+		buffer.WriteString(t.root.attributes.NHXString())
+		buffer.WriteString("]")
+	}
 	if len(t.root.comment) != 0 {
 		for _, c := range t.root.comment {
 			buffer.WriteString("[")
@@ -405,7 +473,6 @@ func (t *Tree) Newick() string {
 
 // returns a Nexus string representation of this tree
 func (t *Tree) Nexus() string {
-	newick := t.Newick()
 	var buffer bytes.Buffer
 	buffer.WriteString("#NEXUS\n")
 	buffer.WriteString("BEGIN TAXA;\n")
@@ -420,13 +487,34 @@ func (t *Tree) Nexus() string {
 	buffer.WriteString(";\n")
 	buffer.WriteString("END;\n")
 	buffer.WriteString("BEGIN TREES;\n")
-	buffer.WriteString("  TREE tree1 = ")
-	buffer.WriteString(newick)
+	buffer.WriteString(t.NexusTree("tree1"))
 	buffer.WriteString("\n")
 	buffer.WriteString("END;\n")
 	return buffer.String()
 }
 
+// returns the TREE command of a nexus TREES block for this tree, with
+// the given name, its tree-level attributes and its rooting flag, e.g.
+// "TREE name [&key=value] = [&R] newick".
+func (t *Tree) NexusTree(name string) string {
+	var buffer bytes.Buffer
+	buffer.WriteString("  TREE ")
+	buffer.WriteString(name)
+	if t.attributes.Len() > 0 {
+		buffer.WriteString(" [")
+		buffer.WriteString(t.attributes.String())
+		buffer.WriteString("]")
+	}
+	buffer.WriteString(" = ")
+	if t.rootFlag != "" {
+		buffer.WriteString("[&")
+		buffer.WriteString(t.rootFlag)
+		buffer.WriteString("] ")
+	}
+	buffer.WriteString(t.Newick())
+	return buffer.String()
+}
+
 // Updates the tipindex which maps tip names to
 // their index in the bitsets.
 //
@@ -1121,6 +1209,7 @@ func (t *Tree) resolveRecur(current, previous *Node) {
 				etmp.SetLength(len)
 				etmp.SetSupport(boot)
 				etmp.SetPValue(pv)
+				etmp.attributes = e.attributes
 			}
 			// Connect new node to current node
 			e := t.ConnectNodes(current, n2)
@@ -1243,6 +1332,16 @@ func (t *Tree) ClearComments() {
 	t.ClearEdgeComments()
 }
 
+// Clears attributes associated to all nodes, tips and edges of the tree
+func (t *Tree) ClearAttributes() {
+	for _, n := range t.Nodes() {
+		n.ClearAttributes()
+	}
+	for _, e := range t.Edges() {
+		e.ClearAttributes()
+	}
+}
+
 func (t *Tree) ClearNodeComments() {
 	nodes := t.Nodes()
 	for _, n := range nodes {
@@ -1338,6 +1437,12 @@ func (t *Tree) UnRoot() {
 	if !n1.Tip() && !n2.Tip() && (e1.Support() != NIL_SUPPORT || e2.Support() != NIL_SUPPORT) {
 		e3.SetSupport(math.Max(math.Max(0, e1.Support()), math.Max(0, e2.Support())))
 	}
+	// Attributes of the branch leading to the new child take precedence
+	if e3.right == n1 {
+		e3.attributes = mergeEdgeAttributes(e2, e1)
+	} else {
+		e3.attributes = mergeEdgeAttributes(e1, e2)
+	}
 	t.delNode(root)
 
 	t.ReinitIndexes()
@@ -1586,6 +1691,7 @@ func (t *Tree) CopyNode(n *Node) *Node {
 	for i, c := range n.comment {
 		out.comment[i] = c
 	}
+	out.attributes = cloneAttributes(n.attributes)
 	return out
 }
 
@@ -1601,8 +1707,10 @@ func (t *Tree) Delete() {
 //	* Support
 //	* id
 //	* bitset (if not nil)
+//	* key/value attributes (if any)
 func (t *Tree) CopyEdge(e *Edge, copy *Edge) {
 	copy.length = e.length
+	copy.attributes = cloneAttributes(e.attributes)
 	copy.support = e.support
 	copy.pvalue = e.pvalue
 	copy.id = e.id
@@ -1626,6 +1734,10 @@ func (t *Tree) Clone() *Tree {
 	if t.tipIndex != nil {
 		copy.UpdateTipIndex()
 	}
+	if t.attributes != nil {
+		copy.attributes = t.attributes.Clone()
+	}
+	copy.rootFlag = t.rootFlag
 	return (copy)
 }
 
@@ -0,0 +1,416 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/fredericlemoine/bitset"
+)
+
+// Tree-to-tree distances computed by TreeDistances and CompareDistances
+const (
+	DISTANCE_WEIGHTED_RF    = iota // Weighted Robinson-Foulds distance
+	DISTANCE_BRANCH_SCORE          // Kuhner-Felsenstein branch score distance
+	DISTANCE_PATH                  // Path difference (number of branches)
+	DISTANCE_PATRISTIC             // Path difference (patristic distances)
+	DISTANCE_MATCHING_SPLIT        // Matching split distance
+	DISTANCE_GENERALIZED_RF        // Generalized RF: clustering information distance
+)
+
+// Type for channel of tree distances
+type DistanceStats struct {
+	Id        int       // Identifier of the compared tree
+	Distances []float64 // Values of the distances, in the requested order
+	Err       error     // Wether an error occured or not in the computation
+}
+
+// Bipartitions and tip-to-tip distances of a tree. Bipartitions are given by
+// the edge bitsets of the tree (see ReinitIndexes), whose tips are indexed
+// by their sorted names.
+type distanceTree struct {
+	n          int
+	splits     []*Edge    // One edge per distinct bipartition
+	index      *EdgeIndex // Bipartitions with their lengths (sum of lengths for root branches)
+	hasLengths bool
+	paths      []float64 // Number of branches between each pair of tips (i<j)
+	patristic  []float64 // Patristic distance between each pair of tips (i<j)
+}
+
+// This function computes the given distances between a reference tree and a set of trees
+// given in the input channel, using TreeDistances.
+//
+// This function returns almost immediately because computation is done in several go routines
+// in background. The returned channel is closed at the end of the computations.
+func CompareDistances(refTree *Tree, compTrees <-chan Trees, distances []int, cpus int) (<-chan DistanceStats, error) {
+	var ref *distanceTree
+	var err error
+
+	stats := make(chan DistanceStats)
+
+	if refTree == nil {
+		return nil, errors.New("Tree 1 in comparison is null")
+	}
+	if cpus < 1 {
+		cpus = 1
+	}
+	if ref, err = newDistanceTree(refTree, distances); err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	for cpu := 0; cpu < cpus; cpu++ {
+		wg.Add(1)
+		go func(cpu int) {
+			for treeV := range compTrees {
+				var comp *distanceTree
+				var st DistanceStats
+				var inerr error = treeV.Err
+				if inerr == nil {
+					if comp, inerr = newDistanceTree(treeV.Tree, distances); inerr == nil {
+						if inerr = refTree.CompareTipIndexes(treeV.Tree); inerr == nil {
+							st.Distances, inerr = ref.distances(comp, distances)
+						}
+					}
+				}
+				st.Id = treeV.Id
+				st.Err = inerr
+				stats <- st
+			}
+			wg.Done()
+		}(cpu)
+	}
+
+	go func() {
+		wg.Wait()
+		close(stats)
+	}()
+
+	return stats, nil
+}
+
+// Computes the given distances (DISTANCE_WEIGHTED_RF, etc.) between two trees having
+// the same set of tips. Trees may be multifurcated, and are considered unrooted:
+// the two branches around the root of a rooted tree are considered as a single one.
+//
+//   - DISTANCE_WEIGHTED_RF: Sum, over all the bipartitions of both trees (including tip branches),
+//     of the absolute differences of their lengths (length 0 if absent from a tree);
+//   - DISTANCE_BRANCH_SCORE: Kuhner & Felsenstein (1994) branch score: square root of the sum of
+//     the squared differences of lengths;
+//   - DISTANCE_PATH: Steel & Penny (1993) path difference: square root of the sum, over all pairs
+//     of tips, of the squared differences of the number of branches between them;
+//   - DISTANCE_PATRISTIC: Same with patristic distances;
+//   - DISTANCE_MATCHING_SPLIT: Bogdanowicz & Giaro (2012) matching split distance: minimum weight
+//     of a perfect matching between the internal bipartitions of both trees, the weight of a pair of
+//     bipartitions being the number of tips to move to transform one into the other (unmatched
+//     bipartitions are matched with the empty bipartition);
+//   - DISTANCE_GENERALIZED_RF: Smith (2020) clustering information distance, in bits:
+//     H(T1) + H(T2) - 2*MCI(T1,T2), H(T) being the sum of the entropies of the internal
+//     bipartitions of T, and MCI the maximum, over matchings of internal bipartitions,
+//     of their summed mutual information.
+//
+// Distances using branch lengths return an error if the trees do not have branch lengths.
+// Matching split and generalized RF distances run in O(n^3), path differences in O(n^2),
+// n being the number of tips.
+//
+// As in Compare, bipartitions are given by the edge bitsets of the trees, whose indexes
+// are (re)initialized (see ReinitIndexes).
+func TreeDistances(t1, t2 *Tree, distances []int) (d []float64, err error) {
+	var dt1, dt2 *distanceTree
+
+	if dt1, err = newDistanceTree(t1, distances); err != nil {
+		return
+	}
+	if dt2, err = newDistanceTree(t2, distances); err != nil {
+		return
+	}
+	if err = t1.CompareTipIndexes(t2); err != nil {
+		return
+	}
+	return dt1.distances(dt2, distances)
+}
+
+// Reinitializes the indexes of the tree, and indexes its bipartitions:
+// the two branches around a root of degree 2 define the same bipartition,
+// whose length is the sum of their lengths.
+func newDistanceTree(t *Tree, distances []int) (dt *distanceTree, err error) {
+	var edges []*Edge
+	var paths bool
+
+	if t.Root() == nil {
+		err = errors.New("The tree has no root")
+		return
+	}
+	if err = t.ReinitIndexes(); err != nil {
+		return
+	}
+	edges = t.Edges()
+	dt = &distanceTree{n: len(t.tipIndex), index: NewEdgeIndex(uint64(len(edges)*2), 0.75), hasLengths: true}
+	for _, e := range edges {
+		length := e.Length()
+		if length == NIL_LENGTH {
+			dt.hasLengths = false
+			length = 0
+		}
+		if v, ok := dt.index.Value(e); ok {
+			v.Len += length
+		} else {
+			dt.index.PutEdgeValue(e, 1, length)
+			dt.splits = append(dt.splits, e)
+		}
+	}
+
+	for _, d := range distances {
+		switch d {
+		case DISTANCE_WEIGHTED_RF, DISTANCE_BRANCH_SCORE, DISTANCE_PATRISTIC:
+			if !dt.hasLengths {
+				err = errors.New("This distance requires branch lengths")
+				return
+			}
+			paths = paths || d == DISTANCE_PATRISTIC
+		case DISTANCE_PATH:
+			paths = true
+		case DISTANCE_MATCHING_SPLIT, DISTANCE_GENERALIZED_RF:
+		default:
+			err = fmt.Errorf("Unknown distance: %d", d)
+			return
+		}
+	}
+	if paths {
+		dt.computePaths(t)
+	}
+	return
+}
+
+// Length of the bipartition of the given edge, 0 if absent from the tree
+func (dt *distanceTree) length(e *Edge) float64 {
+	if v, ok := dt.index.Value(e); ok {
+		return v.Len
+	}
+	return 0
+}
+
+// Number of branches and patristic distances between all pairs of tips.
+// The two branches around a root of degree 2 count as a single branch.
+func (dt *distanceTree) computePaths(t *Tree) {
+	npairs := dt.n * (dt.n - 1) / 2
+	dt.paths = make([]float64, npairs)
+	dt.patristic = make([]float64, npairs)
+	root := t.Root()
+	var walk func(cur, prev *Node, from int, topo, dist float64)
+	walk = func(cur, prev *Node, from int, topo, dist float64) {
+		if cur.Tip() && prev != nil {
+			if to := cur.tipid; from < to {
+				dt.paths[dt.pairIndex(from, to)] = topo
+				dt.patristic[dt.pairIndex(from, to)] = dist
+			}
+		}
+		for i, nb := range cur.neigh {
+			if nb == prev {
+				continue
+			}
+			step := 1.0
+			if root.Nneigh() == 2 && (cur == root || nb == root) {
+				step = 0.5
+			}
+			walk(nb, cur, from, topo+step, dist+math.Max(0, cur.br[i].Length()))
+		}
+	}
+	for _, tip := range t.Tips() {
+		walk(tip, nil, tip.tipid, 0, 0)
+	}
+}
+
+func (dt *distanceTree) pairIndex(i, j int) int {
+	return i*dt.n - i*(i+1)/2 + j - i - 1
+}
+
+// Internal bipartitions (both sides having at least 2 tips)
+func (dt *distanceTree) internalSplits() (internal []*bitset.BitSet) {
+	for _, e := range dt.splits {
+		if c := int(e.bitset.Count()); c > 1 && c < dt.n-1 {
+			internal = append(internal, e.bitset)
+		}
+	}
+	return
+}
+
+func (dt *distanceTree) distances(dt2 *distanceTree, distances []int) (d []float64, err error) {
+	if dt.n != dt2.n {
+		err = errors.New("Trees do not have the same set of tips")
+		return
+	}
+	d = make([]float64, len(distances))
+	for i, dist := range distances {
+		switch dist {
+		case DISTANCE_WEIGHTED_RF:
+			for _, diff := range dt.lengthDifferences(dt2) {
+				d[i] += math.Abs(diff)
+			}
+		case DISTANCE_BRANCH_SCORE:
+			for _, diff := range dt.lengthDifferences(dt2) {
+				d[i] += diff * diff
+			}
+			d[i] = math.Sqrt(d[i])
+		case DISTANCE_PATH:
+			d[i] = pathDifference(dt.paths, dt2.paths)
+		case DISTANCE_PATRISTIC:
+			d[i] = pathDifference(dt.patristic, dt2.patristic)
+		case DISTANCE_MATCHING_SPLIT:
+			d[i] = dt.matchingSplit(dt2)
+		case DISTANCE_GENERALIZED_RF:
+			d[i] = dt.clusteringInfoDistance(dt2)
+		}
+	}
+	return
+}
+
+// Differences of lengths of all the bipartitions of both trees
+func (dt *distanceTree) lengthDifferences(dt2 *distanceTree) (diffs []float64) {
+	for _, e := range dt.splits {
+		diffs = append(diffs, dt.length(e)-dt2.length(e))
+	}
+	for _, e := range dt2.splits {
+		if _, ok := dt.index.Value(e); !ok {
+			diffs = append(diffs, dt2.length(e))
+		}
+	}
+	return
+}
+
+func pathDifference(p1, p2 []float64) float64 {
+	sum := 0.0
+	for i := range p1 {
+		sum += (p1[i] - p2[i]) * (p1[i] - p2[i])
+	}
+	return math.Sqrt(sum)
+}
+
+func (dt *distanceTree) matchingSplit(dt2 *distanceTree) float64 {
+	s1, s2 := dt.internalSplits(), dt2.internalSplits()
+	k := len(s1)
+	if len(s2) > k {
+		k = len(s2)
+	}
+	cost := make([][]float64, k)
+	for i := range cost {
+		cost[i] = make([]float64, k)
+		for j := range cost[i] {
+			switch {
+			case i < len(s1) && j < len(s2):
+				diff := int(s1[i].SymmetricDifferenceCardinality(s2[j]))
+				cost[i][j] = float64(minInt(diff, dt.n-diff))
+			case i < len(s1):
+				c := int(s1[i].Count())
+				cost[i][j] = float64(minInt(c, dt.n-c))
+			case j < len(s2):
+				c := int(s2[j].Count())
+				cost[i][j] = float64(minInt(c, dt.n-c))
+			}
+		}
+	}
+	return minCostAssignment(cost)
+}
+
+func (dt *distanceTree) clusteringInfoDistance(dt2 *distanceTree) float64 {
+	s1, s2 := dt.internalSplits(), dt2.internalSplits()
+	n := float64(dt.n)
+	entropy := 0.0
+	for _, s := range append(append([]*bitset.BitSet{}, s1...), s2...) {
+		a := float64(s.Count())
+		entropy += clusteringEntropy(a/n) + clusteringEntropy((n-a)/n)
+	}
+	k := len(s1)
+	if len(s2) > k {
+		k = len(s2)
+	}
+	cost := make([][]float64, k)
+	for i := range cost {
+		cost[i] = make([]float64, k)
+		if i >= len(s1) {
+			continue
+		}
+		a := float64(s1[i].Count())
+		for j := 0; j < len(s2); j++ {
+			c := float64(s2[j].Count())
+			ac := float64(s1[i].IntersectionCardinality(s2[j]))
+			// Mutual information of the two bipartitions
+			mi := 0.0
+			for _, cell := range [][3]float64{{ac, a, c}, {a - ac, a, n - c}, {c - ac, n - a, c}, {n - a - c + ac, n - a, n - c}} {
+				if cell[0] > 0 {
+					mi += cell[0] / n * math.Log2(cell[0]*n/(cell[1]*cell[2]))
+				}
+			}
+			cost[i][j] = -mi
+		}
+	}
+	return math.Max(0, entropy+2*minCostAssignment(cost))
+}
+
+func clusteringEntropy(p float64) float64 {
+	if p <= 0 {
+		return 0
+	}
+	return -p * math.Log2(p)
+}
+
+// Minimum cost perfect matching of a square cost matrix (Hungarian algorithm, O(k^3))
+func minCostAssignment(cost [][]float64) (total float64) {
+	k := len(cost)
+	u := make([]float64, k+1)
+	v := make([]float64, k+1)
+	p := make([]int, k+1)
+	way := make([]int, k+1)
+	for i := 1; i <= k; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, k+1)
+		used := make([]bool, k+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0, j1, delta := p[j0], 0, math.Inf(1)
+			for j := 1; j <= k; j++ {
+				if used[j] {
+					continue
+				}
+				if cur := cost[i0-1][j-1] - u[i0] - v[j]; cur < minv[j] {
+					minv[j], way[j] = cur, j0
+				}
+				if minv[j] < delta {
+					delta, j1 = minv[j], j
+				}
+			}
+			for j := 0; j <= k; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			if j0 = j1; p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+	for j := 1; j <= k; j++ {
+		total += cost[p[j]-1][j-1]
+	}
+	return
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}